@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/openshift/managed-node-metadata-operator/controllers"
+	"github.com/openshift/managed-node-metadata-operator/pkg/machine"
+)
+
+// stringsFlag is a flag that can be given several times
+type stringsFlag []string
+
+func (f *stringsFlag) String() string {
+	return strings.Join(*f, ",")
+}
+
+func (f *stringsFlag) Set(value string) error {
+	*f = append(*f, value)
+	return nil
+}
+
+// runAdopt records as set by the operator the node labels that were set by hand to the value of their machine set
+// template
+func runAdopt(ctx context.Context, args []string, out io.Writer) error {
+	var opts clusterOptions
+	var machineSet, output string
+	var labels stringsFlag
+	var dryRun bool
+	fs := flag.NewFlagSet("adopt", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprint(fs.Output(), "Bring under the ownership of the operator the node labels already set to the value of their "+
+			"machine set template,\nby recording them in the managed.openshift.com/customlabels annotation of the nodes.\n\n"+
+			"Usage:\n  mnmoctl adopt [flags]\n\nFlags:\n")
+		fs.PrintDefaults()
+	}
+	opts.bindFlags(fs)
+	fs.StringVar(&machineSet, "machineset", "", "The machine set whose nodes to adopt the labels of. All the machine sets are adopted if empty.")
+	fs.Var(&labels, "label", "A label key to adopt, can be given several times. All the matching labels are adopted if not given.")
+	fs.BoolVar(&dryRun, "dry-run", false, "Only print the labels that would be adopted, without updating the nodes.")
+	fs.StringVar(&output, "output", outputTable, "The output format: table, yaml or json.")
+	fs.StringVar(&output, "o", outputTable, "Shorthand for --output.")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if err := checkOutput(output); err != nil {
+		return err
+	}
+
+	c, dc, err := opts.clients()
+	if err != nil {
+		return err
+	}
+	providers, namespace, err := machine.Providers(dc, opts.machineProvider, opts.clusterAPIVersion, opts.namespace)
+	if err != nil {
+		return err
+	}
+	adoptions, err := controllers.Adopt(ctx, c, providers, namespace, machineSet, labels, dryRun)
+	if err != nil {
+		return err
+	}
+	return printAdoptions(out, output, adoptions, dryRun)
+}
+
+// printAdoptions writes the adopted labels in the output format
+func printAdoptions(out io.Writer, output string, adoptions []controllers.Adoption, dryRun bool) error {
+	if printed, err := printStructured(out, output, adoptions); printed {
+		return err
+	}
+	if len(adoptions) == 0 {
+		_, err := fmt.Fprintln(out, "No labels to adopt.")
+		return err
+	}
+
+	header := "ADOPTED"
+	if dryRun {
+		header = "TO ADOPT"
+	}
+	w := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(w, "MACHINESET\tMACHINE\tNODE\t%s\n", header)
+	for _, adoption := range adoptions {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", adoption.MachineSet, adoption.Machine, adoption.Node, strings.Join(adoption.Labels, ","))
+	}
+	return w.Flush()
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/openshift/managed-node-metadata-operator/controllers"
+)
+
+var _ = Describe("adopt", func() {
+	var out *bytes.Buffer
+
+	adoptions := []controllers.Adoption{
+		{MachineSet: "infra-a", Machine: "infra-a-x2k9d", Node: "ip-10-0-1-12", Labels: []string{"foo", "zone"}},
+	}
+
+	BeforeEach(func() {
+		out = &bytes.Buffer{}
+	})
+
+	It("should print a row per node", func() {
+		Expect(printAdoptions(out, outputTable, adoptions, false)).To(Succeed())
+		lines := bytes.Split(bytes.TrimSpace(out.Bytes()), []byte("\n"))
+		Expect(lines).To(HaveLen(2))
+		Expect(string(lines[0])).To(MatchRegexp(`^MACHINESET\s+MACHINE\s+NODE\s+ADOPTED$`))
+		Expect(string(lines[1])).To(MatchRegexp(`^infra-a\s+infra-a-x2k9d\s+ip-10-0-1-12\s+foo,zone$`))
+	})
+
+	It("should say what would be adopted in a dry run", func() {
+		Expect(printAdoptions(out, outputTable, adoptions, true)).To(Succeed())
+		Expect(out.String()).To(ContainSubstring("TO ADOPT"))
+	})
+
+	It("should say when there is nothing to adopt", func() {
+		Expect(printAdoptions(out, outputTable, []controllers.Adoption{}, false)).To(Succeed())
+		Expect(out.String()).To(ContainSubstring("No labels to adopt"))
+	})
+
+	It("should print JSON", func() {
+		Expect(printAdoptions(out, outputJSON, adoptions, false)).To(Succeed())
+		var decoded []controllers.Adoption
+		Expect(json.Unmarshal(out.Bytes(), &decoded)).To(Succeed())
+		Expect(decoded).To(Equal(adoptions))
+	})
+
+	It("should take several label keys", func() {
+		var labels stringsFlag
+		Expect(labels.Set("foo")).To(Succeed())
+		Expect(labels.Set("zone")).To(Succeed())
+		Expect([]string(labels)).To(Equal([]string{"foo", "zone"}))
+	})
+})
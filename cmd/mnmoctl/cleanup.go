@@ -0,0 +1,92 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/openshift/managed-node-metadata-operator/controllers"
+	"github.com/openshift/managed-node-metadata-operator/pkg/machine"
+)
+
+// runCleanup removes what the operator applied to the nodes and the machine sets, before it's uninstalled
+func runCleanup(ctx context.Context, args []string, out io.Writer) error {
+	var opts clusterOptions
+	var policy, reportPath, output string
+	var dryRun bool
+	fs := flag.NewFlagSet("cleanup", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprint(fs.Output(), "Remove the ownership annotations the operator set on the nodes and the machine sets, and the labels and\n"+
+			"taints it applied with the remove policy. Stop the operator first, or it applies them again.\n\n"+
+			"Usage:\n  mnmoctl cleanup [flags]\n\nFlags:\n")
+		fs.PrintDefaults()
+	}
+	opts.bindFlags(fs)
+	fs.StringVar(&policy, "policy", controllers.CleanupPolicyKeep, "What to do with the labels and taints the operator applied: keep or remove.")
+	fs.StringVar(&reportPath, "report", "", "The file to write the JSON report of the cleanup to.")
+	fs.BoolVar(&dryRun, "dry-run", false, "Only print what would be cleaned up, without updating the objects.")
+	fs.StringVar(&output, "output", outputTable, "The output format: table, yaml or json.")
+	fs.StringVar(&output, "o", outputTable, "Shorthand for --output.")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if err := checkOutput(output); err != nil {
+		return err
+	}
+
+	c, dc, err := opts.clients()
+	if err != nil {
+		return err
+	}
+	providers, namespace, err := machine.Providers(dc, opts.machineProvider, opts.clusterAPIVersion, opts.namespace)
+	if err != nil {
+		return err
+	}
+	report, cleanupErr := controllers.Cleanup(ctx, c, providers, namespace, policy, dryRun)
+	if report == nil {
+		return cleanupErr
+	}
+	if reportPath != "" {
+		if err := controllers.WriteCleanupReport(report, reportPath); err != nil {
+			return fmt.Errorf("failed to write the report: %w", err)
+		}
+	}
+	if err := printCleanup(out, output, report); err != nil {
+		return err
+	}
+	return cleanupErr
+}
+
+// printCleanup writes the cleanup report in the output format
+func printCleanup(out io.Writer, output string, report *controllers.CleanupReport) error {
+	if printed, err := printStructured(out, output, report); printed {
+		return err
+	}
+	if len(report.Actions) == 0 {
+		_, err := fmt.Fprintln(out, "Nothing to clean up.")
+		return err
+	}
+
+	w := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "KIND\tNAME\tLABELS\tTAINTS\tANNOTATIONS\tERROR")
+	for _, action := range report.Actions {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", action.Kind, action.Name,
+			cleanupCell(action.RemovedLabels, action.KeptLabels), cleanupCell(action.RemovedTaints, action.KeptTaints),
+			cleanupCell(action.RemovedAnnotations, nil), orDash(action.Error))
+	}
+	return w.Flush()
+}
+
+// cleanupCell says which keys were removed and which were kept
+func cleanupCell(removed []string, kept []string) string {
+	switch {
+	case len(removed) > 0:
+		return "removed " + strings.Join(removed, ",")
+	case len(kept) > 0:
+		return "kept " + strings.Join(kept, ",")
+	}
+	return "-"
+}
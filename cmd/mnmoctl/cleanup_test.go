@@ -0,0 +1,47 @@
+package main
+
+import (
+	"bytes"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/openshift/managed-node-metadata-operator/controllers"
+)
+
+var _ = Describe("cleanup", func() {
+	var out *bytes.Buffer
+
+	report := &controllers.CleanupReport{
+		Policy: controllers.CleanupPolicyRemove,
+		Actions: []controllers.CleanupAction{
+			{Kind: "MachineSet", Namespace: "openshift-machine-api", Name: "infra-a", RemovedLabels: []string{"foo"},
+				RemovedAnnotations: []string{"managed.openshift.com/capacity-labels"}},
+			{Kind: "Node", Name: "ip-10-0-1-12", KeptTaints: []string{"dedicated:NoSchedule"},
+				RemovedAnnotations: []string{"managed.openshift.com/customlabels"}, Error: "conflict"},
+		},
+	}
+
+	BeforeEach(func() {
+		out = &bytes.Buffer{}
+	})
+
+	It("should print a row per object", func() {
+		Expect(printCleanup(out, outputTable, report)).To(Succeed())
+		lines := bytes.Split(bytes.TrimSpace(out.Bytes()), []byte("\n"))
+		Expect(lines).To(HaveLen(3))
+		Expect(string(lines[0])).To(MatchRegexp(`^KIND\s+NAME\s+LABELS\s+TAINTS\s+ANNOTATIONS\s+ERROR$`))
+		Expect(string(lines[1])).To(MatchRegexp(`^MachineSet\s+infra-a\s+removed foo\s+-\s+removed managed.openshift.com/capacity-labels\s+-$`))
+		Expect(string(lines[2])).To(MatchRegexp(`^Node\s+ip-10-0-1-12\s+-\s+kept dedicated:NoSchedule\s+removed managed.openshift.com/customlabels\s+conflict$`))
+	})
+
+	It("should say when there is nothing to clean up", func() {
+		Expect(printCleanup(out, outputTable, &controllers.CleanupReport{})).To(Succeed())
+		Expect(out.String()).To(ContainSubstring("Nothing to clean up"))
+	})
+
+	It("should print YAML", func() {
+		Expect(printCleanup(out, outputYAML, report)).To(Succeed())
+		Expect(out.String()).To(ContainSubstring("policy: remove"))
+	})
+})
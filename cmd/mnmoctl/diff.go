@@ -0,0 +1,177 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"text/tabwriter"
+
+	"github.com/openshift/managed-node-metadata-operator/controllers"
+	"github.com/openshift/managed-node-metadata-operator/pkg/machine"
+	"github.com/openshift/managed-node-metadata-operator/pkg/plan"
+	"k8s.io/client-go/discovery"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/yaml"
+)
+
+// Output formats of the commands
+const (
+	outputTable = "table"
+	outputYAML  = "yaml"
+	outputJSON  = "json"
+)
+
+// runDiff prints the changes a sync would make to the machines and nodes of the machine sets
+func runDiff(ctx context.Context, args []string, out io.Writer) error {
+	var opts clusterOptions
+	var machineSet, output string
+	fs := flag.NewFlagSet("diff", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprint(fs.Output(), "Preview, per Machine and Node, the labels and taints a sync would add, change, remove or skip.\n\n"+
+			"Usage:\n  mnmoctl diff [flags]\n\nFlags:\n")
+		fs.PrintDefaults()
+	}
+	opts.bindFlags(fs)
+	fs.StringVar(&machineSet, "machineset", "", "The machine set to diff. All the machine sets are diffed if empty.")
+	fs.StringVar(&output, "output", outputTable, "The output format: table, yaml or json.")
+	fs.StringVar(&output, "o", outputTable, "Shorthand for --output.")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if err := checkOutput(output); err != nil {
+		return err
+	}
+
+	c, dc, err := opts.clients()
+	if err != nil {
+		return err
+	}
+	diffs, err := diffMachineSets(ctx, c, dc, opts, machineSet)
+	if err != nil {
+		return err
+	}
+	return printDiff(out, output, diffs)
+}
+
+// diffMachineSets returns the plans of the syncs of the machine sets of the provider selected by the options, or of
+// the named machine set only if not empty
+func diffMachineSets(ctx context.Context, c client.Client, dc discovery.ServerGroupsInterface, opts clusterOptions, machineSet string) ([]plan.Plan, error) {
+	providers, namespace, err := machine.Providers(dc, opts.machineProvider, opts.clusterAPIVersion, opts.namespace)
+	if err != nil {
+		return nil, err
+	}
+	diffs, err := controllers.Diff(ctx, c, providers, namespace, machineSet)
+	if err != nil {
+		return nil, err
+	}
+	if machineSet != "" && len(diffs) == 0 {
+		return nil, fmt.Errorf("machine set %s not found in namespace %s", machineSet, namespace)
+	}
+	return diffs, nil
+}
+
+// bindFlags adds the cluster flags to the flag set
+func (o *clusterOptions) bindFlags(fs *flag.FlagSet) {
+	fs.StringVar(&o.kubeconfig, "kubeconfig", "",
+		"The kubeconfig of the cluster. Defaults to the KUBECONFIG environment variable or ~/.kube/config.")
+	o.bindMachineFlags(fs)
+}
+
+// bindMachineFlags adds the flags selecting the machine sets to the flag set
+func (o *clusterOptions) bindMachineFlags(fs *flag.FlagSet) {
+	fs.StringVar(&o.machineProvider, "machine-provider", machine.ProviderAuto,
+		"The machine management API: auto, machine-api or cluster-api.")
+	fs.StringVar(&o.clusterAPIVersion, "cluster-api-version", "v1beta1",
+		"The cluster.x-k8s.io API version to use when the cluster-api provider is selected explicitly.")
+	fs.StringVar(&o.namespace, "namespace", "",
+		"The namespace of the machine sets. Defaults to openshift-machine-api for the Machine API, required for the Cluster API.")
+}
+
+// checkOutput returns an error if the output format is unknown
+func checkOutput(output string) error {
+	if output != outputTable && output != outputYAML && output != outputJSON {
+		return fmt.Errorf("unknown output format %q", output)
+	}
+	return nil
+}
+
+// printStructured writes the value as JSON or YAML, it returns false for the table output format
+func printStructured(out io.Writer, output string, value interface{}) (bool, error) {
+	switch output {
+	case outputJSON:
+		encoder := json.NewEncoder(out)
+		encoder.SetIndent("", "  ")
+		return true, encoder.Encode(value)
+	case outputYAML:
+		data, err := yaml.Marshal(value)
+		if err != nil {
+			return true, err
+		}
+		_, err = out.Write(data)
+		return true, err
+	}
+	return false, nil
+}
+
+// printDiff writes the plans in the output format
+func printDiff(out io.Writer, output string, diffs []plan.Plan) error {
+	if printed, err := printStructured(out, output, diffs); printed {
+		return err
+	}
+	return printDiffTable(out, diffs)
+}
+
+// printDiffTable writes a row per change and per skipped machine
+func printDiffTable(out io.Writer, diffs []plan.Plan) error {
+	rows := 0
+	w := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "MACHINESET\tMACHINE\tNODE\tKIND\tMETADATA\tKEY\tCHANGE\tOLD\tNEW\tREASON")
+	for _, diff := range diffs {
+		for _, m := range diff.Machines {
+			if m.Skipped != "" {
+				fmt.Fprintf(w, "%s\t%s\t%s\t-\t-\t-\t%s\t-\t-\t%s\n", diff.Name, m.Name, orNone(m.Node), plan.ChangeSkipped, m.Skipped)
+				rows++
+				continue
+			}
+			for _, change := range m.Operations {
+				fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n", diff.Name, m.Name, m.Node, change.Kind, change.Metadata,
+					change.Key, change.Change, valueOrNone(change.OldValue), valueOrNone(change.NewValue), orDash(change.Reason))
+				rows++
+			}
+		}
+	}
+	if rows == 0 {
+		_, err := fmt.Fprintln(out, "No changes, the machines and nodes are in sync.")
+		return err
+	}
+	return w.Flush()
+}
+
+// valueOrNone returns the value, quoted if it's empty, or <none> if it's nil
+func valueOrNone(value *string) string {
+	switch {
+	case value == nil:
+		return "<none>"
+	case *value == "":
+		return `""`
+	}
+	return *value
+}
+
+// orNone returns the string, or <none> if it's empty
+func orNone(s string) string {
+	if s == "" {
+		return "<none>"
+	}
+	return s
+}
+
+// orDash returns the string, or - if it's empty
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
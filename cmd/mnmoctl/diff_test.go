@@ -0,0 +1,72 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/openshift/managed-node-metadata-operator/pkg/plan"
+	"sigs.k8s.io/yaml"
+)
+
+var _ = Describe("diff", func() {
+	var out *bytes.Buffer
+
+	oldValue, newValue := "old", "bar"
+	diffs := []plan.Plan{{
+		Provider:  "machineset",
+		Namespace: "openshift-machine-api",
+		Name:      "infra-a",
+		Machines: []plan.MachinePlan{
+			{
+				Name: "infra-a-x2k9d",
+				Node: "ip-10-0-1-12",
+				Operations: []plan.Operation{
+					{Kind: "Node", Metadata: "label", Key: "foo", Change: "changed", OldValue: &oldValue, NewValue: &newValue},
+					{Kind: "Node", Metadata: "label", Key: "team", Change: plan.ChangeSkipped, NewValue: &newValue, Reason: plan.ReasonLabelConflict},
+				},
+			},
+			{Name: "infra-a-b7cq2", Skipped: plan.ReasonNoNode, Operations: []plan.Operation{}},
+		},
+	}}
+
+	BeforeEach(func() {
+		out = &bytes.Buffer{}
+	})
+
+	It("should print a row per change and skipped machine", func() {
+		Expect(printDiff(out, outputTable, diffs)).To(Succeed())
+		lines := bytes.Split(bytes.TrimSpace(out.Bytes()), []byte("\n"))
+		Expect(lines).To(HaveLen(4))
+		Expect(string(lines[0])).To(MatchRegexp(`^MACHINESET\s+MACHINE\s+NODE\s+KIND\s+METADATA\s+KEY\s+CHANGE\s+OLD\s+NEW\s+REASON$`))
+		Expect(string(lines[1])).To(MatchRegexp(`^infra-a\s+infra-a-x2k9d\s+ip-10-0-1-12\s+Node\s+label\s+foo\s+changed\s+old\s+bar\s+-$`))
+		Expect(string(lines[2])).To(MatchRegexp(`team\s+skipped\s+<none>\s+bar\s+LabelConflict$`))
+		Expect(string(lines[3])).To(MatchRegexp(`^infra-a\s+infra-a-b7cq2\s+<none>\s+-\s+-\s+-\s+skipped\s+-\s+-\s+NoNode$`))
+	})
+
+	It("should say when there are no changes", func() {
+		Expect(printDiff(out, outputTable, []plan.Plan{{Name: "infra-a"}})).To(Succeed())
+		Expect(out.String()).To(ContainSubstring("No changes"))
+	})
+
+	It("should print JSON", func() {
+		Expect(printDiff(out, outputJSON, diffs)).To(Succeed())
+		var decoded []plan.Plan
+		Expect(json.Unmarshal(out.Bytes(), &decoded)).To(Succeed())
+		Expect(decoded).To(Equal(diffs))
+	})
+
+	It("should print YAML", func() {
+		Expect(printDiff(out, outputYAML, diffs)).To(Succeed())
+		var decoded []plan.Plan
+		Expect(yaml.Unmarshal(out.Bytes(), &decoded)).To(Succeed())
+		Expect(decoded).To(Equal(diffs))
+	})
+
+	It("should reject unknown output formats", func() {
+		Expect(run(context.TODO(), []string{"diff", "-o", "xml"}, out)).To(MatchError(ContainSubstring("xml")))
+	})
+})
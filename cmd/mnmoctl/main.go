@@ -0,0 +1,117 @@
+// mnmoctl inspects how the managed-node-metadata-operator would sync the labels and taints of the machine sets of a
+// cluster, or of its dumps, brings pre-existing node labels under its ownership, and removes what it applied before it's uninstalled.
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	cpmsv1 "github.com/openshift/api/machine/v1"
+	machinev1 "github.com/openshift/api/machine/v1beta1"
+	"k8s.io/apimachinery/pkg/runtime"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/client-go/discovery"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	_ "k8s.io/client-go/plugin/pkg/client/auth/gcp"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const usage = `mnmoctl inspects and prepares how the managed-node-metadata-operator syncs the labels and taints of the machine sets.
+
+Usage:
+  mnmoctl <command> [flags]
+
+Commands:
+  diff      Preview the label and taint changes of the machines and nodes of the machine sets
+  plan      Preview the same changes offline, from YAML or JSON dumps such as a must-gather
+  report    Report per node and key whether the labels and taints match the machine set templates, as JSON or CSV
+  adopt     Record the node labels already set to the value of their machine set template as set by the operator
+  rollback  Restore the template of a machine set recorded by the operator at a generation
+  cleanup   Remove the ownership annotations, and optionally the labels and taints, the operator applied
+
+Run "mnmoctl <command> -h" for the flags of a command.
+`
+
+var scheme = runtime.NewScheme()
+
+func init() {
+	utilruntime.Must(clientgoscheme.AddToScheme(scheme))
+
+	utilruntime.Must(machinev1.AddToScheme(scheme))
+	utilruntime.Must(cpmsv1.AddToScheme(scheme))
+}
+
+func main() {
+	if err := run(context.Background(), os.Args[1:], os.Stdout); err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		if errors.Is(err, errDrift) {
+			os.Exit(2)
+		}
+		os.Exit(1)
+	}
+}
+
+// run runs the command of the arguments, writing its output to out
+func run(ctx context.Context, args []string, out io.Writer) error {
+	if len(args) == 0 {
+		fmt.Fprint(os.Stderr, usage)
+		return fmt.Errorf("no command given")
+	}
+	switch args[0] {
+	case "diff":
+		return runDiff(ctx, args[1:], out)
+	case "plan":
+		return runPlan(ctx, args[1:], out)
+	case "report":
+		return runReport(ctx, args[1:], out)
+	case "adopt":
+		return runAdopt(ctx, args[1:], out)
+	case "rollback":
+		return runRollback(ctx, args[1:], out)
+	case "cleanup":
+		return runCleanup(ctx, args[1:], out)
+	case "help", "-h", "--help":
+		fmt.Fprint(out, usage)
+		return nil
+	}
+	fmt.Fprint(os.Stderr, usage)
+	return fmt.Errorf("unknown command %q", args[0])
+}
+
+// clusterOptions are the flags selecting the cluster and its machine management API, shared by the commands
+type clusterOptions struct {
+	kubeconfig        string
+	machineProvider   string
+	clusterAPIVersion string
+	namespace         string
+}
+
+// restConfig returns the config of the cluster from the kubeconfig flag, the KUBECONFIG environment variable or
+// the default kubeconfig file
+func (o *clusterOptions) restConfig() (*rest.Config, error) {
+	loadingRules := clientcmd.NewDefaultClientConfigLoadingRules()
+	loadingRules.ExplicitPath = o.kubeconfig
+	return clientcmd.NewNonInteractiveDeferredLoadingClientConfig(loadingRules, &clientcmd.ConfigOverrides{}).ClientConfig()
+}
+
+// clients returns a client and a discovery client of the cluster
+func (o *clusterOptions) clients() (client.Client, discovery.DiscoveryInterface, error) {
+	cfg, err := o.restConfig()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to load the kubeconfig: %w", err)
+	}
+	c, err := client.New(cfg, client.Options{Scheme: scheme})
+	if err != nil {
+		return nil, nil, err
+	}
+	dc, err := discovery.NewDiscoveryClientForConfig(cfg)
+	if err != nil {
+		return nil, nil, err
+	}
+	return c, dc, nil
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/openshift/managed-node-metadata-operator/pkg/offline"
+)
+
+// runPlan prints the changes a sync would make to the machines and nodes of machine sets loaded from dumps, without
+// an API server
+func runPlan(ctx context.Context, args []string, out io.Writer) error {
+	var opts clusterOptions
+	var dir, machineSet, output string
+	fs := flag.NewFlagSet("plan", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprint(fs.Output(), "Preview, per Machine and Node, the labels and taints a sync would add, change, remove or skip,\n"+
+			"from the MachineSets, Machines and Nodes of YAML or JSON dumps such as a must-gather.\n\n"+
+			"Usage:\n  mnmoctl plan --from-dir <dir> [flags]\n\nFlags:\n")
+		fs.PrintDefaults()
+	}
+	opts.bindMachineFlags(fs)
+	fs.StringVar(&dir, "from-dir", "", "The directory of the YAML or JSON dumps, searched recursively.")
+	fs.StringVar(&machineSet, "machineset", "", "The machine set to plan. All the machine sets are planned if empty.")
+	fs.StringVar(&output, "output", outputTable, "The output format: table, yaml or json.")
+	fs.StringVar(&output, "o", outputTable, "Shorthand for --output.")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if dir == "" {
+		return fmt.Errorf("--from-dir is required")
+	}
+	if err := checkOutput(output); err != nil {
+		return err
+	}
+
+	cluster, err := loadDumps(dir, fs.Output())
+	if err != nil {
+		return err
+	}
+	diffs, err := diffMachineSets(ctx, cluster.Client(), cluster, opts, machineSet)
+	if err != nil {
+		return err
+	}
+	return printDiff(out, output, diffs)
+}
+
+// loadDumps loads the objects of the dumps of the directory, warning about the files skipped
+func loadDumps(dir string, warnings io.Writer) (*offline.Cluster, error) {
+	cluster, err := offline.Load(dir, scheme)
+	if err != nil {
+		return nil, err
+	}
+	skipped := make([]string, 0, len(cluster.Skipped))
+	for path := range cluster.Skipped {
+		skipped = append(skipped, path)
+	}
+	sort.Strings(skipped)
+	for _, path := range skipped {
+		fmt.Fprintf(warnings, "warning: skipped %s: %v\n", path, cluster.Skipped[path])
+	}
+	return cluster, nil
+}
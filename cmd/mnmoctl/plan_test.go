@@ -0,0 +1,100 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/openshift/managed-node-metadata-operator/pkg/plan"
+)
+
+const mustGatherMachines = `apiVersion: machine.openshift.io/v1beta1
+kind: MachineSetList
+items:
+- apiVersion: machine.openshift.io/v1beta1
+  kind: MachineSet
+  metadata:
+    name: infra-a
+    namespace: openshift-machine-api
+  spec:
+    selector:
+      matchLabels:
+        pool: infra-a
+    template:
+      metadata:
+        labels:
+          pool: infra-a
+      spec:
+        metadata:
+          labels:
+            team: payments
+---
+apiVersion: machine.openshift.io/v1beta1
+kind: Machine
+metadata:
+  name: infra-a-x2k9d
+  namespace: openshift-machine-api
+  labels:
+    pool: infra-a
+status:
+  nodeRef:
+    name: ip-10-0-1-12
+`
+
+const mustGatherNode = `apiVersion: v1
+kind: Node
+metadata:
+  name: ip-10-0-1-12
+  labels:
+    team: platform
+  annotations:
+    managed.openshift.com/customlabels: team
+status:
+  conditions:
+  - type: Ready
+    status: "True"
+`
+
+var _ = Describe("plan", func() {
+	var (
+		out *bytes.Buffer
+		dir string
+	)
+
+	BeforeEach(func() {
+		out = &bytes.Buffer{}
+		dir = GinkgoT().TempDir()
+		Expect(os.WriteFile(filepath.Join(dir, "machines.yaml"), []byte(mustGatherMachines), 0o644)).To(Succeed())
+		Expect(os.MkdirAll(filepath.Join(dir, "nodes"), 0o755)).To(Succeed())
+		Expect(os.WriteFile(filepath.Join(dir, "nodes", "ip-10-0-1-12.yaml"), []byte(mustGatherNode), 0o644)).To(Succeed())
+	})
+
+	It("should plan the machine sets of the dumps", func() {
+		Expect(run(context.TODO(), []string{"plan", "--from-dir", dir, "-o", "json"}, out)).To(Succeed())
+		var diffs []plan.Plan
+		Expect(json.Unmarshal(out.Bytes(), &diffs)).To(Succeed())
+		Expect(diffs).To(HaveLen(1))
+		Expect(diffs[0].Name).To(Equal("infra-a"))
+		Expect(diffs[0].Machines).To(HaveLen(1))
+
+		changes := map[string]string{}
+		for _, op := range diffs[0].Machines[0].Operations {
+			changes[op.Kind+"/"+op.Key] = op.Change
+		}
+		Expect(changes).To(HaveKeyWithValue("Node/team", plan.ChangeChanged))
+		Expect(changes).To(HaveKeyWithValue("Machine/team", plan.ChangeAdded))
+	})
+
+	It("should require the directory", func() {
+		Expect(run(context.TODO(), []string{"plan"}, out)).NotTo(Succeed())
+	})
+
+	It("should fail for an unknown machine set", func() {
+		Expect(run(context.TODO(), []string{"plan", "--from-dir", dir, "--machineset", "infra-b"}, out)).NotTo(Succeed())
+	})
+})
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+
+	"github.com/openshift/managed-node-metadata-operator/controllers"
+	"k8s.io/client-go/discovery"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// outputCSV is the CSV output format of the report command
+const outputCSV = "csv"
+
+// errDrift is returned when a report finds nodes out of sync, so scheduled checks fail
+var errDrift = errors.New("drift found")
+
+// runReport writes the comparison of the labels and taints of every node with its machine set template, and fails
+// with errDrift if a node drifted
+func runReport(ctx context.Context, args []string, out io.Writer) error {
+	var opts clusterOptions
+	var dir, machineSet, output string
+	fs := flag.NewFlagSet("report", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprint(fs.Output(), "Report, per node and per key, whether the labels and taints of the nodes match their machine set template.\n"+
+			"Exits with 2 if a node drifted.\n\n"+
+			"Usage:\n  mnmoctl report [flags]\n\nFlags:\n")
+		fs.PrintDefaults()
+	}
+	opts.bindFlags(fs)
+	fs.StringVar(&dir, "from-dir", "", "Report from the YAML or JSON dumps of the directory, such as a must-gather, instead of the cluster.")
+	fs.StringVar(&machineSet, "machineset", "", "The machine set to report. All the machine sets are reported if empty.")
+	fs.StringVar(&output, "output", outputJSON, "The output format: json or csv.")
+	fs.StringVar(&output, "o", outputJSON, "Shorthand for --output.")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if output != outputJSON && output != outputCSV {
+		return fmt.Errorf("unknown output format %q", output)
+	}
+
+	var c client.Client
+	var dc discovery.ServerGroupsInterface
+	if dir != "" {
+		cluster, err := loadDumps(dir, fs.Output())
+		if err != nil {
+			return err
+		}
+		c, dc = cluster.Client(), cluster
+	} else {
+		var err error
+		if c, dc, err = opts.clients(); err != nil {
+			return err
+		}
+	}
+	diffs, err := diffMachineSets(ctx, c, dc, opts, machineSet)
+	if err != nil {
+		return err
+	}
+	report := controllers.NewDriftReport(diffs)
+	if err := printReport(out, fs.Output(), output, report); err != nil {
+		return err
+	}
+	if report.Summary.NodesDrifted > 0 {
+		return fmt.Errorf("%w: %d of %d nodes drifted", errDrift, report.Summary.NodesDrifted, report.Summary.Nodes)
+	}
+	return nil
+}
+
+// printReport writes the report in the output format. The CSV rows have no room for the totals, they're written
+// to summary instead.
+func printReport(out io.Writer, summary io.Writer, output string, report *controllers.DriftReport) error {
+	if output == outputJSON {
+		_, err := printStructured(out, outputJSON, report)
+		return err
+	}
+	if err := report.WriteCSV(out); err != nil {
+		return err
+	}
+	s := report.Summary
+	_, err := fmt.Fprintf(summary, "%d machine sets, %d nodes: %d in sync, %d drifted, %d skipped; %d keys in sync, %d drifted\n",
+		s.MachineSets, s.Nodes, s.NodesInSync, s.NodesDrifted, s.NodesSkipped, s.KeysInSync, s.KeysDrifted)
+	return err
+}
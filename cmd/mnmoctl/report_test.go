@@ -0,0 +1,59 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/openshift/managed-node-metadata-operator/controllers"
+)
+
+var _ = Describe("report", func() {
+	var (
+		out *bytes.Buffer
+		dir string
+	)
+
+	BeforeEach(func() {
+		out = &bytes.Buffer{}
+		dir = GinkgoT().TempDir()
+		Expect(os.WriteFile(filepath.Join(dir, "machines.yaml"), []byte(mustGatherMachines), 0o644)).To(Succeed())
+		Expect(os.WriteFile(filepath.Join(dir, "node.yaml"), []byte(mustGatherNode), 0o644)).To(Succeed())
+	})
+
+	It("should fail with the drift error when a node drifted", func() {
+		err := run(context.TODO(), []string{"report", "--from-dir", dir}, out)
+		Expect(errors.Is(err, errDrift)).To(BeTrue())
+
+		report := &controllers.DriftReport{}
+		Expect(json.Unmarshal(out.Bytes(), report)).To(Succeed())
+		Expect(report.Summary.Nodes).To(Equal(1))
+		Expect(report.Summary.NodesDrifted).To(Equal(1))
+	})
+
+	It("should write CSV", func() {
+		Expect(run(context.TODO(), []string{"report", "--from-dir", dir, "-o", "csv"}, out)).To(MatchError(errDrift))
+		rows, err := csv.NewReader(out).ReadAll()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(rows).To(HaveLen(2))
+		Expect(rows[1][:5]).To(Equal([]string{"infra-a", "infra-a-x2k9d", "ip-10-0-1-12", "label", "team"}))
+	})
+
+	It("should succeed when the nodes are in sync", func() {
+		Expect(os.WriteFile(filepath.Join(dir, "node.yaml"), bytes.ReplaceAll([]byte(mustGatherNode), []byte("platform"), []byte("payments")), 0o644)).To(Succeed())
+		Expect(run(context.TODO(), []string{"report", "--from-dir", dir}, out)).To(Succeed())
+	})
+
+	It("should refuse unknown formats", func() {
+		err := run(context.TODO(), []string{"report", "--from-dir", dir, "-o", "table"}, out)
+		Expect(err).To(HaveOccurred())
+		Expect(errors.Is(err, errDrift)).To(BeFalse())
+	})
+})
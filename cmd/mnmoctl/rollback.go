@@ -0,0 +1,141 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/openshift/managed-node-metadata-operator/controllers"
+	"github.com/openshift/managed-node-metadata-operator/pkg/history"
+	"github.com/openshift/managed-node-metadata-operator/pkg/machine"
+	"github.com/openshift/managed-node-metadata-operator/pkg/plan"
+	appsv1 "k8s.io/api/apps/v1"
+	k8serr "k8s.io/apimachinery/pkg/api/errors"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// machineAPINamespace is the namespace of the Machine API machine sets
+	machineAPINamespace = "openshift-machine-api"
+	// operatorDeploymentName is the name of the deployment of the operator, in the namespace of its history
+	operatorDeploymentName = "managed-node-metadata-operator"
+)
+
+// runRollback restores the template of a machine set recorded by the operator at a generation.
+// The nodes target requires the operator to be stopped: its next sync would bring the machines and nodes back to
+// the template of the machine set. The rollback is refused while the operator deployment has ready replicas,
+// unless --force is given.
+func runRollback(ctx context.Context, args []string, out io.Writer) error {
+	var opts clusterOptions
+	var machineSet, target, output, historyNamespace, historyName string
+	var generation int64
+	var list, dryRun, force bool
+	fs := flag.NewFlagSet("rollback", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprint(fs.Output(), "Restore the labels and taints of the template of a Machine API machine set, as recorded by the operator\n"+
+			"when the nodes converged to a generation, on the machine set or directly on its machines and nodes.\n\n"+
+			"Usage:\n  mnmoctl rollback --machineset <name> --list\n  mnmoctl rollback --machineset <name> --to-generation <generation> [flags]\n\nFlags:\n")
+		fs.PrintDefaults()
+	}
+	fs.StringVar(&opts.kubeconfig, "kubeconfig", "",
+		"The kubeconfig of the cluster. Defaults to the KUBECONFIG environment variable or ~/.kube/config.")
+	fs.StringVar(&opts.namespace, "namespace", machineAPINamespace, "The namespace of the machine set.")
+	fs.StringVar(&machineSet, "machineset", "", "The machine set to roll back.")
+	fs.Int64Var(&generation, "to-generation", 0, "The generation of the machine set whose template to restore. "+
+		"A generation that isn't listed because it didn't change the template, like a replica change, restores the template listed before it.")
+	fs.BoolVar(&list, "list", false, "Only list the generations recorded in the history of the machine set.")
+	fs.StringVar(&target, "target", controllers.RollbackTargetMachineSet,
+		"Where to restore the template: machineset, or nodes to sync the machines and nodes without changing the machine set.")
+	fs.BoolVar(&dryRun, "dry-run", false, "Only print the changes the restored template makes to the machines and nodes.")
+	fs.BoolVar(&force, "force", false, "Restore the template on the nodes even though the operator is running and would sync them back.")
+	fs.StringVar(&historyNamespace, "history-configmap-namespace", history.DefaultNamespace, "The namespace of the history ConfigMap of the operator.")
+	fs.StringVar(&historyName, "history-configmap-name", history.DefaultName, "The name of the history ConfigMap of the operator.")
+	fs.StringVar(&output, "output", outputTable, "The output format: table, yaml or json.")
+	fs.StringVar(&output, "o", outputTable, "Shorthand for --output.")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if machineSet == "" {
+		return fmt.Errorf("--machineset is required")
+	}
+	if !list && generation == 0 {
+		return fmt.Errorf("--to-generation or --list is required")
+	}
+	if err := checkOutput(output); err != nil {
+		return err
+	}
+
+	c, _, err := opts.clients()
+	if err != nil {
+		return err
+	}
+	store := &history.Store{Client: c, Namespace: historyNamespace, Name: historyName}
+	if list {
+		snapshots, err := store.Get(ctx, history.Key(machine.MachineAPIProvider{}.Name(), opts.namespace, machineSet))
+		if err != nil {
+			return err
+		}
+		return printHistory(out, output, snapshots)
+	}
+	if target == controllers.RollbackTargetNodes && !dryRun && !force {
+		if err := checkOperatorStopped(ctx, c, historyNamespace); err != nil {
+			return err
+		}
+	}
+	rollbackPlan, err := controllers.Rollback(ctx, c, store, opts.namespace, machineSet, generation, target, dryRun)
+	if err != nil {
+		return err
+	}
+	return printDiff(out, output, []plan.Plan{*rollbackPlan})
+}
+
+// checkOperatorStopped returns an error if the operator deployment in the namespace has ready replicas
+func checkOperatorStopped(ctx context.Context, c client.Client, namespace string) error {
+	deployment := &appsv1.Deployment{}
+	err := c.Get(ctx, client.ObjectKey{Namespace: namespace, Name: operatorDeploymentName}, deployment)
+	if k8serr.IsNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to check that the operator is stopped: %w", err)
+	}
+	if deployment.Status.ReadyReplicas > 0 {
+		return fmt.Errorf("the operator deployment %s/%s has %d ready replicas and would sync the nodes back to the machine set template, "+
+			"scale it down first or pass --force", namespace, operatorDeploymentName, deployment.Status.ReadyReplicas)
+	}
+	return nil
+}
+
+// printHistory writes the snapshots of a machine set in the output format, newest first in a table
+func printHistory(out io.Writer, output string, snapshots []history.Snapshot) error {
+	if printed, err := printStructured(out, output, snapshots); printed {
+		return err
+	}
+	if len(snapshots) == 0 {
+		_, err := fmt.Fprintln(out, "No generations recorded.")
+		return err
+	}
+
+	w := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "GENERATION\tRECORDED\tLABELS\tTAINTS")
+	for i := len(snapshots) - 1; i >= 0; i-- {
+		snapshot := snapshots[i]
+		labels := make([]string, 0, len(snapshot.Labels))
+		for key, value := range snapshot.Labels {
+			labels = append(labels, key+"="+value)
+		}
+		sort.Strings(labels)
+		taints := make([]string, 0, len(snapshot.Taints))
+		for _, taint := range snapshot.Taints {
+			taints = append(taints, taint.ToString())
+		}
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\n", snapshot.Generation, snapshot.Time.Format(time.RFC3339),
+			orDash(strings.Join(labels, ",")), orDash(strings.Join(taints, ",")))
+	}
+	return w.Flush()
+}
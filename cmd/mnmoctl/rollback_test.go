@@ -0,0 +1,69 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/openshift/managed-node-metadata-operator/pkg/history"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+var _ = Describe("rollback", func() {
+	var out *bytes.Buffer
+
+	snapshots := []history.Snapshot{
+		{Generation: 3, Time: time.Date(2026, 10, 1, 8, 0, 0, 0, time.UTC), Labels: map[string]string{"team": "payments", "tier": "prod"},
+			Taints: []corev1.Taint{{Key: "dedicated", Value: "infra", Effect: corev1.TaintEffectNoSchedule}}},
+		{Generation: 5, Time: time.Date(2026, 10, 18, 9, 30, 0, 0, time.UTC)},
+	}
+
+	BeforeEach(func() {
+		out = &bytes.Buffer{}
+	})
+
+	It("should list the generations newest first", func() {
+		Expect(printHistory(out, outputTable, snapshots)).To(Succeed())
+		lines := bytes.Split(bytes.TrimSpace(out.Bytes()), []byte("\n"))
+		Expect(lines).To(HaveLen(3))
+		Expect(string(lines[0])).To(MatchRegexp(`^GENERATION\s+RECORDED\s+LABELS\s+TAINTS$`))
+		Expect(string(lines[1])).To(MatchRegexp(`^5\s+2026-10-18T09:30:00Z\s+-\s+-$`))
+		Expect(string(lines[2])).To(MatchRegexp(`^3\s+2026-10-01T08:00:00Z\s+team=payments,tier=prod\s+dedicated=infra:NoSchedule$`))
+	})
+
+	It("should say when nothing is recorded", func() {
+		Expect(printHistory(out, outputTable, []history.Snapshot{})).To(Succeed())
+		Expect(out.String()).To(ContainSubstring("No generations recorded"))
+	})
+
+	It("should require the machineset", func() {
+		Expect(run(context.TODO(), []string{"rollback", "--to-generation", "3"}, out)).To(MatchError(ContainSubstring("--machineset")))
+	})
+
+	Describe("checkOperatorStopped", func() {
+		deployment := func(readyReplicas int32) *appsv1.Deployment {
+			return &appsv1.Deployment{
+				ObjectMeta: metav1.ObjectMeta{Namespace: history.DefaultNamespace, Name: operatorDeploymentName},
+				Status:     appsv1.DeploymentStatus{ReadyReplicas: readyReplicas},
+			}
+		}
+
+		It("should refuse while the operator has ready replicas", func() {
+			c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(deployment(1)).Build()
+			Expect(checkOperatorStopped(context.TODO(), c, history.DefaultNamespace)).To(MatchError(ContainSubstring("--force")))
+		})
+
+		It("should accept a scaled down or missing operator", func() {
+			c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(deployment(0)).Build()
+			Expect(checkOperatorStopped(context.TODO(), c, history.DefaultNamespace)).To(Succeed())
+			c = fake.NewClientBuilder().WithScheme(scheme).Build()
+			Expect(checkOperatorStopped(context.TODO(), c, history.DefaultNamespace)).To(Succeed())
+		})
+	})
+})
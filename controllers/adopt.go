@@ -0,0 +1,105 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+	"sort"
+	"strings"
+
+	m "github.com/openshift/managed-node-metadata-operator/pkg/machine"
+	"github.com/openshift/managed-node-metadata-operator/pkg/plan"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// Adoption is the labels of a node brought, or to be brought, under the ownership of the operator
+type Adoption struct {
+	MachineSet string   `json:"machineSet"`
+	Machine    string   `json:"machine"`
+	Node       string   `json:"node"`
+	Labels     []string `json:"labels"`
+}
+
+// Adopt records as set by the operator the labels of the nodes of the machinesets that were set by someone else to
+// the value of their template, so the next syncs manage them. Only the label keys given are adopted, if any, and
+// only the machinesets of the name, if not empty. The nodes aren't updated in a dry run.
+func Adopt(ctx context.Context, c client.Client, providers []m.Provider, namespace string, machineSetName string, keys []string, dryRun bool) ([]Adoption, error) {
+	plans, err := Diff(ctx, c, providers, namespace, machineSetName)
+	if err != nil {
+		return nil, err
+	}
+	adoptions := []Adoption{}
+	for _, machineSetPlan := range plans {
+		for _, machinePlan := range machineSetPlan.Machines {
+			adoptable := adoptableLabels(machinePlan, keys)
+			labels := slices.Sorted(maps.Keys(adoptable))
+			if !dryRun {
+				labels, err = adoptLabels(ctx, c, machinePlan.Node, adoptable)
+				if err != nil {
+					return nil, fmt.Errorf("failed to adopt the labels of node %s: %w", machinePlan.Node, err)
+				}
+			}
+			if len(labels) == 0 {
+				continue
+			}
+			adoptions = append(adoptions, Adoption{
+				MachineSet: machineSetPlan.Name,
+				Machine:    machinePlan.Name,
+				Node:       machinePlan.Node,
+				Labels:     labels,
+			})
+		}
+	}
+	return adoptions, nil
+}
+
+// adoptableLabels returns the template values of the adoptable labels of the plan, keyed by label, restricted to
+// the given keys if any
+func adoptableLabels(machinePlan plan.MachinePlan, keys []string) map[string]string {
+	labels := map[string]string{}
+	for _, op := range machinePlan.Adoptable() {
+		if len(keys) > 0 && !slices.Contains(keys, op.Key) {
+			continue
+		}
+		labels[op.Key] = *op.NewValue
+	}
+	return labels
+}
+
+// adoptLabels adds the labels that still have their template value on the node to its custom labels annotation,
+// and returns their sorted keys. A label changed since the plan is left to the next sync. The patch fails if the
+// node was changed in the meantime, so a concurrent sync isn't overwritten.
+func adoptLabels(ctx context.Context, c client.Client, nodeName string, labels map[string]string) ([]string, error) {
+	node := &corev1.Node{}
+	if err := c.Get(ctx, types.NamespacedName{Name: nodeName}, node); err != nil {
+		return nil, err
+	}
+	patch := client.MergeFromWithOptions(node.DeepCopy(), client.MergeFromWithOptimisticLock{})
+
+	var adopted []string
+	for key, value := range labels {
+		if current, ok := node.Labels[key]; ok && current == value {
+			adopted = append(adopted, key)
+		}
+	}
+	if len(adopted) == 0 {
+		return nil, nil
+	}
+	sort.Strings(adopted)
+
+	owned := append([]string{}, adopted...)
+	for _, key := range strings.Split(node.Annotations[plan.CustomLabelsAnnotation], ",") {
+		if key != "" && !slices.Contains(owned, key) {
+			owned = append(owned, key)
+		}
+	}
+	sort.Strings(owned)
+	if node.Annotations == nil {
+		node.Annotations = map[string]string{}
+	}
+	node.Annotations[plan.CustomLabelsAnnotation] = strings.Join(owned, ",")
+	return adopted, c.Patch(ctx, node, patch)
+}
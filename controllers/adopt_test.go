@@ -0,0 +1,117 @@
+package controllers
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	machinev1beta1 "github.com/openshift/api/machine/v1beta1"
+	m "github.com/openshift/managed-node-metadata-operator/pkg/machine"
+	"github.com/openshift/managed-node-metadata-operator/pkg/plan"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+var _ = Describe("Adopt", func() {
+	var (
+		fakeClient client.Client
+		node       *corev1.Node
+	)
+
+	s := newScheme()
+
+	getNode := func() *corev1.Node {
+		current := &corev1.Node{}
+		Expect(fakeClient.Get(context.TODO(), client.ObjectKeyFromObject(node), current)).To(Succeed())
+		return current
+	}
+
+	adopt := func(keys []string, dryRun bool) []Adoption {
+		adoptions, err := Adopt(context.TODO(), fakeClient, []m.Provider{m.MachineAPIProvider{}}, "test", "", keys, dryRun)
+		Expect(err).NotTo(HaveOccurred())
+		return adoptions
+	}
+
+	BeforeEach(func() {
+		machineSet := &machinev1beta1.MachineSet{
+			ObjectMeta: metav1.ObjectMeta{Name: "test-machineset", Namespace: "test"},
+			Spec: machinev1beta1.MachineSetSpec{
+				Selector: metav1.LabelSelector{MatchLabels: map[string]string{"pool": "test"}},
+				Template: machinev1beta1.MachineTemplateSpec{
+					ObjectMeta: machinev1beta1.ObjectMeta{Labels: map[string]string{"pool": "test"}},
+					Spec: machinev1beta1.MachineSpec{
+						ObjectMeta: machinev1beta1.ObjectMeta{
+							Labels: map[string]string{"foo": "bar", "team": "payments", "zone": "a"},
+						},
+					},
+				},
+			},
+		}
+		machine := &machinev1beta1.Machine{
+			ObjectMeta: metav1.ObjectMeta{Name: "test-machine", Namespace: "test", Labels: map[string]string{"pool": "test"}},
+			Status:     machinev1beta1.MachineStatus{NodeRef: &corev1.ObjectReference{Name: "test-node"}},
+		}
+		node = &corev1.Node{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        "test-node",
+				Labels:      map[string]string{"foo": "bar", "team": "platform", "zone": "a", "other": "value"},
+				Annotations: map[string]string{plan.CustomLabelsAnnotation: "other"},
+			},
+			Status: corev1.NodeStatus{Conditions: []corev1.NodeCondition{
+				{Type: corev1.NodeReady, Status: corev1.ConditionTrue},
+			}},
+		}
+		fakeClient = fake.NewClientBuilder().WithScheme(s).WithObjects(machineSet, machine, node).Build()
+	})
+
+	It("should only preview the labels set to the template value in a dry run", func() {
+		Expect(adopt(nil, true)).To(Equal([]Adoption{{
+			MachineSet: "test-machineset",
+			Machine:    "test-machine",
+			Node:       "test-node",
+			Labels:     []string{"foo", "zone"},
+		}}))
+		Expect(getNode().Annotations).To(HaveKeyWithValue(plan.CustomLabelsAnnotation, "other"))
+	})
+
+	It("should record the adopted labels next to the managed ones", func() {
+		Expect(adopt(nil, false)).To(HaveLen(1))
+		Expect(getNode().Annotations).To(HaveKeyWithValue(plan.CustomLabelsAnnotation, "foo,other,zone"))
+		Expect(adopt(nil, true)).To(BeEmpty())
+	})
+
+	It("should only adopt the given keys", func() {
+		adoptions := adopt([]string{"zone", "team"}, false)
+		Expect(adoptions).To(HaveLen(1))
+		Expect(adoptions[0].Labels).To(Equal([]string{"zone"}))
+		Expect(getNode().Annotations).To(HaveKeyWithValue(plan.CustomLabelsAnnotation, "other,zone"))
+	})
+
+	It("should let the next sync manage the adopted labels without changing them", func() {
+		adopt(nil, false)
+		r := &MachinesetReconciler{Client: fakeClient, Recorder: record.NewFakeRecorder(32)}
+		machineSet := &machinev1beta1.MachineSet{}
+		Expect(fakeClient.Get(context.TODO(), client.ObjectKey{Namespace: "test", Name: "test-machineset"}, machineSet)).To(Succeed())
+		_, err := r.ProcessMachineSet(context.TODO(), machineSet)
+		Expect(err).NotTo(HaveOccurred())
+
+		current := getNode()
+		Expect(current.Labels).To(Equal(map[string]string{"foo": "bar", "team": "platform", "zone": "a"}))
+		Expect(current.Annotations).To(HaveKeyWithValue(plan.CustomLabelsAnnotation, "foo,zone"))
+	})
+
+	It("should skip the labels changed since the plan", func() {
+		current := getNode()
+		current.Labels["zone"] = "b"
+		Expect(fakeClient.Update(context.TODO(), current)).To(Succeed())
+
+		adopted, err := adoptLabels(context.TODO(), fakeClient, "test-node", map[string]string{"foo": "bar", "zone": "a"})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(adopted).To(Equal([]string{"foo"}))
+		Expect(getNode().Annotations).To(HaveKeyWithValue(plan.CustomLabelsAnnotation, "foo,other"))
+	})
+})
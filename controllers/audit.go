@@ -0,0 +1,66 @@
+package controllers
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/openshift/managed-node-metadata-operator/pkg/audit"
+	"github.com/openshift/managed-node-metadata-operator/pkg/plan"
+	"k8s.io/klog/v2"
+)
+
+// auditContext returns a context carrying the machineset, machine and node the audit records are about
+func auditContext(ctx context.Context, machineSet string, generation int64, machine string, node string) context.Context {
+	return audit.WithRecord(ctx, audit.Record{
+		MachineSet: machineSet,
+		Generation: generation,
+		Machine:    machine,
+		Node:       node,
+	})
+}
+
+// auditChanges writes a record per label or taint added, changed or removed by the applied operations
+func (r *MachinesetReconciler) auditChanges(ctx context.Context, changes []plan.Operation) {
+	if r.Auditor == nil {
+		return
+	}
+	r.writeAudit(ctx, newAuditRecords(ctx, changes))
+}
+
+// newAuditRecords returns the records of the changes of the machine or node carried by the context
+func newAuditRecords(ctx context.Context, changes []plan.Operation) []audit.Record {
+	records := make([]audit.Record, 0, len(changes))
+	for _, change := range changes {
+		record := audit.FromContext(ctx)
+		record.Kind = change.Kind
+		record.Metadata = change.Metadata
+		record.Key = change.Key
+		record.Change = change.Change
+		record.OldValue = change.OldValue
+		record.NewValue = change.NewValue
+		record.Reason = audit.ReasonSetByMachineSet
+		if change.Change == plan.ChangeRemoved {
+			record.Reason = audit.ReasonRemovedFromMachineSet
+		}
+		records = append(records, record)
+	}
+	return records
+}
+
+// writeAudit timestamps and writes the records, sorted by key. A failing sink never fails the sync.
+func (r *MachinesetReconciler) writeAudit(ctx context.Context, records []audit.Record) {
+	if len(records) == 0 {
+		return
+	}
+	sort.Slice(records, func(i, j int) bool {
+		return records[i].Key < records[j].Key
+	})
+	now := time.Now().UTC()
+	for i := range records {
+		records[i].Timestamp = now
+	}
+	if err := r.Auditor.Write(ctx, records); err != nil {
+		klog.Errorf("failed to write audit records of machineset %s: %v", records[0].MachineSet, err)
+	}
+}
@@ -0,0 +1,141 @@
+package controllers
+
+import (
+	"context"
+	"sync"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	machinev1beta1 "github.com/openshift/api/machine/v1beta1"
+	"github.com/openshift/managed-node-metadata-operator/pkg/audit"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+// memorySink keeps the audit records in memory
+type memorySink struct {
+	mu      sync.Mutex
+	records []audit.Record
+}
+
+func (s *memorySink) Write(_ context.Context, records []audit.Record) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.records = append(s.records, records...)
+	return nil
+}
+
+var _ = Describe("Audit of metadata changes", func() {
+	var (
+		sink       *memorySink
+		r          *MachinesetReconciler
+		machineSet *machinev1beta1.MachineSet
+	)
+
+	s := newScheme()
+
+	BeforeEach(func() {
+		machineSet = &machinev1beta1.MachineSet{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:       "test-machineset",
+				Namespace:  "test",
+				Generation: 3,
+			},
+			Spec: machinev1beta1.MachineSetSpec{
+				Selector: metav1.LabelSelector{
+					MatchLabels: map[string]string{"pool": "test"},
+				},
+				Template: machinev1beta1.MachineTemplateSpec{
+					ObjectMeta: machinev1beta1.ObjectMeta{
+						Labels: map[string]string{"pool": "test"},
+					},
+					Spec: machinev1beta1.MachineSpec{
+						ObjectMeta: machinev1beta1.ObjectMeta{
+							Labels: map[string]string{"foo": "bar"},
+						},
+					},
+				},
+			},
+		}
+		machine := &machinev1beta1.Machine{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "test-machine",
+				Namespace: "test",
+				Labels:    map[string]string{"pool": "test"},
+			},
+			Spec: machinev1beta1.MachineSpec{
+				ObjectMeta: machinev1beta1.ObjectMeta{
+					Labels: map[string]string{"foo": "old"},
+				},
+				Taints: []corev1.Taint{{Key: "dedicated", Value: "infra", Effect: corev1.TaintEffectNoSchedule}},
+			},
+			Status: machinev1beta1.MachineStatus{
+				NodeRef: &corev1.ObjectReference{Name: "test-node"},
+			},
+		}
+		node := &corev1.Node{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        "test-node",
+				Labels:      map[string]string{"foo": "old"},
+				Annotations: map[string]string{"managed.openshift.com/customlabels": "foo"},
+			},
+			Status: corev1.NodeStatus{Conditions: []corev1.NodeCondition{
+				{Type: corev1.NodeReady, Status: corev1.ConditionTrue},
+			}},
+		}
+		sink = &memorySink{}
+		r = &MachinesetReconciler{
+			Client:   fake.NewClientBuilder().WithScheme(s).WithObjects(machineSet, machine, node).Build(),
+			Recorder: record.NewFakeRecorder(32),
+			Auditor:  sink,
+		}
+	})
+
+	It("should write a record per changed label and taint", func() {
+		_, err := r.ProcessMachineSet(context.TODO(), machineSet)
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(sink.records).To(HaveLen(3))
+		for _, record := range sink.records {
+			Expect(record.MachineSet).To(Equal("test-machineset"))
+			Expect(record.Generation).To(Equal(int64(3)))
+			Expect(record.Machine).To(Equal("test-machine"))
+			Expect(record.Node).To(Equal("test-node"))
+			Expect(record.Timestamp.IsZero()).To(BeFalse())
+		}
+
+		machineLabel := sink.records[0]
+		Expect(machineLabel.Kind).To(Equal("Machine"))
+		Expect(machineLabel.Key).To(Equal("foo"))
+		Expect(machineLabel.Change).To(Equal("changed"))
+		Expect(*machineLabel.OldValue).To(Equal("old"))
+		Expect(*machineLabel.NewValue).To(Equal("bar"))
+		Expect(machineLabel.Reason).To(Equal(audit.ReasonSetByMachineSet))
+
+		machineTaint := sink.records[1]
+		Expect(machineTaint.Metadata).To(Equal("taint"))
+		Expect(machineTaint.Key).To(Equal("dedicated:NoSchedule"))
+		Expect(machineTaint.Change).To(Equal("removed"))
+		Expect(*machineTaint.OldValue).To(Equal("infra"))
+		Expect(machineTaint.NewValue).To(BeNil())
+		Expect(machineTaint.Reason).To(Equal(audit.ReasonRemovedFromMachineSet))
+
+		nodeLabel := sink.records[2]
+		Expect(nodeLabel.Kind).To(Equal("Node"))
+		Expect(nodeLabel.Key).To(Equal("foo"))
+		Expect(nodeLabel.Change).To(Equal("changed"))
+	})
+
+	It("should not write records when nothing changes", func() {
+		_, err := r.ProcessMachineSet(context.TODO(), machineSet)
+		Expect(err).NotTo(HaveOccurred())
+		sink.records = nil
+
+		_, err = r.ProcessMachineSet(context.TODO(), machineSet)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(sink.records).To(BeEmpty())
+	})
+})
@@ -0,0 +1,136 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"sort"
+	"strings"
+
+	"github.com/openshift/managed-node-metadata-operator/pkg/plan"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// autoscalerLabelsAnnotation lists the labels the cluster-autoscaler expects on nodes of a machineset scaled to zero
+	autoscalerLabelsAnnotation = "capacity.cluster-autoscaler.kubernetes.io/labels"
+	// autoscalerTaintsAnnotation lists the taints the cluster-autoscaler expects on nodes of a machineset scaled to zero
+	autoscalerTaintsAnnotation = "capacity.cluster-autoscaler.kubernetes.io/taints"
+	// managedAutoscalerLabelsAnnotation records the keys the operator set in the autoscaler labels annotation
+	managedAutoscalerLabelsAnnotation = "managed.openshift.com/capacity-labels"
+	// managedAutoscalerTaintsAnnotation records the keys the operator set in the autoscaler taints annotation
+	managedAutoscalerTaintsAnnotation = "managed.openshift.com/capacity-taints"
+)
+
+// updateAutoscalerAnnotations keeps the scale-from-zero annotations of the machineset in step with the template
+// labels and taints. Entries of the annotations that weren't set by the operator are left alone.
+func (r *MachinesetReconciler) updateAutoscalerAnnotations(ctx context.Context, machineSet client.Object) error {
+	desiredLabels := map[string]string{}
+	for k, v := range r.provider().DesiredLabels(machineSet) {
+		desiredLabels[k] = k + "=" + v
+	}
+	desiredTaints := map[string]string{}
+	uniqueTaints, _ := plan.CheckDuplicateTaints(r.provider().DesiredTaints(machineSet))
+	for _, taint := range uniqueTaints {
+		desiredTaints[taint.Key+":"+string(taint.Effect)] = fmt.Sprintf("%s=%s:%s", taint.Key, taint.Value, taint.Effect)
+	}
+
+	annotations := map[string]string{}
+	for k, v := range machineSet.GetAnnotations() {
+		annotations[k] = v
+	}
+	labels, managedLabels := mergeOwnedEntries(annotations[autoscalerLabelsAnnotation], annotations[managedAutoscalerLabelsAnnotation], desiredLabels, labelEntryKey)
+	taints, managedTaints := mergeOwnedEntries(annotations[autoscalerTaintsAnnotation], annotations[managedAutoscalerTaintsAnnotation], desiredTaints, taintEntryKey)
+	setOrDeleteAnnotation(annotations, autoscalerLabelsAnnotation, labels)
+	setOrDeleteAnnotation(annotations, managedAutoscalerLabelsAnnotation, managedLabels)
+	setOrDeleteAnnotation(annotations, autoscalerTaintsAnnotation, taints)
+	setOrDeleteAnnotation(annotations, managedAutoscalerTaintsAnnotation, managedTaints)
+
+	if maps.Equal(annotations, machineSet.GetAnnotations()) {
+		return nil
+	}
+
+	patch := client.MergeFrom(machineSet.DeepCopyObject().(client.Object))
+	machineSet.SetAnnotations(annotations)
+	if err := r.Patch(ctx, machineSet, patch); err != nil {
+		return fmt.Errorf("failed to update autoscaler annotations of machineset %s: %w", machineSet.GetName(), err)
+	}
+	return nil
+}
+
+// mergeOwnedEntries merges the desired entries into a comma separated annotation value. Entries are identified by
+// the key returned by entryKey. Entries whose key is listed in owned are updated or removed, other entries are kept
+// unchanged. New entries are appended in key order. It returns the new annotation value and the new owned keys.
+func mergeOwnedEntries(current string, owned string, desired map[string]string, entryKey func(string) string) (string, string) {
+	ownedKeys := map[string]bool{}
+	for _, k := range splitList(owned) {
+		ownedKeys[k] = true
+	}
+
+	result := []string{}
+	newOwned := []string{}
+	seen := map[string]bool{}
+	for _, entry := range splitList(current) {
+		key := entryKey(entry)
+		if !ownedKeys[key] {
+			// Not set by the operator, leave it alone
+			result = append(result, entry)
+			seen[key] = true
+			continue
+		}
+		if desiredEntry, ok := desired[key]; ok && !seen[key] {
+			result = append(result, desiredEntry)
+			newOwned = append(newOwned, key)
+			seen[key] = true
+		}
+	}
+
+	keys := make([]string, 0, len(desired))
+	for k := range desired {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		if seen[k] {
+			continue
+		}
+		result = append(result, desired[k])
+		newOwned = append(newOwned, k)
+	}
+
+	return strings.Join(result, ","), strings.Join(newOwned, ",")
+}
+
+// labelEntryKey returns the key of a "key=value" entry
+func labelEntryKey(entry string) string {
+	key, _, _ := strings.Cut(entry, "=")
+	return key
+}
+
+// taintEntryKey returns the "key:effect" identity of a "key=value:effect" entry
+func taintEntryKey(entry string) string {
+	keyValue, effect := entry, ""
+	if i := strings.LastIndex(entry, ":"); i >= 0 {
+		keyValue, effect = entry[:i], entry[i+1:]
+	}
+	key, _, _ := strings.Cut(keyValue, "=")
+	return key + ":" + effect
+}
+
+func splitList(value string) []string {
+	items := []string{}
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+func setOrDeleteAnnotation(annotations map[string]string, key string, value string) {
+	if value == "" {
+		delete(annotations, key)
+		return
+	}
+	annotations[key] = value
+}
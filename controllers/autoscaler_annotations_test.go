@@ -0,0 +1,144 @@
+package controllers
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	machinev1beta1 "github.com/openshift/api/machine/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+var _ = Describe("Autoscaler annotations", func() {
+	var (
+		machineSet  *machinev1beta1.MachineSet
+		annotations map[string]string
+		fakeClient  client.Client
+		r           *MachinesetReconciler
+	)
+
+	s := newScheme()
+
+	BeforeEach(func() {
+		annotations = nil
+	})
+
+	JustBeforeEach(func() {
+		machineSet = &machinev1beta1.MachineSet{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        "test-machineset",
+				Namespace:   "test",
+				Annotations: annotations,
+			},
+			Spec: machinev1beta1.MachineSetSpec{
+				Template: machinev1beta1.MachineTemplateSpec{
+					Spec: machinev1beta1.MachineSpec{
+						ObjectMeta: machinev1beta1.ObjectMeta{
+							Labels: map[string]string{"foo": "bar", "baz": "qux"},
+						},
+						Taints: []corev1.Taint{
+							{Key: "foo", Value: "bar", Effect: corev1.TaintEffectNoSchedule},
+						},
+					},
+				},
+			},
+		}
+		fakeClient = fake.NewClientBuilder().WithScheme(s).WithObjects(machineSet).Build()
+		r = &MachinesetReconciler{
+			Client:   fakeClient,
+			Scheme:   scheme.Scheme,
+			Recorder: record.NewFakeRecorder(32),
+		}
+	})
+
+	getAnnotations := func() map[string]string {
+		updated := &machinev1beta1.MachineSet{}
+		err := fakeClient.Get(context.TODO(), types.NamespacedName{Name: "test-machineset", Namespace: "test"}, updated)
+		Expect(err).NotTo(HaveOccurred())
+		return updated.Annotations
+	}
+
+	Context("When the machineset has no autoscaler annotations", func() {
+		It("should set them from the template", func() {
+			Expect(r.updateAutoscalerAnnotations(context.TODO(), machineSet)).To(Succeed())
+
+			result := getAnnotations()
+			Expect(result).To(HaveKeyWithValue(autoscalerLabelsAnnotation, "baz=qux,foo=bar"))
+			Expect(result).To(HaveKeyWithValue(managedAutoscalerLabelsAnnotation, "baz,foo"))
+			Expect(result).To(HaveKeyWithValue(autoscalerTaintsAnnotation, "foo=bar:NoSchedule"))
+			Expect(result).To(HaveKeyWithValue(managedAutoscalerTaintsAnnotation, "foo:NoSchedule"))
+		})
+	})
+
+	Context("When the autoscaler annotations have entries set by someone else", func() {
+		BeforeEach(func() {
+			annotations = map[string]string{
+				autoscalerLabelsAnnotation:        "kubernetes.io/arch=amd64,foo=old,removed=true",
+				managedAutoscalerLabelsAnnotation: "foo,removed",
+				autoscalerTaintsAnnotation:        "gpu=true:NoSchedule",
+			}
+		})
+
+		It("should update the owned entries and leave the others alone", func() {
+			Expect(r.updateAutoscalerAnnotations(context.TODO(), machineSet)).To(Succeed())
+
+			result := getAnnotations()
+			Expect(result).To(HaveKeyWithValue(autoscalerLabelsAnnotation, "kubernetes.io/arch=amd64,foo=bar,baz=qux"))
+			Expect(result).To(HaveKeyWithValue(managedAutoscalerLabelsAnnotation, "foo,baz"))
+			Expect(result).To(HaveKeyWithValue(autoscalerTaintsAnnotation, "gpu=true:NoSchedule,foo=bar:NoSchedule"))
+			Expect(result).To(HaveKeyWithValue(managedAutoscalerTaintsAnnotation, "foo:NoSchedule"))
+		})
+	})
+
+	Context("When the annotations are already in step with the template", func() {
+		BeforeEach(func() {
+			annotations = map[string]string{
+				autoscalerLabelsAnnotation:        "baz=qux,foo=bar",
+				managedAutoscalerLabelsAnnotation: "baz,foo",
+				autoscalerTaintsAnnotation:        "foo=bar:NoSchedule",
+				managedAutoscalerTaintsAnnotation: "foo:NoSchedule",
+			}
+		})
+
+		It("should not update the machineset", func() {
+			before := &machinev1beta1.MachineSet{}
+			err := fakeClient.Get(context.TODO(), types.NamespacedName{Name: "test-machineset", Namespace: "test"}, before)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(r.updateAutoscalerAnnotations(context.TODO(), machineSet)).To(Succeed())
+
+			after := &machinev1beta1.MachineSet{}
+			err = fakeClient.Get(context.TODO(), types.NamespacedName{Name: "test-machineset", Namespace: "test"}, after)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(after.ResourceVersion).To(Equal(before.ResourceVersion))
+		})
+	})
+
+	Describe("mergeOwnedEntries", func() {
+		It("should remove owned entries that are no longer desired", func() {
+			value, owned := mergeOwnedEntries("a=1,b=2", "a,b", map[string]string{"b": "b=2"}, labelEntryKey)
+			Expect(value).To(Equal("b=2"))
+			Expect(owned).To(Equal("b"))
+		})
+
+		It("should not take over entries it doesn't own", func() {
+			value, owned := mergeOwnedEntries("a=1", "", map[string]string{"a": "a=2"}, labelEntryKey)
+			Expect(value).To(Equal("a=1"))
+			Expect(owned).To(Equal(""))
+		})
+	})
+
+	Describe("taintEntryKey", func() {
+		It("should return the key and effect of the taint", func() {
+			Expect(taintEntryKey("foo=bar:NoSchedule")).To(Equal("foo:NoSchedule"))
+			Expect(taintEntryKey("foo:NoExecute")).To(Equal("foo:NoExecute"))
+		})
+	})
+})
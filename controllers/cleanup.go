@@ -0,0 +1,278 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"os"
+	"sort"
+	"time"
+
+	machinev1beta1 "github.com/openshift/api/machine/v1beta1"
+	m "github.com/openshift/managed-node-metadata-operator/pkg/machine"
+	"github.com/openshift/managed-node-metadata-operator/pkg/plan"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// CleanupPolicyKeep leaves the labels and taints the operator applied in place, only its ownership
+	// annotations are removed
+	CleanupPolicyKeep = "keep"
+	// CleanupPolicyRemove removes the labels and taints the operator applied, and its ownership annotations
+	CleanupPolicyRemove = "remove"
+
+	kindMachineSet = "MachineSet"
+)
+
+// ownershipAnnotations are the machineset annotations the operator keeps its state in
+var ownershipAnnotations = []string{
+	managedAutoscalerLabelsAnnotation,
+	managedAutoscalerTaintsAnnotation,
+	syncGenerationAnnotation,
+	syncStartedAnnotation,
+	syncedGenerationAnnotation,
+}
+
+// CleanupReport is what a cleanup did, or would do in a dry run
+type CleanupReport struct {
+	Policy  string          `json:"policy"`
+	DryRun  bool            `json:"dryRun"`
+	Time    time.Time       `json:"time"`
+	Actions []CleanupAction `json:"actions"`
+}
+
+// CleanupAction is what a cleanup did to a node, a machine or a machineset. The labels and taints of a machineset are
+// the entries of its cluster-autoscaler capacity annotations. Taints are identified by their key and effect.
+type CleanupAction struct {
+	Kind               string   `json:"kind"`
+	Namespace          string   `json:"namespace,omitempty"`
+	Name               string   `json:"name"`
+	MachineSet         string   `json:"machineSet,omitempty"`
+	RemovedLabels      []string `json:"removedLabels,omitempty"`
+	RemovedTaints      []string `json:"removedTaints,omitempty"`
+	KeptLabels         []string `json:"keptLabels,omitempty"`
+	KeptTaints         []string `json:"keptTaints,omitempty"`
+	RemovedAnnotations []string `json:"removedAnnotations,omitempty"`
+	Error              string   `json:"error,omitempty"`
+}
+
+// Cleanup removes the ownership annotations the operator set on the nodes and the machinesets of the providers in
+// the namespace, and the labels and taints it applied too with the remove policy. They're then removed from the
+// specs of the Machine API machines as well, since nodelink copies the labels and taints of the machine specs to
+// their nodes. Nothing is updated in a dry run. The operator must be
+// stopped first, or it applies everything again. An object that fails to be cleaned up doesn't stop the cleanup of
+// the others, it's recorded in the report and fails the cleanup at the end.
+func Cleanup(ctx context.Context, c client.Client, providers []m.Provider, namespace string, policy string, dryRun bool) (*CleanupReport, error) {
+	if policy != CleanupPolicyKeep && policy != CleanupPolicyRemove {
+		return nil, fmt.Errorf("unknown cleanup policy %q, expected %s or %s", policy, CleanupPolicyKeep, CleanupPolicyRemove)
+	}
+	report := &CleanupReport{Policy: policy, DryRun: dryRun, Time: time.Now().UTC(), Actions: []CleanupAction{}}
+
+	// the node taints the operator applied are the ones of the template of the machineset of the node
+	nodeMachineSets := map[string]string{}
+	nodeTaints := map[string][]corev1.Taint{}
+	nodeMachines := map[string]*machinev1beta1.Machine{}
+	for _, provider := range providers {
+		machineSets := provider.NewMachineSetList()
+		if err := c.List(ctx, machineSets, client.InNamespace(namespace)); err != nil {
+			return nil, err
+		}
+		items, err := meta.ExtractList(machineSets)
+		if err != nil {
+			return nil, err
+		}
+		for _, item := range items {
+			machineSet, ok := item.(client.Object)
+			if !ok {
+				continue
+			}
+			machines, err := provider.GetMachines(ctx, c, machineSet)
+			if err != nil {
+				return nil, err
+			}
+			for _, machine := range machines {
+				if nodeName := m.GetNodeName(machine); nodeName != "" {
+					nodeMachineSets[nodeName] = machineSet.GetName()
+					nodeTaints[nodeName] = provider.DesiredTaints(machineSet)
+					if mapiMachine, ok := machine.(*machinev1beta1.Machine); ok {
+						nodeMachines[nodeName] = mapiMachine
+					}
+				}
+			}
+			if action := cleanupMachineSet(ctx, c, machineSet, policy, dryRun); action != nil {
+				report.Actions = append(report.Actions, *action)
+			}
+		}
+	}
+
+	nodes := &corev1.NodeList{}
+	if err := c.List(ctx, nodes); err != nil {
+		return nil, err
+	}
+	for i := range nodes.Items {
+		node := &nodes.Items[i]
+		// The machine is cleaned up first, so nodelink doesn't copy the labels and taints back to the node
+		if machine := nodeMachines[node.Name]; machine != nil && policy == CleanupPolicyRemove {
+			ownedLabels := splitList(node.Annotations[plan.CustomLabelsAnnotation])
+			if action := cleanupMachine(ctx, c, machine, nodeMachineSets[node.Name], ownedLabels, nodeTaints[node.Name], dryRun); action != nil {
+				report.Actions = append(report.Actions, *action)
+			}
+		}
+		if action := cleanupNode(ctx, c, node, nodeMachineSets[node.Name], nodeTaints[node.Name], policy, dryRun); action != nil {
+			report.Actions = append(report.Actions, *action)
+		}
+	}
+
+	failed := 0
+	for _, action := range report.Actions {
+		if action.Error != "" {
+			failed++
+		}
+	}
+	if failed > 0 {
+		return report, fmt.Errorf("failed to clean up %d objects, see the report", failed)
+	}
+	return report, nil
+}
+
+// cleanupMachineSet removes the ownership annotations of the machineset, and with the remove policy the entries the
+// operator set in its cluster-autoscaler capacity annotations. It returns nil if there is nothing to clean up.
+func cleanupMachineSet(ctx context.Context, c client.Client, machineSet client.Object, policy string, dryRun bool) *CleanupAction {
+	annotations := maps.Clone(machineSet.GetAnnotations())
+	action := &CleanupAction{Kind: kindMachineSet, Namespace: machineSet.GetNamespace(), Name: machineSet.GetName()}
+	ownedLabels := splitList(annotations[managedAutoscalerLabelsAnnotation])
+	ownedTaints := splitList(annotations[managedAutoscalerTaintsAnnotation])
+	if policy == CleanupPolicyRemove {
+		labels, _ := mergeOwnedEntries(annotations[autoscalerLabelsAnnotation], annotations[managedAutoscalerLabelsAnnotation], nil, labelEntryKey)
+		taints, _ := mergeOwnedEntries(annotations[autoscalerTaintsAnnotation], annotations[managedAutoscalerTaintsAnnotation], nil, taintEntryKey)
+		setOrDeleteAnnotation(annotations, autoscalerLabelsAnnotation, labels)
+		setOrDeleteAnnotation(annotations, autoscalerTaintsAnnotation, taints)
+		action.RemovedLabels, action.RemovedTaints = ownedLabels, ownedTaints
+	} else {
+		action.KeptLabels, action.KeptTaints = ownedLabels, ownedTaints
+	}
+	for _, key := range ownershipAnnotations {
+		if _, ok := annotations[key]; ok {
+			delete(annotations, key)
+			action.RemovedAnnotations = append(action.RemovedAnnotations, key)
+		}
+	}
+	if len(action.RemovedAnnotations) == 0 {
+		return nil
+	}
+
+	if !dryRun {
+		patch := client.MergeFromWithOptions(machineSet.DeepCopyObject().(client.Object), client.MergeFromWithOptimisticLock{})
+		machineSet.SetAnnotations(annotations)
+		if err := c.Patch(ctx, machineSet, patch); err != nil {
+			action.Error = err.Error()
+		}
+	}
+	return action
+}
+
+// cleanupMachine removes from the spec of the Machine API machine the labels the operator owns on its node and the
+// taints of the template of its machineset. It returns nil if there is nothing to clean up.
+func cleanupMachine(ctx context.Context, c client.Client, machine *machinev1beta1.Machine, machineSet string, ownedLabels []string, templateTaints []corev1.Taint, dryRun bool) *CleanupAction {
+	var labels []string
+	for _, key := range ownedLabels {
+		if _, ok := machine.Spec.Labels[key]; ok {
+			labels = append(labels, key)
+		}
+	}
+	sort.Strings(labels)
+	var taints []string
+	var keptTaints []corev1.Taint
+	for i := range machine.Spec.Taints {
+		if plan.TaintExists(templateTaints, &machine.Spec.Taints[i]) {
+			taints = append(taints, plan.TaintKey(&machine.Spec.Taints[i]))
+		} else {
+			keptTaints = append(keptTaints, machine.Spec.Taints[i])
+		}
+	}
+	if len(labels) == 0 && len(taints) == 0 {
+		return nil
+	}
+
+	action := &CleanupAction{Kind: plan.KindMachine, Namespace: machine.Namespace, Name: machine.Name, MachineSet: machineSet,
+		RemovedLabels: labels, RemovedTaints: taints}
+	if !dryRun {
+		patch := client.MergeFromWithOptions(machine.DeepCopy(), client.MergeFromWithOptimisticLock{})
+		for _, key := range labels {
+			delete(machine.Spec.Labels, key)
+		}
+		machine.Spec.Taints = keptTaints
+		if err := c.Patch(ctx, machine, patch); err != nil {
+			action.Error = err.Error()
+		}
+	}
+	return action
+}
+
+// cleanupNode removes the custom labels annotation of the node, and with the remove policy the labels it lists and
+// the taints of the template of the machineset of the node. It returns nil if there is nothing to clean up.
+func cleanupNode(ctx context.Context, c client.Client, node *corev1.Node, machineSet string, templateTaints []corev1.Taint, policy string, dryRun bool) *CleanupAction {
+	annotation, annotated := node.Annotations[plan.CustomLabelsAnnotation]
+	var labels []string
+	for _, key := range splitList(annotation) {
+		if _, ok := node.Labels[key]; ok {
+			labels = append(labels, key)
+		}
+	}
+	sort.Strings(labels)
+	var taints []string
+	var keptTaints []corev1.Taint
+	for i := range node.Spec.Taints {
+		if plan.TaintExists(templateTaints, &node.Spec.Taints[i]) {
+			taints = append(taints, plan.TaintKey(&node.Spec.Taints[i]))
+		} else {
+			keptTaints = append(keptTaints, node.Spec.Taints[i])
+		}
+	}
+	if !annotated && len(taints) == 0 {
+		return nil
+	}
+
+	action := &CleanupAction{Kind: plan.KindNode, Name: node.Name, MachineSet: machineSet}
+	patch := client.MergeFromWithOptions(node.DeepCopy(), client.MergeFromWithOptimisticLock{})
+	if annotated {
+		delete(node.Annotations, plan.CustomLabelsAnnotation)
+		action.RemovedAnnotations = []string{plan.CustomLabelsAnnotation}
+	}
+	if policy == CleanupPolicyRemove {
+		for _, key := range labels {
+			delete(node.Labels, key)
+		}
+		node.Spec.Taints = keptTaints
+		action.RemovedLabels, action.RemovedTaints = labels, taints
+	} else {
+		action.KeptLabels, action.KeptTaints = labels, taints
+	}
+	if !annotated && policy == CleanupPolicyKeep {
+		return action
+	}
+
+	if !dryRun {
+		if err := c.Patch(ctx, node, patch); err != nil {
+			action.Error = err.Error()
+		}
+	}
+	return action
+}
+
+// WriteCleanupReport writes the report as JSON to the file of the path, or to the standard output if it's empty
+func WriteCleanupReport(report *CleanupReport, path string) error {
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+	if path == "" {
+		_, err = os.Stdout.Write(data)
+		return err
+	}
+	return os.WriteFile(path, data, 0o644)
+}
@@ -0,0 +1,202 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	machinev1beta1 "github.com/openshift/api/machine/v1beta1"
+	m "github.com/openshift/managed-node-metadata-operator/pkg/machine"
+	"github.com/openshift/managed-node-metadata-operator/pkg/plan"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+var _ = Describe("Cleanup", func() {
+	var (
+		fakeClient client.Client
+		machineSet *machinev1beta1.MachineSet
+		machine    *machinev1beta1.Machine
+		node       *corev1.Node
+	)
+
+	s := newScheme()
+
+	cleanup := func(policy string, dryRun bool) *CleanupReport {
+		report, err := Cleanup(context.TODO(), fakeClient, []m.Provider{m.MachineAPIProvider{}}, "test", policy, dryRun)
+		Expect(err).NotTo(HaveOccurred())
+		return report
+	}
+
+	getNode := func() *corev1.Node {
+		current := &corev1.Node{}
+		Expect(fakeClient.Get(context.TODO(), client.ObjectKeyFromObject(node), current)).To(Succeed())
+		return current
+	}
+
+	getMachine := func() *machinev1beta1.Machine {
+		current := &machinev1beta1.Machine{}
+		Expect(fakeClient.Get(context.TODO(), client.ObjectKeyFromObject(machine), current)).To(Succeed())
+		return current
+	}
+
+	getMachineSet := func() *machinev1beta1.MachineSet {
+		current := &machinev1beta1.MachineSet{}
+		Expect(fakeClient.Get(context.TODO(), client.ObjectKeyFromObject(machineSet), current)).To(Succeed())
+		return current
+	}
+
+	BeforeEach(func() {
+		machineSet = &machinev1beta1.MachineSet{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "test-machineset",
+				Namespace: "test",
+				Annotations: map[string]string{
+					autoscalerLabelsAnnotation:        "foo=bar,kubernetes.io/arch=amd64",
+					managedAutoscalerLabelsAnnotation: "foo",
+					autoscalerTaintsAnnotation:        "dedicated=infra:NoSchedule",
+					managedAutoscalerTaintsAnnotation: "dedicated:NoSchedule",
+					syncGenerationAnnotation:          "2",
+					syncStartedAnnotation:             "2026-10-18T10:00:00Z",
+					syncedGenerationAnnotation:        "2",
+					"other":                           "value",
+				},
+			},
+			Spec: machinev1beta1.MachineSetSpec{
+				Selector: metav1.LabelSelector{MatchLabels: map[string]string{"pool": "test"}},
+				Template: machinev1beta1.MachineTemplateSpec{
+					ObjectMeta: machinev1beta1.ObjectMeta{Labels: map[string]string{"pool": "test"}},
+					Spec: machinev1beta1.MachineSpec{
+						ObjectMeta: machinev1beta1.ObjectMeta{Labels: map[string]string{"foo": "bar"}},
+						Taints:     []corev1.Taint{{Key: "dedicated", Value: "infra", Effect: corev1.TaintEffectNoSchedule}},
+					},
+				},
+			},
+		}
+		machine = &machinev1beta1.Machine{
+			ObjectMeta: metav1.ObjectMeta{Name: "test-machine", Namespace: "test", Labels: map[string]string{"pool": "test"}},
+			Spec: machinev1beta1.MachineSpec{
+				ObjectMeta: machinev1beta1.ObjectMeta{Labels: map[string]string{"foo": "bar", "hand-set": "value"}},
+				Taints:     []corev1.Taint{{Key: "dedicated", Value: "infra", Effect: corev1.TaintEffectNoSchedule}},
+			},
+			Status: machinev1beta1.MachineStatus{NodeRef: &corev1.ObjectReference{Name: "test-node"}},
+		}
+		node = &corev1.Node{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        "test-node",
+				Labels:      map[string]string{"foo": "bar", "kubernetes.io/hostname": "test-node"},
+				Annotations: map[string]string{plan.CustomLabelsAnnotation: "foo"},
+			},
+			Spec: corev1.NodeSpec{Taints: []corev1.Taint{
+				{Key: "dedicated", Value: "infra", Effect: corev1.TaintEffectNoSchedule},
+				{Key: corev1.TaintNodeUnschedulable, Effect: corev1.TaintEffectNoSchedule},
+			}},
+		}
+		fakeClient = fake.NewClientBuilder().WithScheme(s).WithObjects(machineSet, machine, node).Build()
+	})
+
+	It("should only strip the ownership annotations with the keep policy", func() {
+		report := cleanup(CleanupPolicyKeep, false)
+		Expect(report.Actions).To(HaveLen(2))
+
+		current := getNode()
+		Expect(current.Annotations).NotTo(HaveKey(plan.CustomLabelsAnnotation))
+		Expect(current.Labels).To(HaveKeyWithValue("foo", "bar"))
+		Expect(current.Spec.Taints).To(HaveLen(2))
+		Expect(getMachine().Spec).To(Equal(machine.Spec))
+
+		Expect(getMachineSet().Annotations).To(Equal(map[string]string{
+			autoscalerLabelsAnnotation: "foo=bar,kubernetes.io/arch=amd64",
+			autoscalerTaintsAnnotation: "dedicated=infra:NoSchedule",
+			"other":                    "value",
+		}))
+	})
+
+	It("should remove the labels and taints the operator applied with the remove policy", func() {
+		report := cleanup(CleanupPolicyRemove, false)
+		Expect(report.Actions).To(ContainElement(CleanupAction{
+			Kind:               plan.KindNode,
+			Name:               "test-node",
+			MachineSet:         "test-machineset",
+			RemovedLabels:      []string{"foo"},
+			RemovedTaints:      []string{"dedicated:NoSchedule"},
+			RemovedAnnotations: []string{plan.CustomLabelsAnnotation},
+		}))
+		Expect(report.Actions).To(ContainElement(CleanupAction{
+			Kind:          plan.KindMachine,
+			Namespace:     "test",
+			Name:          "test-machine",
+			MachineSet:    "test-machineset",
+			RemovedLabels: []string{"foo"},
+			RemovedTaints: []string{"dedicated:NoSchedule"},
+		}))
+
+		current := getNode()
+		Expect(current.Annotations).NotTo(HaveKey(plan.CustomLabelsAnnotation))
+		Expect(current.Labels).To(Equal(map[string]string{"kubernetes.io/hostname": "test-node"}))
+		Expect(current.Spec.Taints).To(Equal([]corev1.Taint{{Key: corev1.TaintNodeUnschedulable, Effect: corev1.TaintEffectNoSchedule}}))
+		Expect(getMachine().Spec.Labels).To(Equal(map[string]string{"hand-set": "value"}))
+		Expect(getMachine().Spec.Taints).To(BeEmpty())
+
+		Expect(getMachineSet().Annotations).To(Equal(map[string]string{
+			autoscalerLabelsAnnotation: "kubernetes.io/arch=amd64",
+			"other":                    "value",
+		}))
+	})
+
+	It("should keep the labels and taints removed through a resync of nodelink", func() {
+		cleanup(CleanupPolicyRemove, false)
+
+		// nodelink copies the labels and taints of the machine spec to the node
+		current := getNode()
+		for key, value := range getMachine().Spec.Labels {
+			current.Labels[key] = value
+		}
+		for _, taint := range getMachine().Spec.Taints {
+			if !plan.TaintExists(current.Spec.Taints, &taint) {
+				current.Spec.Taints = append(current.Spec.Taints, taint)
+			}
+		}
+		Expect(fakeClient.Update(context.TODO(), current)).To(Succeed())
+
+		current = getNode()
+		Expect(current.Labels).NotTo(HaveKey("foo"))
+		Expect(current.Spec.Taints).NotTo(ContainElement(HaveField("Key", "dedicated")))
+	})
+
+	It("should only report what would be cleaned up in a dry run", func() {
+		report := cleanup(CleanupPolicyRemove, true)
+		Expect(report.DryRun).To(BeTrue())
+		Expect(report.Actions).To(HaveLen(3))
+		Expect(getNode().Annotations).To(HaveKey(plan.CustomLabelsAnnotation))
+		Expect(getMachine().Spec).To(Equal(machine.Spec))
+		Expect(getMachineSet().Annotations).To(HaveKey(syncGenerationAnnotation))
+	})
+
+	It("should have nothing left to clean up", func() {
+		cleanup(CleanupPolicyRemove, false)
+		Expect(cleanup(CleanupPolicyRemove, false).Actions).To(BeEmpty())
+	})
+
+	It("should refuse unknown policies", func() {
+		_, err := Cleanup(context.TODO(), fakeClient, []m.Provider{m.MachineAPIProvider{}}, "test", "delete", false)
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("should write the report as JSON", func() {
+		path := filepath.Join(GinkgoT().TempDir(), "report.json")
+		Expect(WriteCleanupReport(cleanup(CleanupPolicyKeep, true), path)).To(Succeed())
+		data, err := os.ReadFile(path)
+		Expect(err).NotTo(HaveOccurred())
+		report := &CleanupReport{}
+		Expect(json.Unmarshal(data, report)).To(Succeed())
+		Expect(report.Policy).To(Equal(CleanupPolicyKeep))
+		Expect(report.Actions).To(HaveLen(2))
+	})
+})
@@ -0,0 +1,199 @@
+package controllers
+
+import (
+	"context"
+	"time"
+
+	cpmsv1 "github.com/openshift/api/machine/v1"
+	machinev1beta1 "github.com/openshift/api/machine/v1beta1"
+	"github.com/openshift/managed-node-metadata-operator/pkg/audit"
+	m "github.com/openshift/managed-node-metadata-operator/pkg/machine"
+	"github.com/openshift/managed-node-metadata-operator/pkg/metrics"
+	"github.com/openshift/managed-node-metadata-operator/pkg/reasons"
+	"github.com/openshift/managed-node-metadata-operator/pkg/tracing"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	k8serr "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+var (
+	// controlPlaneReservedLabels identify control plane nodes and are never managed by the operator
+	controlPlaneReservedLabels = []string{
+		"node-role.kubernetes.io/master",
+		"node-role.kubernetes.io/control-plane",
+	}
+	// controlPlaneReservedTaints keep workloads off control plane nodes and are never removed by the operator
+	controlPlaneReservedTaints = []corev1.Taint{
+		{Key: "node-role.kubernetes.io/master", Effect: corev1.TaintEffectNoSchedule},
+		{Key: "node-role.kubernetes.io/control-plane", Effect: corev1.TaintEffectNoSchedule},
+	}
+)
+
+// ControlPlaneMachineSetReconciler reconciles a ControlPlaneMachineSet object
+type ControlPlaneMachineSetReconciler struct {
+	client.Client
+	Scheme   *runtime.Scheme
+	Recorder record.EventRecorder
+
+	// Auditor records every label and taint change of the control plane machines and nodes
+	Auditor audit.Sink
+}
+
+//+kubebuilder:rbac:groups=machine.openshift.io,resources=controlplanemachinesets,verbs=get;list;watch
+
+// Reconcile syncs the labels and taints of the ControlPlaneMachineSet template to the control plane
+// machines and their nodes, using the same pipeline as the MachineSet reconciler.
+func (r *ControlPlaneMachineSetReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	logger := log.FromContext(ctx)
+
+	key := m.MachineSetKey(m.ControlPlaneMachineSetProvider{}, req.Name)
+	cpms := &cpmsv1.ControlPlaneMachineSet{}
+	err := r.Get(ctx, req.NamespacedName, cpms)
+	if err != nil {
+		if k8serr.IsNotFound(err) {
+			metrics.DeleteMachineSetMetrics(key)
+			return reconcile.Result{}, nil
+		}
+		return reconcile.Result{}, err
+	}
+
+	start := time.Now()
+	defer func() {
+		metrics.ObserveReconcileDuration(key, time.Since(start))
+	}()
+	ctx, span := tracing.Start(ctx, "ReconcileControlPlaneMachineSet", tracing.MachineSetKey.String(key))
+	defer func() { tracing.End(span, err) }()
+
+	// An inactive ControlPlaneMachineSet does not manage the control plane machines yet
+	if cpms.Spec.State != cpmsv1.ControlPlaneMachineSetStateActive {
+		logger.Info("skipping inactive ControlPlaneMachineSet")
+		return reconcile.Result{}, nil
+	}
+	if cpms.Spec.Template.OpenShiftMachineV1Beta1Machine == nil {
+		logger.Info("skipping ControlPlaneMachineSet without a machine.openshift.io/v1beta1 template")
+		return reconcile.Result{}, nil
+	}
+
+	machines, err := m.GetMachinesForControlPlaneMachineSet(r.Client, cpms)
+	if err != nil {
+		return reconcile.Result{}, err
+	}
+
+	result, err := r.machineSetReconciler().processMachines(ctx, machineSetForControlPlaneMachineSet(cpms), m.MachineObjects(machines), nil)
+	if err != nil {
+		reason := reasons.ReasonFor(err)
+		logger.Error(err, "failed to sync ControlPlaneMachineSet", "reason", reason)
+		r.Recorder.Eventf(cpms, corev1.EventTypeWarning, string(reason), "Failed to sync labels and taints: %v", err)
+	}
+	return syncResult(result, err)
+}
+
+// machineSetReconciler returns a MachineSet reconciler that protects the control plane reserved keys
+func (r *ControlPlaneMachineSetReconciler) machineSetReconciler() *MachinesetReconciler {
+	return &MachinesetReconciler{
+		Client:         r.Client,
+		Scheme:         r.Scheme,
+		Recorder:       r.Recorder,
+		Provider:       m.ControlPlaneMachineSetProvider{},
+		Auditor:        r.Auditor,
+		ReservedLabels: controlPlaneReservedLabels,
+		ReservedTaints: controlPlaneReservedTaints,
+	}
+}
+
+// machineSetForControlPlaneMachineSet returns a MachineSet carrying the object metadata, selector
+// and machine template of the ControlPlaneMachineSet
+func machineSetForControlPlaneMachineSet(cpms *cpmsv1.ControlPlaneMachineSet) *machinev1beta1.MachineSet {
+	template := cpms.Spec.Template.OpenShiftMachineV1Beta1Machine
+	return &machinev1beta1.MachineSet{
+		ObjectMeta: *cpms.ObjectMeta.DeepCopy(),
+		Spec: machinev1beta1.MachineSetSpec{
+			Selector: *cpms.Spec.Selector.DeepCopy(),
+			Template: machinev1beta1.MachineTemplateSpec{
+				ObjectMeta: machinev1beta1.ObjectMeta{
+					Labels:      template.ObjectMeta.Labels,
+					Annotations: template.ObjectMeta.Annotations,
+				},
+				Spec: *template.Spec.DeepCopy(),
+			},
+		},
+	}
+}
+
+// machineToControlPlaneMachineSets enqueues the ControlPlaneMachineSets selecting the machine, so replaced control
+// plane machines are synced
+func (r *ControlPlaneMachineSetReconciler) machineToControlPlaneMachineSets(ctx context.Context, obj client.Object) []reconcile.Request {
+	machine, ok := obj.(*machinev1beta1.Machine)
+	if !ok {
+		return nil
+	}
+	cpmsList, err := m.GetControlPlaneMachineSetsForMachine(r.Client, machine)
+	if err != nil {
+		log.FromContext(ctx).Error(err, "failed to list the ControlPlaneMachineSets of machine", "machine", machine.Name)
+		return nil
+	}
+	requests := make([]reconcile.Request, 0, len(cpmsList))
+	for _, cpms := range cpmsList {
+		requests = append(requests, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(cpms)})
+	}
+	return requests
+}
+
+// nodeToControlPlaneMachineSets enqueues the ControlPlaneMachineSets of the machine of the node, so control plane
+// nodes that join or whose labels or taints are changed are synced
+func (r *ControlPlaneMachineSetReconciler) nodeToControlPlaneMachineSets(ctx context.Context, obj client.Object) []reconcile.Request {
+	machines := &machinev1beta1.MachineList{}
+	if err := r.List(ctx, machines); err != nil {
+		log.FromContext(ctx).Error(err, "failed to list the machines of node", "node", obj.GetName())
+		return nil
+	}
+	requests := []reconcile.Request{}
+	for idx := range machines.Items {
+		machine := &machines.Items[idx]
+		if machine.Status.NodeRef != nil && machine.Status.NodeRef.Name == obj.GetName() {
+			requests = append(requests, r.machineToControlPlaneMachineSets(ctx, machine)...)
+		}
+	}
+	return requests
+}
+
+// nodeMetadataChanged passes the node events that can take a node out of sync: nodes joining, and changes of their
+// labels, taints or readiness
+func nodeMetadataChanged() predicate.Predicate {
+	return predicate.Funcs{
+		UpdateFunc: func(e event.UpdateEvent) bool {
+			oldNode, oldOk := e.ObjectOld.(*corev1.Node)
+			newNode, newOk := e.ObjectNew.(*corev1.Node)
+			if !oldOk || !newOk {
+				return false
+			}
+			return !equality.Semantic.DeepEqual(oldNode.Labels, newNode.Labels) ||
+				!equality.Semantic.DeepEqual(oldNode.Spec.Taints, newNode.Spec.Taints) ||
+				m.IsNodeReady(oldNode) != m.IsNodeReady(newNode)
+		},
+		DeleteFunc: func(event.DeleteEvent) bool {
+			return false
+		},
+	}
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *ControlPlaneMachineSetReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&cpmsv1.ControlPlaneMachineSet{}).
+		Watches(&machinev1beta1.Machine{}, handler.EnqueueRequestsFromMapFunc(r.machineToControlPlaneMachineSets)).
+		Watches(&corev1.Node{}, handler.EnqueueRequestsFromMapFunc(r.nodeToControlPlaneMachineSets),
+			builder.WithPredicates(nodeMetadataChanged())).
+		Named("controlplanemachineset_controller").
+		Complete(r)
+}
@@ -0,0 +1,203 @@
+package controllers
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	cpmsv1 "github.com/openshift/api/machine/v1"
+	machinev1beta1 "github.com/openshift/api/machine/v1beta1"
+	"github.com/openshift/managed-node-metadata-operator/pkg/metrics"
+	"github.com/openshift/managed-node-metadata-operator/pkg/plan"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+var _ = Describe("ControlPlaneMachineSetController", func() {
+	var (
+		cpms           cpmsv1.ControlPlaneMachineSet
+		machine        machinev1beta1.Machine
+		node           corev1.Node
+		templateLabels map[string]string
+		templateTaints []corev1.Taint
+		state          cpmsv1.ControlPlaneMachineSetState
+		fakeClient     client.Client
+		r              *ControlPlaneMachineSetReconciler
+	)
+
+	s := newScheme(cpmsv1.AddToScheme)
+
+	BeforeEach(func() {
+		state = cpmsv1.ControlPlaneMachineSetStateActive
+		templateLabels = map[string]string{"foo": "bar"}
+		templateTaints = []corev1.Taint{}
+	})
+
+	JustBeforeEach(func() {
+		cpms = cpmsv1.ControlPlaneMachineSet{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "cluster",
+				Namespace: "openshift-machine-api",
+			},
+			Spec: cpmsv1.ControlPlaneMachineSetSpec{
+				State: state,
+				Selector: metav1.LabelSelector{
+					MatchLabels: map[string]string{
+						"machine.openshift.io/cluster-api-machine-role": "master",
+					},
+				},
+				Template: cpmsv1.ControlPlaneMachineSetTemplate{
+					MachineType: cpmsv1.OpenShiftMachineV1Beta1MachineType,
+					OpenShiftMachineV1Beta1Machine: &cpmsv1.OpenShiftMachineV1Beta1MachineTemplate{
+						ObjectMeta: cpmsv1.ControlPlaneMachineSetTemplateObjectMeta{
+							Labels: map[string]string{
+								"machine.openshift.io/cluster-api-machine-role": "master",
+							},
+						},
+						Spec: machinev1beta1.MachineSpec{
+							ObjectMeta: machinev1beta1.ObjectMeta{
+								Labels: templateLabels,
+							},
+							Taints: templateTaints,
+						},
+					},
+				},
+			},
+		}
+		machine = machinev1beta1.Machine{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "master-0",
+				Namespace: "openshift-machine-api",
+				Labels: map[string]string{
+					"machine.openshift.io/cluster-api-machine-role": "master",
+				},
+			},
+			Status: machinev1beta1.MachineStatus{
+				NodeRef: &corev1.ObjectReference{
+					Name: "master-node-0",
+				},
+			},
+		}
+		node = corev1.Node{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "master-node-0",
+				Labels: map[string]string{
+					"node-role.kubernetes.io/master":        "",
+					"node-role.kubernetes.io/control-plane": "",
+				},
+			},
+			Spec: corev1.NodeSpec{
+				Taints: []corev1.Taint{
+					{Key: "node-role.kubernetes.io/master", Effect: corev1.TaintEffectNoSchedule},
+				},
+			},
+		}
+
+		fakeClient = fake.NewClientBuilder().WithScheme(s).WithObjects(&cpms, &machine, &node).Build()
+		r = &ControlPlaneMachineSetReconciler{
+			Client:   fakeClient,
+			Scheme:   s,
+			Recorder: record.NewFakeRecorder(32),
+		}
+	})
+
+	reconcileAndGetNode := func() *corev1.Node {
+		_, err := r.Reconcile(context.TODO(), ctrl.Request{NamespacedName: types.NamespacedName{Name: "cluster", Namespace: "openshift-machine-api"}})
+		Expect(err).NotTo(HaveOccurred())
+		updatedNode := &corev1.Node{}
+		Expect(fakeClient.Get(context.TODO(), types.NamespacedName{Name: "master-node-0"}, updatedNode)).To(Succeed())
+		return updatedNode
+	}
+
+	Context("When a label and a taint are added to the ControlPlaneMachineSet", func() {
+		BeforeEach(func() {
+			templateTaints = []corev1.Taint{
+				{Key: "foo", Value: "bar", Effect: corev1.TaintEffectPreferNoSchedule},
+			}
+		})
+
+		It("should add them to the node and keep the control plane label and taint", func() {
+			updatedNode := reconcileAndGetNode()
+			Expect(updatedNode.Labels).To(HaveKeyWithValue("foo", "bar"))
+			Expect(updatedNode.Labels).To(HaveKey("node-role.kubernetes.io/master"))
+			Expect(updatedNode.Labels).To(HaveKey("node-role.kubernetes.io/control-plane"))
+			Expect(updatedNode.Spec.Taints).To(HaveLen(2))
+			Expect(plan.TaintExists(updatedNode.Spec.Taints, &corev1.Taint{Key: "node-role.kubernetes.io/master", Effect: corev1.TaintEffectNoSchedule})).To(BeTrue())
+			Expect(plan.TaintExists(updatedNode.Spec.Taints, &templateTaints[0])).To(BeTrue())
+		})
+	})
+
+	Context("When the ControlPlaneMachineSet template sets a reserved label", func() {
+		BeforeEach(func() {
+			templateLabels = map[string]string{"node-role.kubernetes.io/master": "changed"}
+		})
+
+		It("should not change the reserved label", func() {
+			updatedNode := reconcileAndGetNode()
+			Expect(updatedNode.Labels).To(HaveKeyWithValue("node-role.kubernetes.io/master", ""))
+			Expect(updatedNode.Annotations["managed.openshift.com/customlabels"]).NotTo(ContainSubstring("node-role.kubernetes.io/master"))
+		})
+	})
+
+	Context("When the ControlPlaneMachineSet is inactive", func() {
+		BeforeEach(func() {
+			state = cpmsv1.ControlPlaneMachineSetStateInactive
+		})
+
+		It("should not change the node", func() {
+			updatedNode := reconcileAndGetNode()
+			Expect(updatedNode.Labels).NotTo(HaveKey("foo"))
+		})
+	})
+
+	Context("When the ControlPlaneMachineSet is synced", func() {
+		BeforeEach(func() {
+			metrics.ManagedKeys.Reset()
+		})
+
+		It("should key its metrics by kind and name, apart from a MachineSet named cluster", func() {
+			reconcileAndGetNode()
+			Expect(testutil.ToFloat64(metrics.ManagedKeys.WithLabelValues("ControlPlaneMachineSet/cluster", plan.MetadataLabel))).To(Equal(1.0))
+			Expect(testutil.CollectAndCount(metrics.ManagedKeys)).To(Equal(2))
+		})
+	})
+
+	Context("When a control plane machine or node changes", func() {
+		It("should enqueue the ControlPlaneMachineSet of the machine", func() {
+			Expect(r.machineToControlPlaneMachineSets(context.TODO(), &machine)).To(ConsistOf(
+				reconcile.Request{NamespacedName: types.NamespacedName{Name: "cluster", Namespace: "openshift-machine-api"}},
+			))
+		})
+
+		It("should enqueue the ControlPlaneMachineSet of the machine of the node", func() {
+			Expect(r.nodeToControlPlaneMachineSets(context.TODO(), &node)).To(ConsistOf(
+				reconcile.Request{NamespacedName: types.NamespacedName{Name: "cluster", Namespace: "openshift-machine-api"}},
+			))
+		})
+
+		It("should not enqueue it for the nodes of other machines", func() {
+			other := &corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "worker-node-0"}}
+			Expect(r.nodeToControlPlaneMachineSets(context.TODO(), other)).To(BeEmpty())
+		})
+
+		It("should only pass the node updates changing labels, taints or readiness", func() {
+			p := nodeMetadataChanged()
+			relabelled := node.DeepCopy()
+			relabelled.Labels["foo"] = "bar"
+			heartbeat := node.DeepCopy()
+			heartbeat.ResourceVersion = "2"
+			Expect(p.Update(event.UpdateEvent{ObjectOld: &node, ObjectNew: relabelled})).To(BeTrue())
+			Expect(p.Update(event.UpdateEvent{ObjectOld: &node, ObjectNew: heartbeat})).To(BeFalse())
+			Expect(p.Create(event.CreateEvent{Object: &node})).To(BeTrue())
+		})
+	})
+})
@@ -0,0 +1,85 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"strconv"
+	"time"
+
+	"github.com/openshift/managed-node-metadata-operator/pkg/metrics"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// syncGenerationAnnotation records the generation of the machineset the operator is converging the nodes to
+	syncGenerationAnnotation = "managed.openshift.com/sync-generation"
+	// syncStartedAnnotation records when the operator first saw the generation of the sync generation annotation
+	syncStartedAnnotation = "managed.openshift.com/sync-started"
+	// syncedGenerationAnnotation records the last generation of the machineset all the nodes were updated to
+	syncedGenerationAnnotation = "managed.openshift.com/synced-generation"
+)
+
+// convergenceStartKey is the context key of the time the generation being synced was first seen
+type convergenceStartKey struct{}
+
+// startConvergence returns when the operator first saw the current generation of the machineset, and whether its
+// nodes already converged to it. The time is kept in annotations of the machineset so it survives restarts of the
+// operator, they're only written when a new generation is first seen. The time is returned even if it couldn't be
+// kept.
+func (r *MachinesetReconciler) startConvergence(ctx context.Context, machineSet client.Object) (time.Time, bool, error) {
+	generation := strconv.FormatInt(machineSet.GetGeneration(), 10)
+	annotations := machineSet.GetAnnotations()
+	if annotations[syncGenerationAnnotation] == generation {
+		start, err := time.Parse(time.RFC3339, annotations[syncStartedAnnotation])
+		if err == nil {
+			return start, annotations[syncedGenerationAnnotation] == generation, nil
+		}
+		klog.Warningf("ignoring invalid %s annotation of machineset %s: %v", syncStartedAnnotation, machineSet.GetName(), err)
+	}
+
+	start := time.Now().UTC().Truncate(time.Second)
+	return start, false, r.patchAnnotations(ctx, machineSet, map[string]string{
+		syncGenerationAnnotation: generation,
+		syncStartedAnnotation:    start.Format(time.RFC3339),
+	})
+}
+
+// completeConvergence records that all the nodes of the machineset match its current generation
+func (r *MachinesetReconciler) completeConvergence(ctx context.Context, machineSet client.Object, start time.Time) error {
+	err := r.patchAnnotations(ctx, machineSet, map[string]string{
+		syncedGenerationAnnotation: strconv.FormatInt(machineSet.GetGeneration(), 10),
+	})
+	if err != nil {
+		return err
+	}
+	metrics.ObserveMachineSetConvergence(r.machineSetKey(machineSet.GetName()), time.Since(start))
+	return nil
+}
+
+// patchAnnotations sets the given annotations on the machineset, keeping the others
+func (r *MachinesetReconciler) patchAnnotations(ctx context.Context, machineSet client.Object, set map[string]string) error {
+	patch := client.MergeFrom(machineSet.DeepCopyObject().(client.Object))
+	annotations := maps.Clone(machineSet.GetAnnotations())
+	if annotations == nil {
+		annotations = map[string]string{}
+	}
+	maps.Copy(annotations, set)
+	machineSet.SetAnnotations(annotations)
+	if err := r.Patch(ctx, machineSet, patch); err != nil {
+		return fmt.Errorf("failed to update sync annotations of machineset %s: %w", machineSet.GetName(), err)
+	}
+	return nil
+}
+
+// withConvergenceStart returns a context carrying when the generation being synced was first seen
+func withConvergenceStart(ctx context.Context, start time.Time) context.Context {
+	return context.WithValue(ctx, convergenceStartKey{}, start)
+}
+
+// convergenceStart returns when the generation being synced was first seen, if the nodes haven't converged to it yet
+func convergenceStart(ctx context.Context) (time.Time, bool) {
+	start, ok := ctx.Value(convergenceStartKey{}).(time.Time)
+	return start, ok
+}
@@ -0,0 +1,212 @@
+package controllers
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	machinev1beta1 "github.com/openshift/api/machine/v1beta1"
+	"github.com/openshift/managed-node-metadata-operator/pkg/metrics"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	dto "github.com/prometheus/client_model/go"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+// histogram returns the observations of the machineset in the histogram
+func histogram(vec *prometheus.HistogramVec, machineSet string) *dto.Histogram {
+	metric := &dto.Metric{}
+	Expect(vec.WithLabelValues(machineSet).(prometheus.Metric).Write(metric)).To(Succeed())
+	return metric.GetHistogram()
+}
+
+func histogramCount(vec *prometheus.HistogramVec, machineSet string) uint64 {
+	return histogram(vec, machineSet).GetSampleCount()
+}
+
+func histogramSum(vec *prometheus.HistogramVec, machineSet string) float64 {
+	return histogram(vec, machineSet).GetSampleSum()
+}
+
+var _ = Describe("Time to converge", func() {
+	var (
+		fakeClient client.Client
+		r          *MachinesetReconciler
+		machineSet *machinev1beta1.MachineSet
+		node       *corev1.Node
+	)
+
+	s := newScheme()
+
+	getMachineSet := func() *machinev1beta1.MachineSet {
+		current := &machinev1beta1.MachineSet{}
+		Expect(fakeClient.Get(context.TODO(), client.ObjectKeyFromObject(machineSet), current)).To(Succeed())
+		return current
+	}
+
+	// synced tells if the plan of a sync of the machineset changes nothing
+	synced := func(machineSet *machinev1beta1.MachineSet) bool {
+		machineSetPlan, err := r.planMachineSet(context.TODO(), machineSet)
+		Expect(err).NotTo(HaveOccurred())
+		return machineSetPlan.Synced()
+	}
+
+	BeforeEach(func() {
+		metrics.DeleteMachineSetMetrics("test-machineset")
+		machineSet = &machinev1beta1.MachineSet{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:       "test-machineset",
+				Namespace:  "test",
+				Generation: 2,
+			},
+			Spec: machinev1beta1.MachineSetSpec{
+				Selector: metav1.LabelSelector{
+					MatchLabels: map[string]string{"pool": "test"},
+				},
+				Template: machinev1beta1.MachineTemplateSpec{
+					ObjectMeta: machinev1beta1.ObjectMeta{
+						Labels: map[string]string{"pool": "test"},
+					},
+					Spec: machinev1beta1.MachineSpec{
+						ObjectMeta: machinev1beta1.ObjectMeta{
+							Labels: map[string]string{"foo": "bar"},
+						},
+						Taints: []corev1.Taint{{Key: "dedicated", Value: "infra", Effect: corev1.TaintEffectNoExecute}},
+					},
+				},
+			},
+		}
+		machine := &machinev1beta1.Machine{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "test-machine",
+				Namespace: "test",
+				Labels:    map[string]string{"pool": "test"},
+			},
+			Status: machinev1beta1.MachineStatus{
+				NodeRef: &corev1.ObjectReference{Name: "test-node"},
+			},
+		}
+		node = &corev1.Node{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "test-node",
+			},
+			Status: corev1.NodeStatus{Conditions: []corev1.NodeCondition{
+				{Type: corev1.NodeReady, Status: corev1.ConditionTrue},
+			}},
+		}
+		fakeClient = fake.NewClientBuilder().WithScheme(s).WithObjects(machineSet, machine, node).Build()
+		r = &MachinesetReconciler{Client: fakeClient, Recorder: record.NewFakeRecorder(32)}
+	})
+
+	It("should observe the time to converge once per generation", func() {
+		_, err := r.ProcessMachineSet(context.TODO(), getMachineSet())
+		Expect(err).NotTo(HaveOccurred())
+
+		current := getMachineSet()
+		Expect(current.Annotations).To(HaveKeyWithValue(syncGenerationAnnotation, "2"))
+		Expect(current.Annotations).To(HaveKey(syncStartedAnnotation))
+		Expect(current.Annotations).To(HaveKeyWithValue(syncedGenerationAnnotation, "2"))
+		Expect(synced(current)).To(BeTrue())
+		Expect(testutil.CollectAndCount(metrics.MachineSetConvergenceDuration)).To(Equal(1))
+		Expect(testutil.CollectAndCount(metrics.NodeConvergenceDuration)).To(Equal(1))
+
+		_, err = r.ProcessMachineSet(context.TODO(), getMachineSet())
+		Expect(err).NotTo(HaveOccurred())
+		Expect(histogramCount(metrics.MachineSetConvergenceDuration, "test-machineset")).To(Equal(uint64(1)))
+		Expect(histogramCount(metrics.NodeConvergenceDuration, "test-machineset")).To(Equal(uint64(1)))
+	})
+
+	It("should measure from when the generation was first seen, across restarts", func() {
+		current := getMachineSet()
+		current.Annotations = map[string]string{
+			syncGenerationAnnotation: "2",
+			syncStartedAnnotation:    time.Now().Add(-time.Hour).UTC().Format(time.RFC3339),
+		}
+		Expect(fakeClient.Update(context.TODO(), current)).To(Succeed())
+
+		restarted := &MachinesetReconciler{Client: fakeClient, Recorder: record.NewFakeRecorder(32)}
+		_, err := restarted.ProcessMachineSet(context.TODO(), getMachineSet())
+		Expect(err).NotTo(HaveOccurred())
+		Expect(histogramSum(metrics.MachineSetConvergenceDuration, "test-machineset")).To(BeNumerically(">=", time.Hour.Seconds()))
+	})
+
+	It("should not observe a converged generation again after a restart", func() {
+		_, err := r.ProcessMachineSet(context.TODO(), getMachineSet())
+		Expect(err).NotTo(HaveOccurred())
+
+		restarted := &MachinesetReconciler{Client: fakeClient, Recorder: record.NewFakeRecorder(32)}
+		_, err = restarted.ProcessMachineSet(context.TODO(), getMachineSet())
+		Expect(err).NotTo(HaveOccurred())
+		Expect(histogramCount(metrics.MachineSetConvergenceDuration, "test-machineset")).To(Equal(uint64(1)))
+	})
+
+	It("should restart the clock on a new generation", func() {
+		_, err := r.ProcessMachineSet(context.TODO(), getMachineSet())
+		Expect(err).NotTo(HaveOccurred())
+
+		current := getMachineSet()
+		current.Generation = 3
+		current.Spec.Template.Spec.Labels = map[string]string{"foo": "baz"}
+		Expect(fakeClient.Update(context.TODO(), current)).To(Succeed())
+		Expect(synced(getMachineSet())).To(BeFalse())
+
+		_, err = r.ProcessMachineSet(context.TODO(), getMachineSet())
+		Expect(err).NotTo(HaveOccurred())
+		Expect(synced(getMachineSet())).To(BeTrue())
+		Expect(getMachineSet().Annotations).To(HaveKeyWithValue(syncedGenerationAnnotation, "3"))
+		Expect(histogramCount(metrics.MachineSetConvergenceDuration, "test-machineset")).To(Equal(uint64(2)))
+		Expect(histogramCount(metrics.NodeConvergenceDuration, "test-machineset")).To(Equal(uint64(2)))
+	})
+
+	It("should not converge while NoExecute taints are held back", func() {
+		node.Status.Conditions[0].Status = corev1.ConditionFalse
+		Expect(fakeClient.Status().Update(context.TODO(), node)).To(Succeed())
+
+		result, err := r.ProcessMachineSet(context.TODO(), getMachineSet())
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result.RequeueAfter).To(BeNumerically(">", 0))
+		Expect(synced(getMachineSet())).To(BeFalse())
+		Expect(getMachineSet().Annotations).NotTo(HaveKey(syncedGenerationAnnotation))
+		Expect(testutil.CollectAndCount(metrics.MachineSetConvergenceDuration)).To(Equal(0))
+		Expect(testutil.CollectAndCount(metrics.NodeConvergenceDuration)).To(Equal(0))
+	})
+
+	It("should not converge until the nodes of new machines join", func() {
+		provisioning := &machinev1beta1.Machine{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "new-machine",
+				Namespace: "test",
+				Labels:    map[string]string{"pool": "test"},
+			},
+		}
+		Expect(fakeClient.Create(context.TODO(), provisioning)).To(Succeed())
+
+		result, err := r.ProcessMachineSet(context.TODO(), getMachineSet())
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result.RequeueAfter).To(BeNumerically(">", 0))
+		Expect(getMachineSet().Annotations).NotTo(HaveKey(syncedGenerationAnnotation))
+		Expect(testutil.CollectAndCount(metrics.MachineSetConvergenceDuration)).To(Equal(0))
+
+		Expect(fakeClient.Create(context.TODO(), &corev1.Node{
+			ObjectMeta: metav1.ObjectMeta{Name: "new-node"},
+			Status: corev1.NodeStatus{
+				Conditions: []corev1.NodeCondition{{Type: corev1.NodeReady, Status: corev1.ConditionTrue}},
+			},
+		})).To(Succeed())
+		provisioning.Status.NodeRef = &corev1.ObjectReference{Name: "new-node"}
+		Expect(fakeClient.Update(context.TODO(), provisioning)).To(Succeed())
+
+		result, err = r.ProcessMachineSet(context.TODO(), getMachineSet())
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result.RequeueAfter).To(BeZero())
+		Expect(getMachineSet().Annotations).To(HaveKeyWithValue(syncedGenerationAnnotation, "2"))
+		Expect(histogramCount(metrics.MachineSetConvergenceDuration, "test-machineset")).To(Equal(uint64(1)))
+		Expect(histogramCount(metrics.NodeConvergenceDuration, "test-machineset")).To(Equal(uint64(2)))
+	})
+})
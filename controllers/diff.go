@@ -0,0 +1,106 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+
+	machinev1beta1 "github.com/openshift/api/machine/v1beta1"
+	m "github.com/openshift/managed-node-metadata-operator/pkg/machine"
+	"github.com/openshift/managed-node-metadata-operator/pkg/plan"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// Diff returns the plans of the syncs of the machinesets of the providers in the namespace, or of the named
+// machineset only if not empty. It never updates any object.
+func Diff(ctx context.Context, c client.Client, providers []m.Provider, namespace string, machineSetName string) ([]plan.Plan, error) {
+	plans := []plan.Plan{}
+	for _, provider := range providers {
+		r := &MachinesetReconciler{Client: c, Provider: provider}
+		machineSets := provider.NewMachineSetList()
+		if err := c.List(ctx, machineSets, client.InNamespace(namespace)); err != nil {
+			return nil, err
+		}
+		items, err := meta.ExtractList(machineSets)
+		if err != nil {
+			return nil, err
+		}
+		for _, item := range items {
+			machineSet, ok := item.(client.Object)
+			if !ok || (machineSetName != "" && machineSet.GetName() != machineSetName) {
+				continue
+			}
+			machineSetPlan, err := r.planMachineSet(ctx, machineSet)
+			if err != nil {
+				return nil, err
+			}
+			plans = append(plans, *machineSetPlan)
+		}
+	}
+	return plans, nil
+}
+
+// planMachineSet fetches the machines of the machineset and the nodes of the machines it syncs, and returns the
+// plan of its sync
+func (r *MachinesetReconciler) planMachineSet(ctx context.Context, machineSet client.Object) (*plan.Plan, error) {
+	machines, overlaps, err := r.getMachines(ctx, machineSet)
+	if err != nil {
+		return nil, err
+	}
+	nodes, err := r.getNodes(ctx, machines, overlaps)
+	if err != nil {
+		return nil, err
+	}
+	return r.planner().Plan(machineSet, machines, nodes, overlaps), nil
+}
+
+// getMachines returns the machines of the machineset, and the other machinesets selecting them keyed by machine
+// name
+func (r *MachinesetReconciler) getMachines(ctx context.Context, machineSet client.Object) ([]client.Object, map[string][]string, error) {
+	machines, err := r.provider().GetMachines(ctx, r.Client, machineSet)
+	if err != nil {
+		return nil, nil, err
+	}
+	overlaps := map[string][]string{}
+	if mapiMachineSet, ok := machineSet.(*machinev1beta1.MachineSet); ok {
+		overlaps, err = contestedMachines(r.Client, mapiMachineSet, machines)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	return machines, overlaps, nil
+}
+
+// getNodes returns the nodes of the machines, keyed by name. The nodes of the machines the sync skips whatever
+// their node aren't fetched.
+func (r *MachinesetReconciler) getNodes(ctx context.Context, machines []client.Object, overlaps map[string][]string) (map[string]*corev1.Node, error) {
+	nodes := map[string]*corev1.Node{}
+	for _, machine := range machines {
+		if plan.SkipReason(machine, overlaps) != "" {
+			continue
+		}
+		node, err := r.provider().GetNode(ctx, r.Client, machine)
+		if err != nil {
+			return nil, &nodeError{Node: m.GetNodeName(machine), err: fmt.Errorf("failed to fetch node for machine %s: %w", machine.GetName(), err)}
+		}
+		if node != nil {
+			nodes[node.Name] = node
+		}
+	}
+	return nodes, nil
+}
+
+// nodeError is the failure to fetch the node of a machine
+type nodeError struct {
+	Node string
+	err  error
+}
+
+func (e *nodeError) Error() string {
+	return e.err.Error()
+}
+
+func (e *nodeError) Unwrap() error {
+	return e.err
+}
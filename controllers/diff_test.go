@@ -0,0 +1,200 @@
+package controllers
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	machinev1beta1 "github.com/openshift/api/machine/v1beta1"
+	m "github.com/openshift/managed-node-metadata-operator/pkg/machine"
+	"github.com/openshift/managed-node-metadata-operator/pkg/plan"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+var _ = Describe("Diff", func() {
+	var (
+		fakeClient client.Client
+		objects    []client.Object
+	)
+
+	s := newScheme()
+
+	newMachine := func(name string, nodeName string, labels map[string]string) *machinev1beta1.Machine {
+		machine := &machinev1beta1.Machine{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: "test",
+				Labels:    map[string]string{"pool": "test"},
+			},
+			Spec: machinev1beta1.MachineSpec{
+				ObjectMeta: machinev1beta1.ObjectMeta{Labels: labels},
+			},
+		}
+		if nodeName != "" {
+			machine.Status.NodeRef = &corev1.ObjectReference{Name: nodeName}
+		}
+		return machine
+	}
+
+	newNode := func(name string, labels map[string]string, managedLabels string, ready corev1.ConditionStatus) *corev1.Node {
+		return &corev1.Node{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        name,
+				Labels:      labels,
+				Annotations: map[string]string{"managed.openshift.com/customlabels": managedLabels},
+			},
+			Status: corev1.NodeStatus{Conditions: []corev1.NodeCondition{
+				{Type: corev1.NodeReady, Status: ready},
+			}},
+		}
+	}
+
+	BeforeEach(func() {
+		objects = []client.Object{
+			&machinev1beta1.MachineSet{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "test-machineset",
+					Namespace: "test",
+				},
+				Spec: machinev1beta1.MachineSetSpec{
+					Selector: metav1.LabelSelector{
+						MatchLabels: map[string]string{"pool": "test"},
+					},
+					Template: machinev1beta1.MachineTemplateSpec{
+						ObjectMeta: machinev1beta1.ObjectMeta{
+							Labels: map[string]string{"pool": "test"},
+						},
+						Spec: machinev1beta1.MachineSpec{
+							ObjectMeta: machinev1beta1.ObjectMeta{
+								Labels: map[string]string{"foo": "bar", "team": "payments"},
+							},
+							Taints: []corev1.Taint{{Key: "dedicated", Value: "infra", Effect: corev1.TaintEffectNoExecute}},
+						},
+					},
+				},
+			},
+		}
+	})
+
+	JustBeforeEach(func() {
+		fakeClient = fake.NewClientBuilder().WithScheme(s).WithObjects(objects...).Build()
+	})
+
+	diff := func() []plan.MachinePlan {
+		diffs, err := Diff(context.TODO(), fakeClient, []m.Provider{m.MachineAPIProvider{}}, "test", "")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(diffs).To(HaveLen(1))
+		return diffs[0].Machines
+	}
+
+	Context("When a node is out of sync", func() {
+		BeforeEach(func() {
+			objects = append(objects,
+				newMachine("test-machine", "test-node", map[string]string{"foo": "old", "stale": "value"}),
+				newNode("test-node", map[string]string{"foo": "old", "stale": "value", "team": "platform"}, "foo,stale", corev1.ConditionTrue),
+			)
+		})
+
+		It("should return the changes per machine and node", func() {
+			machines := diff()
+			Expect(machines).To(HaveLen(1))
+			Expect(machines[0].Node).To(Equal("test-node"))
+
+			changes := map[string]plan.Operation{}
+			for _, change := range machines[0].Operations {
+				changes[change.Kind+"/"+change.Metadata+"/"+change.Key] = change
+			}
+			Expect(changes).To(HaveLen(7))
+
+			Expect(changes["Machine/label/foo"].Change).To(Equal(plan.ChangeChanged))
+			Expect(*changes["Machine/label/foo"].OldValue).To(Equal("old"))
+			Expect(*changes["Machine/label/foo"].NewValue).To(Equal("bar"))
+			Expect(changes["Machine/label/stale"].Change).To(Equal(plan.ChangeRemoved))
+			Expect(changes["Machine/taint/dedicated:NoExecute"].Change).To(Equal(plan.ChangeAdded))
+			Expect(changes["Node/label/foo"].Change).To(Equal(plan.ChangeChanged))
+			Expect(changes["Node/label/stale"].Change).To(Equal(plan.ChangeRemoved))
+			Expect(changes["Node/taint/dedicated:NoExecute"].Change).To(Equal(plan.ChangeAdded))
+
+			skipped := changes["Node/label/team"]
+			Expect(skipped.Change).To(Equal(plan.ChangeSkipped))
+			Expect(skipped.Reason).To(Equal(plan.ReasonLabelConflict))
+			Expect(*skipped.OldValue).To(Equal("platform"))
+			Expect(*skipped.NewValue).To(Equal("payments"))
+		})
+
+		It("should not update anything", func() {
+			diff()
+			node := &corev1.Node{}
+			Expect(fakeClient.Get(context.TODO(), client.ObjectKey{Name: "test-node"}, node)).To(Succeed())
+			Expect(node.Labels).To(HaveKeyWithValue("foo", "old"))
+			Expect(node.Spec.Taints).To(BeEmpty())
+		})
+
+		It("should match what a sync changes", func() {
+			r := &MachinesetReconciler{Client: fakeClient, Recorder: record.NewFakeRecorder(32)}
+			machineSet := &machinev1beta1.MachineSet{}
+			Expect(fakeClient.Get(context.TODO(), client.ObjectKey{Namespace: "test", Name: "test-machineset"}, machineSet)).To(Succeed())
+			_, err := r.ProcessMachineSet(context.TODO(), machineSet)
+			Expect(err).NotTo(HaveOccurred())
+
+			machines := diff()
+			Expect(machines[0].Operations).To(HaveLen(1))
+			Expect(machines[0].Operations[0].Change).To(Equal(plan.ChangeSkipped))
+		})
+	})
+
+	Context("When a node isn't ready", func() {
+		BeforeEach(func() {
+			objects = append(objects,
+				newMachine("test-machine", "test-node", nil),
+				newNode("test-node", nil, "", corev1.ConditionFalse),
+			)
+		})
+
+		It("should skip the NoExecute taints", func() {
+			machines := diff()
+			var held []plan.Operation
+			for _, change := range machines[0].Operations {
+				Expect(change.Metadata == plan.MetadataTaint && change.Change != plan.ChangeSkipped).To(BeFalse())
+				if change.Change == plan.ChangeSkipped && change.Metadata == plan.MetadataTaint {
+					held = append(held, change)
+				}
+			}
+			Expect(held).To(HaveLen(1))
+			Expect(held[0].Key).To(Equal("dedicated:NoExecute"))
+			Expect(held[0].Reason).To(Equal(plan.ReasonNodeNotReady))
+		})
+	})
+
+	Context("When machines can't be synced", func() {
+		BeforeEach(func() {
+			failed := newMachine("failed-machine", "failed-node", nil)
+			phase := "Failed"
+			failed.Status.Phase = &phase
+			objects = append(objects, failed, newMachine("provisioning-machine", "", nil))
+		})
+
+		It("should return why they're skipped", func() {
+			skipped := map[string]string{}
+			for _, machine := range diff() {
+				skipped[machine.Name] = machine.Skipped
+				Expect(machine.Operations).To(BeEmpty())
+			}
+			Expect(skipped).To(Equal(map[string]string{
+				"failed-machine":       plan.ReasonMachineFailed,
+				"provisioning-machine": plan.ReasonNoNode,
+			}))
+		})
+	})
+
+	It("should filter by machineset", func() {
+		diffs, err := Diff(context.TODO(), fakeClient, []m.Provider{m.MachineAPIProvider{}}, "test", "other")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(diffs).To(BeEmpty())
+	})
+})
@@ -0,0 +1,117 @@
+package controllers
+
+import (
+	"context"
+	"time"
+
+	m "github.com/openshift/managed-node-metadata-operator/pkg/machine"
+	"github.com/openshift/managed-node-metadata-operator/pkg/metrics"
+	"github.com/openshift/managed-node-metadata-operator/pkg/plan"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// DriftEvaluator periodically compares the labels and taints of the nodes with their machineset template
+// and exports the number of nodes out of sync, so a diverged pool is noticed even when no machineset event
+// triggers a reconcile. It never updates any object.
+type DriftEvaluator struct {
+	client.Client
+
+	// Provider gives access to the machine sets, machines and nodes, it defaults to the Machine API
+	Provider m.Provider
+	// Namespace of the machine sets, all namespaces of the cache are evaluated if empty
+	Namespace string
+	// Interval between two evaluations
+	Interval time.Duration
+
+	// reported are the machinesets exported by the last evaluation
+	reported map[string]bool
+}
+
+// nodeDrift is the number of nodes of a machineset whose labels or taints are out of sync
+type nodeDrift struct {
+	labels int
+	taints int
+}
+
+// Start runs the evaluation every Interval until the context is done
+func (e *DriftEvaluator) Start(ctx context.Context) error {
+	wait.UntilWithContext(ctx, func(ctx context.Context) {
+		if err := e.Evaluate(ctx); err != nil {
+			klog.Errorf("failed to evaluate drift of the %s nodes: %v", e.reconciler().provider().Name(), err)
+		}
+	}, e.Interval)
+	return nil
+}
+
+// NeedLeaderElection makes only the leader export the drift, like it is the only one syncing the nodes
+func (e *DriftEvaluator) NeedLeaderElection() bool {
+	return true
+}
+
+// Evaluate exports the number of nodes out of sync for every machineset
+func (e *DriftEvaluator) Evaluate(ctx context.Context) error {
+	r := e.reconciler()
+	machineSets := r.provider().NewMachineSetList()
+	if err := e.List(ctx, machineSets, client.InNamespace(e.Namespace)); err != nil {
+		return err
+	}
+	items, err := meta.ExtractList(machineSets)
+	if err != nil {
+		return err
+	}
+
+	reported := map[string]bool{}
+	for _, item := range items {
+		machineSet, ok := item.(client.Object)
+		if !ok {
+			continue
+		}
+		drift, err := e.evaluateMachineSet(ctx, r, machineSet)
+		if err != nil {
+			klog.Errorf("failed to evaluate drift of machineset %s: %v", machineSet.GetName(), err)
+			continue
+		}
+		key := r.machineSetKey(machineSet.GetName())
+		metrics.SetNodesOutOfSync(key, plan.MetadataLabel, drift.labels)
+		metrics.SetNodesOutOfSync(key, plan.MetadataTaint, drift.taints)
+		reported[key] = true
+	}
+
+	for key := range e.reported {
+		if !reported[key] {
+			metrics.DeleteNodesOutOfSync(key)
+		}
+	}
+	e.reported = reported
+	return nil
+}
+
+// evaluateMachineSet counts the nodes of the machineset out of sync, skipping the machines the reconciler skips
+func (e *DriftEvaluator) evaluateMachineSet(ctx context.Context, r *MachinesetReconciler, machineSet client.Object) (nodeDrift, error) {
+	drift := nodeDrift{}
+	machineSetPlan, err := r.planMachineSet(ctx, machineSet)
+	if err != nil {
+		return drift, err
+	}
+	for _, machinePlan := range machineSetPlan.Machines {
+		if len(machinePlan.Changes(plan.KindNode, plan.MetadataLabel)) > 0 {
+			drift.labels++
+		}
+		if len(machinePlan.Changes(plan.KindNode, plan.MetadataTaint)) > 0 {
+			drift.taints++
+		}
+	}
+	return drift, nil
+}
+
+// reconciler returns a MachineSet reconciler used to compute the expected labels and taints, it's never
+// used to update objects
+func (e *DriftEvaluator) reconciler() *MachinesetReconciler {
+	return &MachinesetReconciler{
+		Client:   e.Client,
+		Provider: e.Provider,
+	}
+}
@@ -0,0 +1,249 @@
+package controllers
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"net/http"
+	"sort"
+	"time"
+
+	m "github.com/openshift/managed-node-metadata-operator/pkg/machine"
+	"github.com/openshift/managed-node-metadata-operator/pkg/plan"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// DriftReportPath is the path of the drift report endpoint on the metrics server
+const DriftReportPath = "/debug/drift-report"
+
+// Statuses of the keys of a drift report
+const (
+	// KeyInSync is a key whose value on the node is the one of the template
+	KeyInSync = "InSync"
+	// KeyDrifted is a key whose value on the node isn't the one of the template, whether the operator will fix it
+	// or skips it
+	KeyDrifted = "Drifted"
+	// KeyUnmanaged is a key of the template the operator never manages, like reserved labels
+	KeyUnmanaged = "Unmanaged"
+)
+
+// driftReportColumns is the header of the CSV drift report
+var driftReportColumns = []string{"machineset", "machine", "node", "metadata", "key", "expected", "actual", "status", "change", "reason"}
+
+// DriftReport compares the labels and taints of every node of the machinesets with their template
+type DriftReport struct {
+	Time    time.Time    `json:"time"`
+	Summary DriftSummary `json:"summary"`
+	Nodes   []NodeReport `json:"nodes"`
+}
+
+// DriftSummary is the totals of a drift report
+type DriftSummary struct {
+	MachineSets  int `json:"machineSets"`
+	Nodes        int `json:"nodes"`
+	NodesInSync  int `json:"nodesInSync"`
+	NodesDrifted int `json:"nodesDrifted"`
+	// NodesSkipped are the nodes of machines the operator doesn't sync, like failed machines
+	NodesSkipped int `json:"nodesSkipped"`
+	KeysInSync   int `json:"keysInSync"`
+	KeysDrifted  int `json:"keysDrifted"`
+}
+
+// NodeReport compares the labels and taints of a node with the template of its machineset
+type NodeReport struct {
+	MachineSet string `json:"machineSet"`
+	Machine    string `json:"machine"`
+	Node       string `json:"node"`
+	// Skipped is why the machine of the node isn't synced, its keys aren't compared
+	Skipped string      `json:"skipped,omitempty"`
+	Drifted bool        `json:"drifted"`
+	Keys    []KeyReport `json:"keys"`
+}
+
+// KeyReport compares a label or a taint of a node with the template. Taints are identified by their key and
+// effect. Expected is nil for the keys the node shouldn't have, and actual for the keys it lacks.
+type KeyReport struct {
+	Metadata string  `json:"metadata"`
+	Key      string  `json:"key"`
+	Expected *string `json:"expected"`
+	Actual   *string `json:"actual"`
+	Status   string  `json:"status"`
+	// Change is what the next sync does to the key of a drifted node
+	Change string `json:"change,omitempty"`
+	Reason string `json:"reason,omitempty"`
+}
+
+// NewDriftReport returns the drift report of the nodes of the plans. Machines without a node are left out.
+func NewDriftReport(plans []plan.Plan) *DriftReport {
+	report := &DriftReport{Time: time.Now().UTC(), Nodes: []NodeReport{}}
+	for _, machineSetPlan := range plans {
+		report.Summary.MachineSets++
+		for _, machinePlan := range machineSetPlan.Machines {
+			if machinePlan.Node == "" || machinePlan.Skipped == plan.ReasonNoNode {
+				continue
+			}
+			nodeReport := NodeReport{
+				MachineSet: machineSetPlan.Name,
+				Machine:    machinePlan.Name,
+				Node:       machinePlan.Node,
+				Skipped:    machinePlan.Skipped,
+				Keys:       []KeyReport{},
+			}
+			if machinePlan.Skipped == "" {
+				nodeReport.Keys = compareKeys(machinePlan)
+			}
+			report.add(nodeReport)
+		}
+	}
+	return report
+}
+
+// add adds the node to the report and its totals
+func (r *DriftReport) add(nodeReport NodeReport) {
+	for _, key := range nodeReport.Keys {
+		switch key.Status {
+		case KeyInSync:
+			r.Summary.KeysInSync++
+		case KeyDrifted:
+			r.Summary.KeysDrifted++
+			nodeReport.Drifted = true
+		}
+	}
+	r.Summary.Nodes++
+	switch {
+	case nodeReport.Skipped != "":
+		r.Summary.NodesSkipped++
+	case nodeReport.Drifted:
+		r.Summary.NodesDrifted++
+	default:
+		r.Summary.NodesInSync++
+	}
+	r.Nodes = append(r.Nodes, nodeReport)
+}
+
+// compareKeys compares the keys of the template and the keys changed on the node by the plan. Labels set by someone
+// else to the value of the template are in sync, to another value they're drifted even though the operator skips
+// them.
+func compareKeys(machinePlan plan.MachinePlan) []KeyReport {
+	keys := []KeyReport{}
+	planned := map[string]bool{}
+	for _, op := range machinePlan.Operations {
+		if op.Kind != plan.KindNode {
+			continue
+		}
+		planned[op.Metadata+"/"+op.Key] = true
+		key := KeyReport{Metadata: op.Metadata, Key: op.Key, Expected: op.NewValue, Actual: op.OldValue, Status: KeyDrifted, Change: op.Change, Reason: op.Reason}
+		switch {
+		case op.Reason == plan.ReasonReservedKey:
+			key.Status, key.Change = KeyUnmanaged, ""
+		case op.Change == plan.ChangeSkipped && op.OldValue != nil && op.NewValue != nil && *op.OldValue == *op.NewValue:
+			key.Status, key.Change = KeyInSync, ""
+		}
+		keys = append(keys, key)
+	}
+
+	for name, value := range machinePlan.Labels {
+		if !planned[plan.MetadataLabel+"/"+name] {
+			keys = append(keys, KeyReport{Metadata: plan.MetadataLabel, Key: name, Expected: &value, Actual: &value, Status: KeyInSync})
+		}
+	}
+	for i := range machinePlan.Taints {
+		name := plan.TaintKey(&machinePlan.Taints[i])
+		if !planned[plan.MetadataTaint+"/"+name] {
+			value := &machinePlan.Taints[i].Value
+			keys = append(keys, KeyReport{Metadata: plan.MetadataTaint, Key: name, Expected: value, Actual: value, Status: KeyInSync})
+		}
+	}
+	sort.SliceStable(keys, func(i, j int) bool {
+		if keys[i].Metadata != keys[j].Metadata {
+			return keys[i].Metadata < keys[j].Metadata
+		}
+		return keys[i].Key < keys[j].Key
+	})
+	return keys
+}
+
+// WriteCSV writes a row per key of the nodes of the report, and a row per skipped node, after a header row
+func (r *DriftReport) WriteCSV(out io.Writer) error {
+	w := csv.NewWriter(out)
+	if err := w.Write(driftReportColumns); err != nil {
+		return err
+	}
+	for _, node := range r.Nodes {
+		if node.Skipped != "" {
+			if err := w.Write([]string{node.MachineSet, node.Machine, node.Node, "", "", "", "", "Skipped", "", node.Skipped}); err != nil {
+				return err
+			}
+			continue
+		}
+		for _, key := range node.Keys {
+			row := []string{node.MachineSet, node.Machine, node.Node, key.Metadata, key.Key, valueOrEmpty(key.Expected),
+				valueOrEmpty(key.Actual), key.Status, key.Change, key.Reason}
+			if err := w.Write(row); err != nil {
+				return err
+			}
+		}
+	}
+	w.Flush()
+	return w.Error()
+}
+
+func valueOrEmpty(value *string) string {
+	if value == nil {
+		return ""
+	}
+	return *value
+}
+
+// DriftReportHandler serves the drift report of the managed machinesets as JSON, or as CSV with the format=csv query
+// parameter. The machineset query parameter filters the machinesets. It never updates any object.
+type DriftReportHandler struct {
+	client.Client
+
+	// Providers give access to the machine sets, machines and nodes
+	Providers []m.Provider
+	// Namespace of the machine sets, all namespaces of the cache are reported if empty
+	Namespace string
+}
+
+func (h *DriftReportHandler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet {
+		http.Error(w, "only GET is supported", http.StatusMethodNotAllowed)
+		return
+	}
+	format := req.URL.Query().Get("format")
+	if format != "" && format != "json" && format != "csv" {
+		http.Error(w, "format must be json or csv", http.StatusBadRequest)
+		return
+	}
+	report, err := h.DriftReport(req.Context(), req.URL.Query().Get("machineset"))
+	if err != nil {
+		klog.Errorf("failed to compute the drift report: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if format == "csv" {
+		w.Header().Set("Content-Type", "text/csv")
+		err = report.WriteCSV(w)
+	} else {
+		w.Header().Set("Content-Type", "application/json")
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		err = encoder.Encode(report)
+	}
+	if err != nil {
+		klog.Errorf("failed to write the drift report: %v", err)
+	}
+}
+
+// DriftReport returns the drift report of the machinesets, or of the named machineset only if not empty
+func (h *DriftReportHandler) DriftReport(ctx context.Context, machineSetName string) (*DriftReport, error) {
+	plans, err := Diff(ctx, h.Client, h.Providers, h.Namespace, machineSetName)
+	if err != nil {
+		return nil, err
+	}
+	return NewDriftReport(plans), nil
+}
@@ -0,0 +1,152 @@
+package controllers
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	machinev1beta1 "github.com/openshift/api/machine/v1beta1"
+	m "github.com/openshift/managed-node-metadata-operator/pkg/machine"
+	"github.com/openshift/managed-node-metadata-operator/pkg/plan"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+var _ = Describe("DriftReport", func() {
+	var handler *DriftReportHandler
+
+	s := newScheme()
+
+	newNode := func(name string, labels map[string]string, owned string) *corev1.Node {
+		return &corev1.Node{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        name,
+				Labels:      labels,
+				Annotations: map[string]string{plan.CustomLabelsAnnotation: owned},
+			},
+			Spec: corev1.NodeSpec{Taints: []corev1.Taint{{Key: "dedicated", Value: "infra", Effect: corev1.TaintEffectNoSchedule}}},
+			Status: corev1.NodeStatus{Conditions: []corev1.NodeCondition{
+				{Type: corev1.NodeReady, Status: corev1.ConditionTrue},
+			}},
+		}
+	}
+
+	newMachine := func(name string, node string, phase string) *machinev1beta1.Machine {
+		return &machinev1beta1.Machine{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "test", Labels: map[string]string{"pool": "test"}},
+			Status:     machinev1beta1.MachineStatus{NodeRef: &corev1.ObjectReference{Name: node}, Phase: &phase},
+		}
+	}
+
+	BeforeEach(func() {
+		machineSet := &machinev1beta1.MachineSet{
+			ObjectMeta: metav1.ObjectMeta{Name: "test-machineset", Namespace: "test"},
+			Spec: machinev1beta1.MachineSetSpec{
+				Selector: metav1.LabelSelector{MatchLabels: map[string]string{"pool": "test"}},
+				Template: machinev1beta1.MachineTemplateSpec{
+					ObjectMeta: machinev1beta1.ObjectMeta{Labels: map[string]string{"pool": "test"}},
+					Spec: machinev1beta1.MachineSpec{
+						ObjectMeta: machinev1beta1.ObjectMeta{Labels: map[string]string{"foo": "bar", "team": "payments"}},
+						Taints:     []corev1.Taint{{Key: "dedicated", Value: "infra", Effect: corev1.TaintEffectNoSchedule}},
+					},
+				},
+			},
+		}
+		fakeClient := fake.NewClientBuilder().WithScheme(s).WithObjects(
+			machineSet,
+			newMachine("in-sync", "in-sync-node", "Running"),
+			newMachine("drifted", "drifted-node", "Running"),
+			newMachine("failed", "failed-node", "Failed"),
+			newNode("in-sync-node", map[string]string{"foo": "bar", "team": "payments"}, "foo"),
+			newNode("drifted-node", map[string]string{"foo": "old", "team": "platform"}, "foo"),
+			newNode("failed-node", nil, ""),
+		).Build()
+		handler = &DriftReportHandler{Client: fakeClient, Providers: []m.Provider{m.MachineAPIProvider{}}, Namespace: "test"}
+	})
+
+	nodeReports := func(report *DriftReport) map[string]NodeReport {
+		result := map[string]NodeReport{}
+		for _, node := range report.Nodes {
+			result[node.Node] = node
+		}
+		return result
+	}
+
+	It("should compare every key of the nodes with the template", func() {
+		report, err := handler.DriftReport(context.TODO(), "")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(report.Summary).To(Equal(DriftSummary{
+			MachineSets:  1,
+			Nodes:        3,
+			NodesInSync:  1,
+			NodesDrifted: 1,
+			NodesSkipped: 1,
+			KeysInSync:   4,
+			KeysDrifted:  2,
+		}))
+
+		nodes := nodeReports(report)
+		Expect(nodes["in-sync-node"].Drifted).To(BeFalse())
+		Expect(nodes["failed-node"].Skipped).To(Equal(plan.ReasonMachineFailed))
+		Expect(nodes["failed-node"].Keys).To(BeEmpty())
+
+		keys := map[string]KeyReport{}
+		for _, key := range nodes["drifted-node"].Keys {
+			keys[key.Metadata+"/"+key.Key] = key
+		}
+		Expect(keys["label/foo"].Status).To(Equal(KeyDrifted))
+		Expect(keys["label/foo"].Change).To(Equal(plan.ChangeChanged))
+		Expect(*keys["label/foo"].Actual).To(Equal("old"))
+		Expect(keys["label/team"].Status).To(Equal(KeyDrifted))
+		Expect(keys["label/team"].Reason).To(Equal(plan.ReasonLabelConflict))
+		Expect(keys["taint/dedicated:NoSchedule"].Status).To(Equal(KeyInSync))
+	})
+
+	It("should report labels set by someone else to the template value in sync", func() {
+		value := "payments"
+		report := NewDriftReport([]plan.Plan{{Name: "test-machineset", Machines: []plan.MachinePlan{
+			{Name: "machine", Node: "node", Operations: []plan.Operation{
+				{Kind: plan.KindNode, Metadata: plan.MetadataLabel, Key: "team", Change: plan.ChangeSkipped,
+					OldValue: &value, NewValue: &value, Reason: plan.ReasonLabelConflict},
+			}},
+			{Name: "provisioning", Skipped: plan.ReasonNoNode},
+		}}})
+		Expect(report.Nodes).To(HaveLen(1))
+		Expect(report.Nodes[0].Keys[0].Status).To(Equal(KeyInSync))
+		Expect(report.Summary.NodesInSync).To(Equal(1))
+	})
+
+	It("should serve the report as JSON", func() {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, DriftReportPath+"?machineset=test-machineset", nil))
+		Expect(rec.Code).To(Equal(http.StatusOK))
+		report := &DriftReport{}
+		Expect(json.Unmarshal(rec.Body.Bytes(), report)).To(Succeed())
+		Expect(report.Nodes).To(HaveLen(3))
+	})
+
+	It("should serve a CSV row per key and skipped node", func() {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, DriftReportPath+"?format=csv", nil))
+		Expect(rec.Code).To(Equal(http.StatusOK))
+		Expect(rec.Header().Get("Content-Type")).To(Equal("text/csv"))
+		rows, err := csv.NewReader(rec.Body).ReadAll()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(rows).To(HaveLen(8))
+		Expect(rows[0]).To(Equal(driftReportColumns))
+		Expect(rows).To(ContainElement([]string{"test-machineset", "failed", "failed-node", "", "", "", "", "Skipped", "", plan.ReasonMachineFailed}))
+		Expect(rows).To(ContainElement([]string{"test-machineset", "drifted", "drifted-node", "label", "foo", "bar", "old", KeyDrifted, plan.ChangeChanged, ""}))
+	})
+
+	It("should refuse unknown formats", func() {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, DriftReportPath+"?format=xml", nil))
+		Expect(rec.Code).To(Equal(http.StatusBadRequest))
+	})
+})
@@ -0,0 +1,120 @@
+package controllers
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	machinev1beta1 "github.com/openshift/api/machine/v1beta1"
+	"github.com/openshift/managed-node-metadata-operator/pkg/metrics"
+	"github.com/openshift/managed-node-metadata-operator/pkg/plan"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+var _ = Describe("DriftEvaluator", func() {
+	var (
+		fakeClient client.Client
+		evaluator  *DriftEvaluator
+		objects    []client.Object
+	)
+
+	s := newScheme()
+
+	newMachine := func(name string, nodeName string) *machinev1beta1.Machine {
+		return &machinev1beta1.Machine{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: "test",
+				Labels:    map[string]string{"pool": "test"},
+			},
+			Status: machinev1beta1.MachineStatus{
+				NodeRef: &corev1.ObjectReference{Name: nodeName},
+			},
+		}
+	}
+
+	newNode := func(name string, labels map[string]string, managedLabels string, taints []corev1.Taint) *corev1.Node {
+		return &corev1.Node{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        name,
+				Labels:      labels,
+				Annotations: map[string]string{"managed.openshift.com/customlabels": managedLabels},
+			},
+			Spec: corev1.NodeSpec{Taints: taints},
+			Status: corev1.NodeStatus{Conditions: []corev1.NodeCondition{
+				{Type: corev1.NodeReady, Status: corev1.ConditionTrue},
+			}},
+		}
+	}
+
+	taint := corev1.Taint{Key: "foo", Value: "bar", Effect: corev1.TaintEffectNoSchedule}
+
+	BeforeEach(func() {
+		metrics.NodesOutOfSync.Reset()
+		objects = []client.Object{
+			&machinev1beta1.MachineSet{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "test-machineset",
+					Namespace: "test",
+				},
+				Spec: machinev1beta1.MachineSetSpec{
+					Selector: metav1.LabelSelector{
+						MatchLabels: map[string]string{"pool": "test"},
+					},
+					Template: machinev1beta1.MachineTemplateSpec{
+						ObjectMeta: machinev1beta1.ObjectMeta{
+							Labels: map[string]string{"pool": "test"},
+						},
+						Spec: machinev1beta1.MachineSpec{
+							ObjectMeta: machinev1beta1.ObjectMeta{
+								Labels: map[string]string{"foo": "bar"},
+							},
+							Taints: []corev1.Taint{taint},
+						},
+					},
+				},
+			},
+			newMachine("in-sync", "in-sync-node"),
+			newNode("in-sync-node", map[string]string{"foo": "bar"}, "foo", []corev1.Taint{taint}),
+			newMachine("out-of-sync", "out-of-sync-node"),
+			newNode("out-of-sync-node", map[string]string{"foo": "old"}, "foo", nil),
+		}
+	})
+
+	JustBeforeEach(func() {
+		fakeClient = fake.NewClientBuilder().WithScheme(s).WithObjects(objects...).Build()
+		evaluator = &DriftEvaluator{
+			Client:    fakeClient,
+			Namespace: "test",
+		}
+	})
+
+	It("should export the nodes out of sync per kind", func() {
+		Expect(evaluator.Evaluate(context.TODO())).To(Succeed())
+
+		Expect(testutil.ToFloat64(metrics.NodesOutOfSync.WithLabelValues("test-machineset", plan.MetadataLabel))).To(Equal(1.0))
+		Expect(testutil.ToFloat64(metrics.NodesOutOfSync.WithLabelValues("test-machineset", plan.MetadataTaint))).To(Equal(1.0))
+	})
+
+	It("should not update the nodes", func() {
+		Expect(evaluator.Evaluate(context.TODO())).To(Succeed())
+
+		node := &corev1.Node{}
+		Expect(fakeClient.Get(context.TODO(), client.ObjectKey{Name: "out-of-sync-node"}, node)).To(Succeed())
+		Expect(node.Labels).To(HaveKeyWithValue("foo", "old"))
+		Expect(node.Spec.Taints).To(BeEmpty())
+	})
+
+	It("should remove the series of deleted machinesets", func() {
+		Expect(evaluator.Evaluate(context.TODO())).To(Succeed())
+		Expect(fakeClient.Delete(context.TODO(), objects[0])).To(Succeed())
+		Expect(evaluator.Evaluate(context.TODO())).To(Succeed())
+
+		Expect(testutil.CollectAndCount(metrics.NodesOutOfSync)).To(Equal(0))
+	})
+})
@@ -18,20 +18,31 @@ package controllers
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"reflect"
+	"sort"
 	"strings"
+	"time"
 
 	machinev1beta1 "github.com/openshift/api/machine/v1beta1"
+	"github.com/openshift/managed-node-metadata-operator/pkg/audit"
+	"github.com/openshift/managed-node-metadata-operator/pkg/history"
 	m "github.com/openshift/managed-node-metadata-operator/pkg/machine"
 	"github.com/openshift/managed-node-metadata-operator/pkg/metrics"
+	"github.com/openshift/managed-node-metadata-operator/pkg/plan"
+	"github.com/openshift/managed-node-metadata-operator/pkg/reasons"
+	"github.com/openshift/managed-node-metadata-operator/pkg/tracing"
+	"go.opentelemetry.io/otel/trace"
 	corev1 "k8s.io/api/core/v1"
 	k8serr "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/client-go/tools/record"
+	"k8s.io/client-go/util/workqueue"
 	"k8s.io/klog/v2"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/log"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 )
@@ -41,20 +52,39 @@ type MachinesetReconciler struct {
 	client.Client
 	Scheme   *runtime.Scheme
 	Recorder record.EventRecorder
-}
 
-type DuplicateTaintError struct {
-	Message string
+	// Provider gives access to the machine sets, machines and nodes, it defaults to the Machine API
+	Provider m.Provider
+	// Auditor records every label and taint change of the machines and nodes, no record is written if nil
+	Auditor audit.Sink
+	// SyncResults keeps the outcome of the last sync of each machineset for the sync state endpoint, if not nil
+	SyncResults *SyncResults
+	// History keeps the templates applied to the nodes per machineset generation, no history is kept if nil
+	History *history.Store
+	// SyncAutoscalerAnnotations keeps the cluster-autoscaler scale-from-zero annotations of the machinesets
+	// in step with their template labels and taints
+	SyncAutoscalerAnnotations bool
+
+	// ReservedLabels are node label keys that are never changed or removed
+	ReservedLabels []string
+	// ReservedTaints are node taints that are never removed
+	ReservedTaints []corev1.Taint
+
+	// overlapReports keeps the contested machines last reported for each machineset
+	overlapReports overlapReports
 }
 
-func (d DuplicateTaintError) Error() string {
-	return d.Message
-}
+// notReadyRequeueInterval is how long to wait before checking again whether the held back taints can be applied,
+// or whether the machines without a node got one
+const notReadyRequeueInterval = 30 * time.Second
 
 //+kubebuilder:rbac:groups=machine.openshift.io,resources=machinesets,verbs=get;list;watch;create;update;patch;delete
 //+kubebuilder:rbac:groups=machine.openshift.io,resources=machinesets/status,verbs=get;update;patch
 //+kubebuilder:rbac:groups=machine.openshift.io,resources=machines,verbs=get;list;watch;create;update;patch;delete
 //+kubebuilder:rbac:groups="",resources=nodes,verbs=get;list;watch;update;patch
+//+kubebuilder:rbac:groups="",resources=events,verbs=create;patch
+//+kubebuilder:rbac:groups=cluster.x-k8s.io,resources=machinesets;machinedeployments,verbs=get;list;watch;patch
+//+kubebuilder:rbac:groups=cluster.x-k8s.io,resources=machines,verbs=get;list;watch
 
 // Reconcile is part of the main kubernetes reconciliation loop which aims to
 // move the current state of the cluster closer to the desired state.
@@ -69,12 +99,20 @@ func (r *MachinesetReconciler) Reconcile(ctx context.Context, req ctrl.Request)
 	_ = log.FromContext(ctx)
 
 	// Fetch the MachineSet instance
-	machineSet := &machinev1beta1.MachineSet{}
+	machineSet := r.provider().NewMachineSet()
 	err := r.Get(ctx, req.NamespacedName, machineSet)
 	if err != nil {
 		if k8serr.IsNotFound(err) {
 			// Object not found, return.  Created objects are automatically garbage collected.
 			// For additional cleanup logic use finalizers.
+			metrics.DeleteMachineSetMetrics(r.machineSetKey(req.Name))
+			if r.SyncResults != nil {
+				r.SyncResults.Delete(r.machineSetKey(req.Name))
+			}
+			r.overlapReports.delete(r.machineSetKey(req.Name))
+			if err := r.deleteHistory(ctx, req.Namespace, req.Name); err != nil {
+				return reconcile.Result{}, fmt.Errorf("failed to drop the template history of machineset %s: %w", req.Name, err)
+			}
 			return reconcile.Result{}, nil
 		}
 		// Error reading the object - requeue the request.
@@ -84,235 +122,324 @@ func (r *MachinesetReconciler) Reconcile(ctx context.Context, req ctrl.Request)
 	return r.ProcessMachineSet(ctx, machineSet)
 }
 
-func (r *MachinesetReconciler) ProcessMachineSet(ctx context.Context, machineSet *machinev1beta1.MachineSet) (reconcile.Result, error) {
+func (r *MachinesetReconciler) ProcessMachineSet(ctx context.Context, machineSet client.Object) (reconcile.Result, error) {
+	key := r.machineSetKey(machineSet.GetName())
+	start := time.Now()
+	defer func() {
+		metrics.ObserveReconcileDuration(key, time.Since(start))
+	}()
+	ctx, span := tracing.Start(ctx, "ReconcileMachineSet",
+		tracing.MachineSetKey.String(machineSet.GetName()), tracing.ProviderKey.String(r.provider().Name()))
+
+	result, err := r.syncMachineSet(ctx, machineSet)
+	r.reportSyncResult(ctx, machineSet, err)
+	tracing.End(span, err)
+	if r.SyncResults != nil {
+		r.SyncResults.Record(key, result, err)
+	}
+	return syncResult(result, err)
+}
+
+// syncMachineSet syncs the template labels and taints of the machineset to its machines and their nodes
+func (r *MachinesetReconciler) syncMachineSet(ctx context.Context, machineSet client.Object) (reconcile.Result, error) {
+	if r.SyncAutoscalerAnnotations {
+		if err := r.updateAutoscalerAnnotations(ctx, machineSet); err != nil {
+			return reconcile.Result{}, err
+		}
+	}
+
+	// The time to converge is only observed, failing to record it doesn't fail the sync
+	start, converged, err := r.startConvergence(ctx, machineSet)
+	if err != nil {
+		klog.Errorf("failed to record the sync start of machineset %s: %v", machineSet.GetName(), err)
+	}
+	if !converged {
+		ctx = withConvergenceStart(ctx, start)
+	}
+
 	// Get machines for machineset
-	machines, err := m.GetMachinesForMachineSet(r.Client, machineSet)
+	machines, overlaps, err := r.getMachines(ctx, machineSet)
 	if err != nil {
 		return reconcile.Result{}, err
 	}
+	// Machine API machines without a controller can be selected by several machinesets
+	if mapiMachineSet, ok := machineSet.(*machinev1beta1.MachineSet); ok {
+		r.reportContestedMachines(ctx, mapiMachineSet, overlaps)
+	}
 
-	for _, machine := range machines {
-		if machine.Status.NodeRef == nil || machine.Status.NodeRef.Name == "" {
-			continue
+	result, err := r.processMachines(ctx, machineSet, machines, overlaps)
+	if err == nil && result.RequeueAfter == 0 && !converged {
+		if convergeErr := r.completeConvergence(ctx, machineSet, start); convergeErr != nil {
+			klog.Errorf("failed to record the convergence of machineset %s: %v", machineSet.GetName(), convergeErr)
 		}
-		node, err := m.GetNodeForMachine(r.Client, machine)
-		if err != nil {
-			klog.Errorf("failed to fetch node for machine %s", machine.Name)
-			metrics.IncreaseNodeReconciliationFailure(machine.Name)
-			return reconcile.Result{}, err
+		if historyErr := r.recordHistory(ctx, machineSet); historyErr != nil {
+			klog.Errorf("failed to record the template history of machineset %s: %v", machineSet.GetName(), historyErr)
 		}
-		expectedLabels := r.getExpectedLabels(ctx, machineSet, machine, node)
-		if err != nil {
-			return reconcile.Result{}, err
+	}
+	return result, err
+}
+
+// processMachines syncs the template labels and taints of the machineset to the given machines and their nodes.
+// The machines the plan skips, such as the failed ones and the ones selected by the other machinesets listed in
+// overlaps, are left alone, and the sync is retried until the machines without a node get one. Nodes that aren't
+// Ready get their labels, but NoExecute taints are held back until they're Ready, so the workloads already scheduled
+// on them aren't evicted while they come up.
+func (r *MachinesetReconciler) processMachines(ctx context.Context, machineSet client.Object, machines []client.Object, overlaps map[string][]string) (reconcile.Result, error) {
+	logger := log.FromContext(ctx)
+	key := r.machineSetKey(machineSet.GetName())
+	desiredLabels := r.provider().DesiredLabels(machineSet)
+	desiredTaints := r.provider().DesiredTaints(machineSet)
+	result := reconcile.Result{}
+	trace.SpanFromContext(ctx).SetAttributes(tracing.MachinesKey.Int(len(machines)))
+	r.recordManagedKeys(key, desiredLabels, desiredTaints)
+	for label := range desiredLabels {
+		if r.planner().IsReservedLabel(label) {
+			logger.Info("ignoring reserved label of the template", "label", label, "reason", reasons.ReasonReservedKey)
 		}
-		// Update labels in machine
-		err = r.updateLabelsInMachine(ctx, machine, expectedLabels)
-		if err != nil {
-			metrics.IncreaseNodeReconciliationFailure(node.Name)
-			return reconcile.Result{}, err
+	}
+
+	nodes, err := r.getNodes(ctx, machines, overlaps)
+	if err != nil {
+		klog.Errorf("failed to fetch the nodes of machineset %s: %v", machineSet.GetName(), err)
+		var nodeErr *nodeError
+		if errors.As(err, &nodeErr) {
+			metrics.IncreaseNodeReconciliationFailure(key, nodeErr.Node, string(reasons.ReasonFor(err)))
 		}
-		// Update taints in machine
-		err = r.updateTaintsInMachine(ctx, machineSet, machine)
-		if err != nil {
-			metrics.IncreaseNodeReconciliationFailure(node.Name)
-			return reconcile.Result{}, err
+		return reconcile.Result{}, err
+	}
+	machineSetPlan := r.planner().Plan(machineSet, machines, nodes, overlaps)
+
+	for i, machine := range machines {
+		machinePlan := machineSetPlan.Machines[i]
+		switch machinePlan.Skipped {
+		case plan.ReasonMachineFailed:
+			logger.Info("skipping failed machine", "machine", machine.GetName())
+			metrics.IncreaseLifecycleDecision(key, metrics.DecisionFailedMachineSkipped)
+			continue
+		case plan.ReasonNoNode:
+			// The machineset hasn't converged until the node joins, nothing else triggers a sync when it does
+			result.RequeueAfter = notReadyRequeueInterval
+			continue
+		case plan.ReasonMachineSelectorOverlap:
+			continue
 		}
-		//Update labels in node
-		err = r.updateLabelsInNode(ctx, node, expectedLabels)
-		if err != nil {
-			metrics.IncreaseNodeReconciliationFailure(node.Name)
-			return reconcile.Result{}, err
+		node := nodes[machinePlan.Node]
+		if !m.IsNodeReady(node) {
+			logger.Info("syncing node that isn't ready", "machine", machine.GetName(), "node", node.Name)
+			metrics.IncreaseLifecycleDecision(key, metrics.DecisionNodeNotReady)
+			if len(machinePlan.HeldTaints) > 0 {
+				logger.Info("holding back NoExecute taints until the node is ready", "node", node.Name, "taints", machinePlan.HeldTaints)
+				metrics.IncreaseLifecycleDecision(key, metrics.DecisionNoExecuteTaintsHeld)
+				result.RequeueAfter = notReadyRequeueInterval
+			}
+		}
+		metrics.IncreaseLabelConflicts(key, len(machinePlan.Skips(plan.ReasonLabelConflict)))
+		machineCtx := auditContext(ctx, machineSet.GetName(), machineSet.GetGeneration(), machine.GetName(), node.Name)
+		// Machine API machines carry the node labels and taints in their spec
+		if mapiMachine, ok := machine.(*machinev1beta1.Machine); ok {
+			err = r.syncMachine(machineCtx, mapiMachine, node, &machinePlan)
+			if err != nil {
+				metrics.IncreaseNodeReconciliationFailure(key, node.Name, string(reasons.ReasonFor(err)))
+				return reconcile.Result{}, err
+			}
 		}
-		// Update taints in node
-		err = r.updateTaintsInNode(ctx, machine, node)
+		outOfSync := !machinePlan.NodeInSync()
+		err = r.syncNode(machineCtx, machine, node, &machinePlan)
 		if err != nil {
-			metrics.IncreaseNodeReconciliationFailure(node.Name)
-			if derr, ok := err.(DuplicateTaintError); ok {
-				log.Log.Info("found duplicate taint on machine spec", "error", derr.Message)
-				return reconcile.Result{Requeue: false}, nil
+			metrics.IncreaseNodeReconciliationFailure(key, node.Name, string(reasons.ReasonFor(err)))
+			var derr plan.DuplicateTaintError
+			if errors.As(err, &derr) {
+				log.Log.Info("found duplicate taint on machine spec", "error", derr.Message, "reason", derr.Reason())
 			}
 			return reconcile.Result{}, err
 		}
+		// Nodes with held back taints converge once they're Ready
+		if start, ok := convergenceStart(ctx); ok && outOfSync && len(machinePlan.HeldTaints) == 0 {
+			metrics.ObserveNodeConvergence(key, time.Since(start))
+		}
 	}
-	return reconcile.Result{}, nil
+	return result, nil
 }
 
-func (r *MachinesetReconciler) getExpectedLabels(ctx context.Context, machineSet *machinev1beta1.MachineSet, machine *machinev1beta1.Machine, node *corev1.Node) map[string]string {
-	result := machineSet.Spec.Template.Spec.Labels
+// syncMachine applies the label and taint operations of the plan to the spec of the machine, in a span of its own
+func (r *MachinesetReconciler) syncMachine(ctx context.Context, machine *machinev1beta1.Machine, node *corev1.Node, machinePlan *plan.MachinePlan) (err error) {
+	ctx, span := tracing.Start(ctx, "UpdateMachine", tracing.MachineKey.String(machine.Name), tracing.NodeKey.String(node.Name))
+	defer func() { tracing.End(span, err) }()
+
+	// Update labels in machine
+	if err := r.updateLabelsInMachine(ctx, machine, machinePlan); err != nil {
+		return err
+	}
+	// Update taints in machine
+	return r.updateTaintsInMachine(ctx, machine, machinePlan)
+}
 
-	currentAnnotationValue := node.Annotations["managed.openshift.com/customlabels"]
-	// Labels that are already set at the Node, but weren't set by the machine resource are ignored to avoid overwriting them
-	for label := range machineSet.Spec.Template.Spec.Labels {
-		_, nodeHasLabel := node.Labels[label]
-		_, machineHasLabel := machine.Spec.Labels[label]
+// syncNode applies the label and taint operations of the plan to the node, in a span of its own
+func (r *MachinesetReconciler) syncNode(ctx context.Context, machine client.Object, node *corev1.Node, machinePlan *plan.MachinePlan) (err error) {
+	ctx, span := tracing.Start(ctx, "UpdateNode", tracing.MachineKey.String(machine.GetName()), tracing.NodeKey.String(node.Name))
+	defer func() { tracing.End(span, err) }()
 
-		// If the label exists in the annotation, it was previously set by MNMO, so it can be updated
-		isSetByManageNodeMetadataOperator := false
-		for _, lk := range strings.Split(currentAnnotationValue, ",") {
-			if lk == label {
-				isSetByManageNodeMetadataOperator = true
-			}
-		}
-		if nodeHasLabel && !machineHasLabel && !isSetByManageNodeMetadataOperator {
-			delete(result, label)
-		}
+	//Update labels in node
+	if err := r.updateLabelsInNode(ctx, node, machinePlan); err != nil {
+		return err
 	}
+	// Update taints in node
+	return r.updateTaintsInNode(ctx, node, machinePlan)
+}
 
-	return result
+// recordManagedKeys exports the number of labels and taints managed for the nodes of the machineset
+func (r *MachinesetReconciler) recordManagedKeys(machineSet string, desiredLabels map[string]string, desiredTaints []corev1.Taint) {
+	managedLabels := 0
+	for label := range desiredLabels {
+		if !r.planner().IsReservedLabel(label) {
+			managedLabels++
+		}
+	}
+	uniqueTaints, _ := plan.CheckDuplicateTaints(desiredTaints)
+	metrics.SetManagedKeys(machineSet, plan.MetadataLabel, managedLabels)
+	metrics.SetManagedKeys(machineSet, plan.MetadataTaint, len(uniqueTaints))
 }
 
-func (r *MachinesetReconciler) updateLabelsInMachine(ctx context.Context, m *machinev1beta1.Machine, expectedLabels map[string]string) error {
-	if reflect.DeepEqual(expectedLabels, m.Spec.Labels) {
+func (r *MachinesetReconciler) updateLabelsInMachine(ctx context.Context, m *machinev1beta1.Machine, machinePlan *plan.MachinePlan) error {
+	changes := machinePlan.Changes(plan.KindMachine, plan.MetadataLabel)
+	if len(changes) == 0 {
 		return nil
 	}
-	m.Spec.Labels = expectedLabels
+	m.Spec.Labels = machinePlan.Labels
 	err := r.Update(ctx, m)
 	if err != nil {
 		klog.Errorf("failed to update label in %s", m.Name)
 		return err
 	}
+	r.recordChanges(ctx, plan.KindMachine, plan.MetadataLabel, changes)
 	return nil
 }
 
-// updateTaintsInMachine compares taints of machineset vs machine and updates them if they're not the same
-func (r *MachinesetReconciler) updateTaintsInMachine(ctx context.Context, machineSet *machinev1beta1.MachineSet, machine *machinev1beta1.Machine) error {
-	if !reflect.DeepEqual(machineSet.Spec.Template.Spec.Taints, machine.Spec.Taints) {
-		machine.Spec.Taints = machineSet.Spec.Template.Spec.Taints
-		if err := r.Update(ctx, machine); err != nil {
-			return fmt.Errorf("failed to update taint for machine %s: %w", machine.Name, err)
-		}
+// updateTaintsInMachine updates the taints of the machine if the plan changes them
+func (r *MachinesetReconciler) updateTaintsInMachine(ctx context.Context, machine *machinev1beta1.Machine, machinePlan *plan.MachinePlan) error {
+	changes := machinePlan.Changes(plan.KindMachine, plan.MetadataTaint)
+	if len(changes) == 0 {
+		return nil
 	}
-
+	machine.Spec.Taints = machinePlan.Taints
+	if err := r.Update(ctx, machine); err != nil {
+		return fmt.Errorf("failed to update taint for machine %s: %w", machine.Name, err)
+	}
+	r.recordChanges(ctx, plan.KindMachine, plan.MetadataTaint, changes)
 	return nil
 }
 
-func (r *MachinesetReconciler) updateLabelsInNode(ctx context.Context, node *corev1.Node, expectedLabels map[string]string) error {
-	// Build temp map to store current custom labels in node
-	currentNodeLabels := map[string]string{}
-	// Check node Annotations and compare with Labels to get custom labels
-	currentAnnotationValue, ok := node.Annotations["managed.openshift.com/customlabels"]
-	if ok {
-		for _, lk := range strings.Split(currentAnnotationValue, ",") {
-			if lv, nodeHasLabel := node.Labels[lk]; nodeHasLabel {
-				currentNodeLabels[lk] = lv
-				// Delete label if it's present in node but not in machine
-				if _, machineHasLabel := expectedLabels[lk]; !machineHasLabel {
-					delete(node.Labels, lk)
-				}
-			}
-		}
-	}
-
-	// Compare custom labels with labels in machine
-	if reflect.DeepEqual(expectedLabels, currentNodeLabels) {
+// updateLabelsInNode updates the labels of the node and the annotation recording the ones set by the operator, if
+// the plan changes them
+func (r *MachinesetReconciler) updateLabelsInNode(ctx context.Context, node *corev1.Node, machinePlan *plan.MachinePlan) error {
+	changes := machinePlan.Changes(plan.KindNode, plan.MetadataLabel)
+	if len(changes) == 0 {
 		return nil
 	}
-	// Update Annotations and Labels when new labels are added in machine
-	newAnnotationValue := ""
-	for newKey, newVal := range expectedLabels {
-		node.Labels[newKey] = newVal
-		if newAnnotationValue != "" {
-			newAnnotationValue += ","
+	if node.Labels == nil {
+		node.Labels = map[string]string{}
+	}
+	for _, change := range changes {
+		if change.Change == plan.ChangeRemoved {
+			delete(node.Labels, change.Key)
 		}
-		newAnnotationValue += newKey
 	}
+	keys := make([]string, 0, len(machinePlan.Labels))
+	for k, v := range machinePlan.Labels {
+		node.Labels[k] = v
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
 	if node.Annotations == nil {
 		node.Annotations = map[string]string{}
 	}
-	node.Annotations["managed.openshift.com/customlabels"] = newAnnotationValue
+	node.Annotations[plan.CustomLabelsAnnotation] = strings.Join(keys, ",")
 
 	err := r.Update(ctx, node)
 	if err != nil {
 		klog.Errorf("failed to update label in %s", node.Name)
 		return err
 	}
-
+	r.recordChanges(ctx, plan.KindNode, plan.MetadataLabel, changes)
 	return nil
 }
 
-// updateTaintsInNode ensures all taints on a node are expected. Expected taints on a node are any taints specified
-// on a machine as well as any other NoSchedule taints.
-func (r *MachinesetReconciler) updateTaintsInNode(ctx context.Context, machine *machinev1beta1.Machine, node *corev1.Node) error {
-	noScheduleTaint := &corev1.Taint{
-		Key:    corev1.TaintNodeUnschedulable,
-		Effect: corev1.TaintEffectNoSchedule,
-	}
-
-	expectedTaints, duplicateTaintErr := CheckDuplicateTaints(machine.Spec.Taints)
-	// expectedTaints := machine.Spec.Taints
-	for _, taint := range node.Spec.Taints {
-		if taint.MatchTaint(noScheduleTaint) {
-			expectedTaints = append(expectedTaints, taint)
-		}
-	}
-
-	// If there are any differences between expected taints and the node taints, update them
-	toAdd, toRemove := TaintSliceDiff(expectedTaints, node.Spec.Taints)
-	if len(toAdd) > 0 || len(toRemove) > 0 {
-		node.Spec.Taints = expectedTaints
+// updateTaintsInNode updates the taints of the node if the plan changes them. Expected taints on a node are any
+// taints specified on the machineset as well as the node.kubernetes.io taints and reserved taints. The
+// DuplicateTaintError of the plan is returned, if any, once the node is updated.
+func (r *MachinesetReconciler) updateTaintsInNode(ctx context.Context, node *corev1.Node, machinePlan *plan.MachinePlan) error {
+	changes := machinePlan.Changes(plan.KindNode, plan.MetadataTaint)
+	if len(changes) > 0 {
+		node.Spec.Taints = machinePlan.NodeTaints
 		if err := r.Update(ctx, node); err != nil {
 			return fmt.Errorf("failed to update taints for node %s: %w", node.Name, err)
 		}
+		r.recordChanges(ctx, plan.KindNode, plan.MetadataTaint, changes)
 	}
 
-	return duplicateTaintErr
+	return machinePlan.DuplicateTaintErr
 }
 
-func CheckDuplicateTaints(taints []corev1.Taint) ([]corev1.Taint, error) {
-	var err error = nil
-	tmpTaints := make(map[corev1.Taint]bool, len(taints))
-	uniqueTaints := make([]corev1.Taint, 0)
-	for _, taint := range taints {
-		if _, value := tmpTaints[taint]; !value {
-			tmpTaints[taint] = true
-			uniqueTaints = append(uniqueTaints, taint)
-		} else {
-			err = DuplicateTaintError{Message: fmt.Sprintf("duplicate taint in machine spec found - will be ignored: %v", taint)}
-		}
-	}
-	return uniqueTaints, err
+// recordChanges audits, counts and traces the applied label or taint operations of a machine or node
+func (r *MachinesetReconciler) recordChanges(ctx context.Context, kind string, metadata string, changes []plan.Operation) {
+	added, changed, removed := plan.CountChanges(changes)
+	r.auditChanges(ctx, changes)
+	metrics.RecordMetadataChanges(kind, metadata, added, changed, removed)
+	tracing.RecordChanges(ctx, metadata, added, changed, removed)
 }
 
-// TaintExists checks if the given taint exists in list of taints. Returns true if exists false otherwise.
-func TaintExists(taints []corev1.Taint, taintToFind *corev1.Taint) bool {
-	for _, taint := range taints {
-		if taint.MatchTaint(taintToFind) {
-			return true
-		}
+// provider returns the configured Provider, or the Machine API provider if none is set
+func (r *MachinesetReconciler) provider() m.Provider {
+	if r.Provider == nil {
+		return m.MachineAPIProvider{}
 	}
-	return false
+	return r.Provider
 }
 
-// TaintSliceDiff finds the difference between two taint slices and
-// returns all new and removed elements of the new slice relative to the old slice.
-// for example:
-// input: expected=[a b] actual=[a c]
-// output: taintsToAdd=[b] taintsToRemove=[c]
-func TaintSliceDiff(expected, actual []corev1.Taint) ([]*corev1.Taint, []*corev1.Taint) {
-	var (
-		taintsToAdd    []*corev1.Taint
-		taintsToRemove []*corev1.Taint
-	)
-
-	for i := range expected {
-		if !TaintExists(actual, &expected[i]) {
-			taintsToAdd = append(taintsToAdd, &expected[i])
-		}
+// machineSetKey returns the key of the machine set in the metrics and the sync results
+func (r *MachinesetReconciler) machineSetKey(name string) string {
+	return m.MachineSetKey(r.provider(), name)
+}
+
+// planner returns the planner of the syncs of the reconciler
+func (r *MachinesetReconciler) planner() plan.Planner {
+	return plan.Planner{
+		Provider:       r.provider(),
+		ReservedLabels: r.ReservedLabels,
+		ReservedTaints: r.ReservedTaints,
 	}
+}
 
-	for i := range actual {
-		if !TaintExists(expected, &actual[i]) {
-			taintsToRemove = append(taintsToRemove, &actual[i])
-		}
+// syncResult returns the result of the reconcile for the outcome of a sync. Duplicate taints come from the
+// template, retrying doesn't help until it's fixed.
+func syncResult(result reconcile.Result, err error) (reconcile.Result, error) {
+	var duplicateTaintErr plan.DuplicateTaintError
+	if errors.As(err, &duplicateTaintErr) {
+		return reconcile.Result{Requeue: false}, nil
 	}
+	return result, err
+}
 
-	return taintsToAdd, taintsToRemove
+// metricsCleanupHandler removes the metrics of deleted nodes, and of the nodes of deleted machines, so
+// autoscaled clusters don't build up stale series. It never enqueues a reconcile.
+func metricsCleanupHandler() handler.EventHandler {
+	return handler.Funcs{
+		DeleteFunc: func(_ context.Context, e event.DeleteEvent, _ workqueue.TypedRateLimitingInterface[reconcile.Request]) {
+			if node, ok := e.Object.(*corev1.Node); ok {
+				metrics.DeleteNodeMetrics(node.Name)
+				return
+			}
+			metrics.DeleteNodeMetrics(m.GetNodeName(e.Object))
+		},
+	}
 }
 
 // SetupWithManager sets up the controller with the Manager.
 func (r *MachinesetReconciler) SetupWithManager(mgr ctrl.Manager) error {
 	return ctrl.NewControllerManagedBy(mgr).
-		For(&machinev1beta1.MachineSet{}).
-		Named("machineset_controller").
+		For(r.provider().NewMachineSet()).
+		Watches(&corev1.Node{}, metricsCleanupHandler()).
+		Watches(r.provider().NewMachine(), metricsCleanupHandler()).
+		Named(r.provider().Name() + "_controller").
 		Complete(r)
 }
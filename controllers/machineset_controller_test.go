@@ -10,15 +10,20 @@ import (
 
 	machinev1beta1 "github.com/openshift/api/machine/v1beta1"
 	m "github.com/openshift/managed-node-metadata-operator/pkg/machine"
+	"github.com/openshift/managed-node-metadata-operator/pkg/metrics"
+	"github.com/openshift/managed-node-metadata-operator/pkg/plan"
+	"github.com/openshift/managed-node-metadata-operator/pkg/reasons"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/kubernetes/scheme"
 	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/event"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 )
 
@@ -27,6 +32,12 @@ type mocks struct {
 	mockCtrl       *gomock.Controller
 }
 
+// planMachine returns the plan of the sync of the machine and its node for the labels and taints of the template
+func planMachine(r *MachinesetReconciler, labels map[string]string, taints []corev1.Taint, machine *machinev1beta1.Machine, node *corev1.Node) *plan.MachinePlan {
+	machinePlan := r.planner().PlanMachine(labels, taints, machine, node)
+	return &machinePlan
+}
+
 var _ = Describe("MachinesetController", func() {
 	var (
 		machineSet     machinev1beta1.MachineSet
@@ -41,13 +52,11 @@ var _ = Describe("MachinesetController", func() {
 		localObjects   []client.Object
 	)
 
-	s := runtime.NewScheme()
-	if err := corev1.AddToScheme(s); err != nil {
-		fmt.Printf("failed adding apis to scheme in machineset controller tests")
-	}
-	if err := machinev1beta1.AddToScheme(s); err != nil {
-		fmt.Printf("failed adding apis to scheme in machineset controller tests")
-	}
+	s := newScheme()
+
+	BeforeEach(func() {
+		ctx = context.TODO()
+	})
 
 	Describe("Updating labels in machine", func() {
 		var (
@@ -113,9 +122,9 @@ var _ = Describe("MachinesetController", func() {
 			}
 
 			r = &MachinesetReconciler{
-				mockObjects.fakeKubeClient,
-				scheme.Scheme,
-				record.NewFakeRecorder(32),
+				Client:   mockObjects.fakeKubeClient,
+				Scheme:   scheme.Scheme,
+				Recorder: record.NewFakeRecorder(32),
 			}
 		})
 
@@ -130,7 +139,7 @@ var _ = Describe("MachinesetController", func() {
 			})
 
 			It("should update labels in machine", func() {
-				err = r.updateLabelsInMachine(ctx, &machine, newLabelsInMachineSet)
+				err = r.updateLabelsInMachine(ctx, &machine, planMachine(r, newLabelsInMachineSet, nil, &machine, &corev1.Node{}))
 				Expect(err).NotTo(HaveOccurred())
 			})
 		})
@@ -142,7 +151,7 @@ var _ = Describe("MachinesetController", func() {
 			})
 
 			It("should delete label in machine", func() {
-				err = r.updateLabelsInMachine(ctx, &machine, newLabelsInMachineSet)
+				err = r.updateLabelsInMachine(ctx, &machine, planMachine(r, newLabelsInMachineSet, nil, &machine, &corev1.Node{}))
 				Expect(err).NotTo(HaveOccurred())
 				Expect(machine.Spec.Labels).To(Equal(machineSet.Spec.Template.Spec.Labels))
 			})
@@ -155,7 +164,7 @@ var _ = Describe("MachinesetController", func() {
 			})
 
 			It("should not change labels", func() {
-				err = r.updateLabelsInMachine(ctx, &machine, newLabelsInMachineSet)
+				err = r.updateLabelsInMachine(ctx, &machine, planMachine(r, newLabelsInMachineSet, nil, &machine, &corev1.Node{}))
 				Expect(err).NotTo(HaveOccurred())
 				Expect(machine.Spec.Labels).To(Equal(machineSet.Spec.Template.Spec.Labels))
 			})
@@ -273,9 +282,9 @@ var _ = Describe("MachinesetController", func() {
 			}
 
 			r = &MachinesetReconciler{
-				mockObjects.fakeKubeClient,
-				scheme.Scheme,
-				record.NewFakeRecorder(32),
+				Client:   mockObjects.fakeKubeClient,
+				Scheme:   scheme.Scheme,
+				Recorder: record.NewFakeRecorder(32),
 			}
 		})
 
@@ -304,7 +313,7 @@ var _ = Describe("MachinesetController", func() {
 			})
 
 			It("should update taints in machine", func() {
-				err = r.updateTaintsInMachine(ctx, &machineSet, &machine)
+				err = r.updateTaintsInMachine(ctx, &machine, planMachine(r, nil, machineSet.Spec.Template.Spec.Taints, &machine, &corev1.Node{}))
 				Expect(err).NotTo(HaveOccurred())
 				Expect(machine.Spec.Taints).To(Equal(updatedMachine.Spec.Taints))
 			})
@@ -324,7 +333,7 @@ var _ = Describe("MachinesetController", func() {
 			})
 
 			It("should delete taint in machine", func() {
-				err = r.updateTaintsInMachine(ctx, &machineSet, &machine)
+				err = r.updateTaintsInMachine(ctx, &machine, planMachine(r, nil, machineSet.Spec.Template.Spec.Taints, &machine, &corev1.Node{}))
 				Expect(err).NotTo(HaveOccurred())
 				Expect(machine.Spec.Taints).To(Equal(updatedMachine.Spec.Taints))
 			})
@@ -339,7 +348,7 @@ var _ = Describe("MachinesetController", func() {
 			})
 
 			It("should not change taints", func() {
-				err = r.updateTaintsInMachine(ctx, &machineSet, &machine)
+				err = r.updateTaintsInMachine(ctx, &machine, planMachine(r, nil, machineSet.Spec.Template.Spec.Taints, &machine, &corev1.Node{}))
 				Expect(err).NotTo(HaveOccurred())
 				Expect(machine.Spec.Taints).To(Equal(updatedMachine.Spec.Taints))
 			})
@@ -385,9 +394,9 @@ var _ = Describe("MachinesetController", func() {
 			}
 
 			r = &MachinesetReconciler{
-				mockObjects.fakeKubeClient,
-				scheme.Scheme,
-				record.NewFakeRecorder(32),
+				Client:   mockObjects.fakeKubeClient,
+				Scheme:   scheme.Scheme,
+				Recorder: record.NewFakeRecorder(32),
 			}
 		})
 
@@ -402,7 +411,7 @@ var _ = Describe("MachinesetController", func() {
 			})
 
 			It("should update labels in node", func() {
-				err = r.updateLabelsInNode(ctx, &node, newLabelsInMachine)
+				err = r.updateLabelsInNode(ctx, &node, planMachine(r, newLabelsInMachine, nil, &machine, &node))
 				Expect(err).NotTo(HaveOccurred())
 				Expect(machine.Spec.Labels).To(Equal(node.Labels))
 			})
@@ -417,7 +426,7 @@ var _ = Describe("MachinesetController", func() {
 
 			It("should update labels in node", func() {
 				var myNilMap map[string]string
-				err = r.updateLabelsInNode(ctx, &node, newLabelsInMachine)
+				err = r.updateLabelsInNode(ctx, &node, planMachine(r, newLabelsInMachine, nil, &machine, &node))
 				Expect(err).NotTo(HaveOccurred())
 				Expect(node.Labels).To(Equal(myNilMap))
 			})
@@ -430,7 +439,7 @@ var _ = Describe("MachinesetController", func() {
 			})
 
 			It("should not change labels in node", func() {
-				err = r.updateLabelsInNode(ctx, &node, newLabelsInMachine)
+				err = r.updateLabelsInNode(ctx, &node, planMachine(r, newLabelsInMachine, nil, &machine, &node))
 				Expect(err).NotTo(HaveOccurred())
 				Expect(machine.Spec.Labels).To(Equal(node.Labels))
 			})
@@ -487,9 +496,9 @@ var _ = Describe("MachinesetController", func() {
 			}
 
 			r = &MachinesetReconciler{
-				mockObjects.fakeKubeClient,
-				scheme.Scheme,
-				record.NewFakeRecorder(32),
+				Client:   mockObjects.fakeKubeClient,
+				Scheme:   scheme.Scheme,
+				Recorder: record.NewFakeRecorder(32),
 			}
 		})
 
@@ -518,7 +527,7 @@ var _ = Describe("MachinesetController", func() {
 			})
 
 			It("should update taints in node", func() {
-				err = r.updateTaintsInNode(ctx, &machine, &node)
+				err = r.updateTaintsInNode(ctx, &node, planMachine(r, nil, machine.Spec.Taints, &machine, &node))
 				Expect(err).NotTo(HaveOccurred())
 				Expect(node.Spec.Taints).To(Equal(updatedNode.Spec.Taints))
 			})
@@ -543,7 +552,7 @@ var _ = Describe("MachinesetController", func() {
 			})
 
 			It("should update taints in node", func() {
-				err = r.updateTaintsInNode(ctx, &machine, &node)
+				err = r.updateTaintsInNode(ctx, &node, planMachine(r, nil, machine.Spec.Taints, &machine, &node))
 				Expect(err).NotTo(HaveOccurred())
 				Expect(node.Spec.Taints).To(Equal(updatedNode.Spec.Taints))
 			})
@@ -563,7 +572,7 @@ var _ = Describe("MachinesetController", func() {
 			})
 
 			It("should delete taint in node", func() {
-				err = r.updateTaintsInNode(ctx, &machine, &node)
+				err = r.updateTaintsInNode(ctx, &node, planMachine(r, nil, machine.Spec.Taints, &machine, &node))
 				Expect(err).NotTo(HaveOccurred())
 				Expect(updatedNode.Spec.Taints).To(Equal(updatedTaintsInNode))
 			})
@@ -579,7 +588,7 @@ var _ = Describe("MachinesetController", func() {
 			})
 
 			It("should not change taints", func() {
-				err = r.updateTaintsInNode(ctx, &machine, &node)
+				err = r.updateTaintsInNode(ctx, &node, planMachine(r, nil, machine.Spec.Taints, &machine, &node))
 				Expect(err).NotTo(HaveOccurred())
 				Expect(node.Spec.Taints).To(Equal(updatedNode.Spec.Taints))
 			})
@@ -609,7 +618,7 @@ var _ = Describe("MachinesetController", func() {
 				}
 			})
 			It("it should update the node, but indicate the error", func() {
-				err = r.updateTaintsInNode(ctx, &machine, &node)
+				err = r.updateTaintsInNode(ctx, &node, planMachine(r, nil, machine.Spec.Taints, &machine, &node))
 				Expect(err).To(HaveOccurred())
 				Expect(node.Spec.Taints).To(Equal(updatedNode.Spec.Taints))
 			})
@@ -638,9 +647,9 @@ var _ = Describe("MachinesetController", func() {
 					mockCtrl:       gomock.NewController(GinkgoT()),
 				}
 				r = &MachinesetReconciler{
-					mockObjects.fakeKubeClient,
-					scheme.Scheme,
-					record.NewFakeRecorder(32),
+					Client:   mockObjects.fakeKubeClient,
+					Scheme:   scheme.Scheme,
+					Recorder: record.NewFakeRecorder(32),
 				}
 			})
 
@@ -688,9 +697,9 @@ var _ = Describe("MachinesetController", func() {
 					mockCtrl:       gomock.NewController(GinkgoT()),
 				}
 				r = &MachinesetReconciler{
-					mockObjects.fakeKubeClient,
-					scheme.Scheme,
-					record.NewFakeRecorder(32),
+					Client:   mockObjects.fakeKubeClient,
+					Scheme:   scheme.Scheme,
+					Recorder: record.NewFakeRecorder(32),
 				}
 			})
 
@@ -706,154 +715,249 @@ var _ = Describe("MachinesetController", func() {
 		})
 	})
 
-	Describe("Taint utility functions", func() {
-		Describe("CheckDuplicateTaints", func() {
-			Context("When taints are unique", func() {
-				It("should return all taints without error", func() {
-					taints := []corev1.Taint{
-						{Key: "key1", Value: "value1", Effect: corev1.TaintEffectNoSchedule},
-						{Key: "key2", Value: "value2", Effect: corev1.TaintEffectPreferNoSchedule},
-					}
+	Describe("Processing a Cluster API MachineSet", func() {
+		var capiMachineSet *unstructured.Unstructured
 
-					result, err := CheckDuplicateTaints(taints)
-					Expect(err).NotTo(HaveOccurred())
-					Expect(result).To(Equal(taints))
-				})
-			})
+		BeforeEach(func() {
+			capiMachineSet = &unstructured.Unstructured{Object: map[string]interface{}{
+				"apiVersion": "cluster.x-k8s.io/v1beta1",
+				"kind":       "MachineSet",
+				"metadata": map[string]interface{}{
+					"name":      "test-machineset",
+					"namespace": "test",
+				},
+				"spec": map[string]interface{}{
+					"selector": map[string]interface{}{
+						"matchLabels": map[string]interface{}{"pool": "test"},
+					},
+					"template": map[string]interface{}{
+						"metadata": map[string]interface{}{
+							"labels": map[string]interface{}{"pool": "test", "foo": "bar"},
+						},
+						"spec": map[string]interface{}{
+							"clusterName": "test-cluster",
+						},
+					},
+				},
+			}}
+			capiMachine := &unstructured.Unstructured{Object: map[string]interface{}{
+				"apiVersion": "cluster.x-k8s.io/v1beta1",
+				"kind":       "Machine",
+				"metadata": map[string]interface{}{
+					"name":      "test-machine",
+					"namespace": "test",
+					"labels":    map[string]interface{}{"pool": "test"},
+				},
+				"status": map[string]interface{}{
+					"nodeRef": map[string]interface{}{"name": "test-node"},
+				},
+			}}
+			node = corev1.Node{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:   "test-node",
+					Labels: map[string]string{},
+				},
+				Spec: corev1.NodeSpec{
+					Taints: []corev1.Taint{{Key: "bootstrap", Value: "true", Effect: corev1.TaintEffectNoSchedule}},
+				},
+			}
+			mockObjects = &mocks{
+				fakeKubeClient: fake.NewClientBuilder().WithScheme(s).WithObjects(capiMachineSet, capiMachine, &node).Build(),
+				mockCtrl:       gomock.NewController(GinkgoT()),
+			}
+			r = &MachinesetReconciler{
+				Client:   mockObjects.fakeKubeClient,
+				Scheme:   scheme.Scheme,
+				Recorder: record.NewFakeRecorder(32),
+				Provider: m.ClusterAPIProvider{Version: "v1beta1", Kind: m.ClusterAPIMachineSetKind},
+			}
+		})
 
-			Context("When taints have duplicates", func() {
-				It("should return unique taints and error", func() {
-					taints := []corev1.Taint{
-						{Key: "key1", Value: "value1", Effect: corev1.TaintEffectNoSchedule},
-						{Key: "key1", Value: "value1", Effect: corev1.TaintEffectNoSchedule},
-						{Key: "key2", Value: "value2", Effect: corev1.TaintEffectPreferNoSchedule},
-					}
+		AfterEach(func() {
+			mockObjects.mockCtrl.Finish()
+		})
 
-					result, err := CheckDuplicateTaints(taints)
-					Expect(err).To(HaveOccurred())
-					Expect(err).To(BeAssignableToTypeOf(DuplicateTaintError{}))
-					Expect(len(result)).To(Equal(2))
-					Expect(result[0]).To(Equal(taints[0]))
-					Expect(result[1]).To(Equal(taints[2]))
-				})
-			})
+		It("should sync the template labels to the node and leave its taints alone", func() {
+			result, err := r.Reconcile(context.TODO(), ctrl.Request{NamespacedName: types.NamespacedName{Name: "test-machineset", Namespace: "test"}})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result).To(Equal(reconcile.Result{}))
 
-			Context("When taints slice is empty", func() {
-				It("should return empty slice without error", func() {
-					taints := []corev1.Taint{}
+			newNode := &corev1.Node{}
+			err = mockObjects.fakeKubeClient.Get(context.TODO(), types.NamespacedName{Name: "test-node"}, newNode)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(newNode.Labels).To(Equal(map[string]string{"foo": "bar"}))
+			Expect(newNode.Annotations).To(HaveKeyWithValue("managed.openshift.com/customlabels", "foo"))
+			Expect(newNode.Spec.Taints).To(Equal([]corev1.Taint{{Key: "bootstrap", Value: "true", Effect: corev1.TaintEffectNoSchedule}}))
+		})
+	})
 
-					result, err := CheckDuplicateTaints(taints)
-					Expect(err).NotTo(HaveOccurred())
-					Expect(result).To(BeEmpty())
-				})
-			})
+	Describe("Processing machines by lifecycle", func() {
+		var (
+			phase      *string
+			conditions []corev1.NodeCondition
+			nodeLabels map[string]string
+			nodeTaints []corev1.Taint
+			result     reconcile.Result
+		)
+
+		BeforeEach(func() {
+			phase = nil
+			conditions = []corev1.NodeCondition{{Type: corev1.NodeReady, Status: corev1.ConditionTrue}}
+			nodeLabels = map[string]string{}
+			nodeTaints = nil
+			metrics.LabelConflicts.Reset()
 		})
 
-		Describe("TaintExists", func() {
-			Context("When taint exists in slice", func() {
-				It("should return true", func() {
-					taints := []corev1.Taint{
-						{Key: "key1", Value: "value1", Effect: corev1.TaintEffectNoSchedule},
-						{Key: "key2", Value: "value2", Effect: corev1.TaintEffectPreferNoSchedule},
-					}
-					taintToFind := &corev1.Taint{Key: "key1", Value: "value1", Effect: corev1.TaintEffectNoSchedule}
+		JustBeforeEach(func() {
+			machineSet = machinev1beta1.MachineSet{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "test-machineset",
+					Namespace: "test",
+				},
+				Spec: machinev1beta1.MachineSetSpec{
+					Selector: metav1.LabelSelector{
+						MatchLabels: map[string]string{"owner": "fake-machineset"},
+					},
+					Template: machinev1beta1.MachineTemplateSpec{
+						ObjectMeta: machinev1beta1.ObjectMeta{
+							Labels: map[string]string{"owner": "fake-machineset"},
+						},
+						Spec: machinev1beta1.MachineSpec{
+							ObjectMeta: machinev1beta1.ObjectMeta{
+								Labels: map[string]string{"foo": "bar"},
+							},
+							Taints: []corev1.Taint{
+								{Key: "foo", Value: "bar", Effect: corev1.TaintEffectNoSchedule},
+								{Key: "evict", Value: "true", Effect: corev1.TaintEffectNoExecute},
+							},
+						},
+					},
+				},
+			}
+			machine = machinev1beta1.Machine{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "test-machine",
+					Namespace: "test",
+					Labels:    map[string]string{"owner": "fake-machineset"},
+				},
+				Status: machinev1beta1.MachineStatus{
+					Phase:   phase,
+					NodeRef: &corev1.ObjectReference{Name: "test-node"},
+				},
+			}
+			node = corev1.Node{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:   "test-node",
+					Labels: nodeLabels,
+				},
+				Spec:   corev1.NodeSpec{Taints: nodeTaints},
+				Status: corev1.NodeStatus{Conditions: conditions},
+			}
+			mockObjects = &mocks{
+				fakeKubeClient: fake.NewClientBuilder().WithScheme(s).WithObjects(&machineSet, &machine, &node).Build(),
+				mockCtrl:       gomock.NewController(GinkgoT()),
+			}
+			r = &MachinesetReconciler{
+				Client:   mockObjects.fakeKubeClient,
+				Scheme:   scheme.Scheme,
+				Recorder: record.NewFakeRecorder(32),
+			}
 
-					result := TaintExists(taints, taintToFind)
-					Expect(result).To(BeTrue())
-				})
-			})
+			result, err = r.Reconcile(context.TODO(), ctrl.Request{NamespacedName: types.NamespacedName{Name: "test-machineset", Namespace: "test"}})
+			Expect(err).NotTo(HaveOccurred())
+			err = mockObjects.fakeKubeClient.Get(context.TODO(), types.NamespacedName{Name: "test-node"}, &updatedNode)
+			Expect(err).NotTo(HaveOccurred())
+		})
 
-			Context("When taint does not exist in slice", func() {
-				It("should return false", func() {
-					taints := []corev1.Taint{
-						{Key: "key1", Value: "value1", Effect: corev1.TaintEffectNoSchedule},
-					}
-					taintToFind := &corev1.Taint{Key: "key2", Value: "value2", Effect: corev1.TaintEffectNoSchedule}
+		AfterEach(func() {
+			mockObjects.mockCtrl.Finish()
+		})
 
-					result := TaintExists(taints, taintToFind)
-					Expect(result).To(BeFalse())
-				})
+		Context("When the node is ready", func() {
+			It("should sync all labels and taints", func() {
+				Expect(result).To(Equal(reconcile.Result{}))
+				Expect(updatedNode.Labels).To(HaveKeyWithValue("foo", "bar"))
+				Expect(updatedNode.Spec.Taints).To(HaveLen(2))
+				Expect(testutil.CollectAndCount(metrics.LabelConflicts)).To(Equal(0))
 			})
+		})
 
-			Context("When taint slice is empty", func() {
-				It("should return false", func() {
-					taints := []corev1.Taint{}
-					taintToFind := &corev1.Taint{Key: "key1", Value: "value1", Effect: corev1.TaintEffectNoSchedule}
+		Context("When a template label was set on the node by someone else", func() {
+			BeforeEach(func() {
+				nodeLabels = map[string]string{"foo": "hand-set"}
+			})
 
-					result := TaintExists(taints, taintToFind)
-					Expect(result).To(BeFalse())
-				})
+			It("should leave the label and count the conflict", func() {
+				Expect(updatedNode.Labels).To(HaveKeyWithValue("foo", "hand-set"))
+				Expect(testutil.ToFloat64(metrics.LabelConflicts.WithLabelValues("test-machineset"))).To(Equal(1.0))
 			})
 		})
 
-		Describe("TaintSliceDiff", func() {
-			Context("When expected and actual are identical", func() {
-				It("should return empty slices", func() {
-					expected := []corev1.Taint{
-						{Key: "key1", Value: "value1", Effect: corev1.TaintEffectNoSchedule},
-					}
-					actual := []corev1.Taint{
-						{Key: "key1", Value: "value1", Effect: corev1.TaintEffectNoSchedule},
-					}
-
-					toAdd, toRemove := TaintSliceDiff(expected, actual)
-					Expect(toAdd).To(BeEmpty())
-					Expect(toRemove).To(BeEmpty())
-				})
+		Context("When the machine is failed", func() {
+			BeforeEach(func() {
+				failed := m.MachinePhaseFailed
+				phase = &failed
 			})
 
-			Context("When expected has additional taints", func() {
-				It("should return taints to add", func() {
-					expected := []corev1.Taint{
-						{Key: "key1", Value: "value1", Effect: corev1.TaintEffectNoSchedule},
-						{Key: "key2", Value: "value2", Effect: corev1.TaintEffectNoSchedule},
-					}
-					actual := []corev1.Taint{
-						{Key: "key1", Value: "value1", Effect: corev1.TaintEffectNoSchedule},
-					}
+			It("should skip the machine", func() {
+				Expect(result).To(Equal(reconcile.Result{}))
+				Expect(updatedNode.Labels).NotTo(HaveKey("foo"))
+				Expect(updatedNode.Spec.Taints).To(BeEmpty())
+			})
+		})
 
-					toAdd, toRemove := TaintSliceDiff(expected, actual)
-					Expect(len(toAdd)).To(Equal(1))
-					Expect(toAdd[0]).To(Equal(&expected[1]))
-					Expect(toRemove).To(BeEmpty())
-				})
+		Context("When the node is not ready", func() {
+			BeforeEach(func() {
+				conditions = []corev1.NodeCondition{{Type: corev1.NodeReady, Status: corev1.ConditionFalse}}
 			})
 
-			Context("When actual has additional taints", func() {
-				It("should return taints to remove", func() {
-					expected := []corev1.Taint{
-						{Key: "key1", Value: "value1", Effect: corev1.TaintEffectNoSchedule},
-					}
-					actual := []corev1.Taint{
-						{Key: "key1", Value: "value1", Effect: corev1.TaintEffectNoSchedule},
-						{Key: "key2", Value: "value2", Effect: corev1.TaintEffectNoSchedule},
-					}
+			It("should sync the labels and hold back the NoExecute taints", func() {
+				Expect(result.RequeueAfter).To(Equal(notReadyRequeueInterval))
+				Expect(updatedNode.Labels).To(HaveKeyWithValue("foo", "bar"))
+				Expect(updatedNode.Spec.Taints).To(Equal([]corev1.Taint{
+					{Key: "foo", Value: "bar", Effect: corev1.TaintEffectNoSchedule},
+				}))
 
-					toAdd, toRemove := TaintSliceDiff(expected, actual)
-					Expect(toAdd).To(BeEmpty())
-					Expect(len(toRemove)).To(Equal(1))
-					Expect(toRemove[0]).To(Equal(&actual[1]))
-				})
+				err = mockObjects.fakeKubeClient.Get(context.TODO(), types.NamespacedName{Name: "test-machine", Namespace: "test"}, &updatedMachine)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(updatedMachine.Spec.Taints).To(Equal([]corev1.Taint{
+					{Key: "foo", Value: "bar", Effect: corev1.TaintEffectNoSchedule},
+				}))
 			})
 
-			Context("When both have different taints", func() {
-				It("should return both taints to add and remove", func() {
-					expected := []corev1.Taint{
-						{Key: "key1", Value: "value1", Effect: corev1.TaintEffectNoSchedule},
-						{Key: "key3", Value: "value3", Effect: corev1.TaintEffectNoSchedule},
-					}
-					actual := []corev1.Taint{
-						{Key: "key2", Value: "value2", Effect: corev1.TaintEffectNoSchedule},
-						{Key: "key3", Value: "value3", Effect: corev1.TaintEffectNoSchedule},
-					}
+			Context("When the NoExecute taint is already on the node", func() {
+				BeforeEach(func() {
+					nodeTaints = []corev1.Taint{{Key: "evict", Value: "true", Effect: corev1.TaintEffectNoExecute}}
+				})
 
-					toAdd, toRemove := TaintSliceDiff(expected, actual)
-					Expect(len(toAdd)).To(Equal(1))
-					Expect(toAdd[0]).To(Equal(&expected[0]))
-					Expect(len(toRemove)).To(Equal(1))
-					Expect(toRemove[0]).To(Equal(&actual[0]))
+				It("should keep it", func() {
+					Expect(result).To(Equal(reconcile.Result{}))
+					Expect(updatedNode.Spec.Taints).To(HaveLen(2))
 				})
 			})
 		})
 	})
+
+	Describe("Failure metric", func() {
+		It("should classify duplicate taints", func() {
+			err := fmt.Errorf("failed: %w", plan.DuplicateTaintError{Message: "duplicate"})
+			Expect(reasons.ReasonFor(err)).To(Equal(reasons.ReasonDuplicateTaint))
+		})
+
+		It("should remove the series of deleted nodes and machines", func() {
+			metrics.NodeReconciliationFailure.Reset()
+			metrics.IncreaseNodeReconciliationFailure("test-machineset", "deleted-node", "Conflict")
+			metrics.IncreaseNodeReconciliationFailure("test-machineset", "machine-node", "Conflict")
+			metrics.IncreaseNodeReconciliationFailure("test-machineset", "other-node", "Conflict")
+
+			h := metricsCleanupHandler()
+			h.Delete(context.TODO(), event.DeleteEvent{Object: &corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "deleted-node"}}}, nil)
+			h.Delete(context.TODO(), event.DeleteEvent{Object: &machinev1beta1.Machine{
+				Status: machinev1beta1.MachineStatus{NodeRef: &corev1.ObjectReference{Name: "machine-node"}},
+			}}, nil)
+
+			Expect(testutil.CollectAndCount(metrics.NodeReconciliationFailure)).To(Equal(1))
+			Expect(testutil.ToFloat64(metrics.NodeReconciliationFailure.WithLabelValues("test-machineset", "other-node", "Conflict"))).To(Equal(1.0))
+		})
+	})
 })
@@ -0,0 +1,88 @@
+package controllers
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+
+	machinev1beta1 "github.com/openshift/api/machine/v1beta1"
+	m "github.com/openshift/managed-node-metadata-operator/pkg/machine"
+	"github.com/openshift/managed-node-metadata-operator/pkg/metrics"
+	"github.com/openshift/managed-node-metadata-operator/pkg/reasons"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// reportContestedMachines reports the machines of the machineset selected by other machinesets, as listed in
+// overlaps. Syncing a machine selected by several machinesets would flip its node labels and taints back and
+// forth, so the plans skip contested machines and they're reported through the mnmo_contested_machines metric and,
+// when the overlap changes, Warning events. The machineset status is owned by its controller and is left alone.
+func (r *MachinesetReconciler) reportContestedMachines(ctx context.Context, machineSet *machinev1beta1.MachineSet, overlaps map[string][]string) {
+	key := r.machineSetKey(machineSet.Name)
+	metrics.SetContestedMachines(key, len(overlaps))
+
+	machines := make([]string, 0, len(overlaps))
+	for machine := range overlaps {
+		machines = append(machines, machine)
+	}
+	sort.Strings(machines)
+	reported := make([]string, 0, len(machines))
+	for _, machine := range machines {
+		reported = append(reported, machine+"="+strings.Join(overlaps[machine], ","))
+	}
+	// The events only repeat when the overlap changes, or once after a restart of the operator
+	changed := r.overlapReports.update(key, strings.Join(reported, ";"))
+
+	for _, machine := range machines {
+		others := overlaps[machine]
+		log.FromContext(ctx).Info("skipping machine selected by several machinesets", "machine", machine, "machinesets", others,
+			"reason", reasons.ReasonMachineSelectorOverlap)
+		if !changed {
+			continue
+		}
+		r.Recorder.Eventf(machineSet, corev1.EventTypeWarning, string(reasons.ReasonMachineSelectorOverlap),
+			"Machine %s is also selected by MachineSet(s) %s, its labels and taints are not synced", machine, strings.Join(others, ", "))
+	}
+}
+
+// overlapReports keeps the overlap last reported for each machineset, keyed by m.MachineSetKey
+type overlapReports struct {
+	mu       sync.Mutex
+	reported map[string]string
+}
+
+// update records the overlap of the machineset and returns whether it differs from the last one reported
+func (o *overlapReports) update(key string, overlap string) bool {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.reported == nil {
+		o.reported = map[string]string{}
+	}
+	last, found := o.reported[key]
+	if overlap == "" {
+		delete(o.reported, key)
+		return false
+	}
+	o.reported[key] = overlap
+	return !found || last != overlap
+}
+
+// delete forgets the overlap of a deleted machineset
+func (o *overlapReports) delete(key string) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	delete(o.reported, key)
+}
+
+// contestedMachines returns the other machinesets selecting the machines of the machineset, keyed by machine name
+func contestedMachines(c client.Client, machineSet *machinev1beta1.MachineSet, machines []client.Object) (map[string][]string, error) {
+	mapiMachines := make([]*machinev1beta1.Machine, 0, len(machines))
+	for _, machine := range machines {
+		if mapiMachine, ok := machine.(*machinev1beta1.Machine); ok {
+			mapiMachines = append(mapiMachines, mapiMachine)
+		}
+	}
+	return m.GetOverlappingMachineSets(c, machineSet, mapiMachines)
+}
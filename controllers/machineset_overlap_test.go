@@ -0,0 +1,151 @@
+package controllers
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	machinev1beta1 "github.com/openshift/api/machine/v1beta1"
+	"github.com/openshift/managed-node-metadata-operator/pkg/metrics"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+var _ = Describe("Overlapping MachineSets", func() {
+	var (
+		fakeClient client.Client
+		recorder   *record.FakeRecorder
+		r          *MachinesetReconciler
+		objects    []client.Object
+	)
+
+	s := newScheme()
+
+	newMachineSet := func(name string, labels map[string]string) *machinev1beta1.MachineSet {
+		return &machinev1beta1.MachineSet{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: "test",
+			},
+			Spec: machinev1beta1.MachineSetSpec{
+				Selector: metav1.LabelSelector{
+					MatchLabels: map[string]string{"pool": "shared"},
+				},
+				Template: machinev1beta1.MachineTemplateSpec{
+					ObjectMeta: machinev1beta1.ObjectMeta{
+						Labels: map[string]string{"pool": "shared"},
+					},
+					Spec: machinev1beta1.MachineSpec{
+						ObjectMeta: machinev1beta1.ObjectMeta{
+							Labels: labels,
+						},
+					},
+				},
+			},
+		}
+	}
+
+	BeforeEach(func() {
+		objects = []client.Object{
+			newMachineSet("first", map[string]string{"foo": "first"}),
+			&machinev1beta1.Machine{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "orphan",
+					Namespace: "test",
+					Labels:    map[string]string{"pool": "shared"},
+				},
+				Status: machinev1beta1.MachineStatus{
+					NodeRef: &corev1.ObjectReference{Name: "test-node"},
+				},
+			},
+			&corev1.Node{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:   "test-node",
+					Labels: map[string]string{},
+				},
+			},
+		}
+	})
+
+	JustBeforeEach(func() {
+		fakeClient = fake.NewClientBuilder().WithScheme(s).WithObjects(objects...).
+			WithStatusSubresource(&machinev1beta1.MachineSet{}).Build()
+		recorder = record.NewFakeRecorder(32)
+		r = &MachinesetReconciler{
+			Client:   fakeClient,
+			Scheme:   scheme.Scheme,
+			Recorder: recorder,
+		}
+	})
+
+	reconcileFirst := func() {
+		_, err := r.Reconcile(context.TODO(), ctrl.Request{NamespacedName: types.NamespacedName{Name: "first", Namespace: "test"}})
+		Expect(err).NotTo(HaveOccurred())
+	}
+
+	getMachineSet := func() *machinev1beta1.MachineSet {
+		machineSet := &machinev1beta1.MachineSet{}
+		Expect(fakeClient.Get(context.TODO(), types.NamespacedName{Name: "first", Namespace: "test"}, machineSet)).To(Succeed())
+		return machineSet
+	}
+
+	Context("When the machine is selected by a single machineset", func() {
+		It("should sync the machine", func() {
+			reconcileFirst()
+
+			node := &corev1.Node{}
+			Expect(fakeClient.Get(context.TODO(), types.NamespacedName{Name: "test-node"}, node)).To(Succeed())
+			Expect(node.Labels).To(HaveKeyWithValue("foo", "first"))
+			Expect(testutil.ToFloat64(metrics.ContestedMachines.WithLabelValues("first"))).To(Equal(0.0))
+		})
+	})
+
+	Context("When the machine is selected by another machineset", func() {
+		BeforeEach(func() {
+			objects = append(objects, newMachineSet("second", map[string]string{"foo": "second"}))
+		})
+
+		It("should skip the machine and report the overlap", func() {
+			reconcileFirst()
+
+			node := &corev1.Node{}
+			Expect(fakeClient.Get(context.TODO(), types.NamespacedName{Name: "test-node"}, node)).To(Succeed())
+			Expect(node.Labels).NotTo(HaveKey("foo"))
+
+			Expect(getMachineSet().Status.Conditions).To(BeEmpty())
+			Expect(testutil.ToFloat64(metrics.ContestedMachines.WithLabelValues("first"))).To(Equal(1.0))
+			Expect(recorder.Events).To(HaveLen(1))
+			Expect(<-recorder.Events).To(ContainSubstring("also selected by MachineSet(s) second"))
+		})
+
+		It("should report the overlap once while it doesn't change", func() {
+			reconcileFirst()
+			Expect(recorder.Events).To(HaveLen(1))
+			<-recorder.Events
+
+			reconcileFirst()
+			Expect(recorder.Events).To(BeEmpty())
+		})
+
+		It("should report the overlap again once it was resolved and comes back", func() {
+			reconcileFirst()
+			<-recorder.Events
+			Expect(fakeClient.Delete(context.TODO(), newMachineSet("second", nil))).To(Succeed())
+			reconcileFirst()
+			Expect(testutil.ToFloat64(metrics.ContestedMachines.WithLabelValues("first"))).To(Equal(0.0))
+			Expect(recorder.Events).To(BeEmpty())
+
+			Expect(fakeClient.Create(context.TODO(), newMachineSet("second", nil))).To(Succeed())
+			reconcileFirst()
+			Expect(recorder.Events).To(HaveLen(1))
+		})
+	})
+})
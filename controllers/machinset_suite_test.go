@@ -1,13 +1,25 @@
-package controllers_test
+package controllers
 
 import (
 	"testing"
 
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
+	machinev1beta1 "github.com/openshift/api/machine/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
 )
 
 func TestMachineset(t *testing.T) {
 	RegisterFailHandler(Fail)
 	RunSpecs(t, "Machinset Suite")
 }
+
+// newScheme returns a scheme with the core and machine APIs, and the APIs of addToSchemes
+func newScheme(addToSchemes ...func(*runtime.Scheme) error) *runtime.Scheme {
+	s := runtime.NewScheme()
+	for _, addToScheme := range append([]func(*runtime.Scheme) error{corev1.AddToScheme, machinev1beta1.AddToScheme}, addToSchemes...) {
+		Expect(addToScheme(s)).To(Succeed(), "failed adding apis to scheme")
+	}
+	return s
+}
@@ -0,0 +1,95 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	machinev1beta1 "github.com/openshift/api/machine/v1beta1"
+	"github.com/openshift/managed-node-metadata-operator/pkg/history"
+	m "github.com/openshift/managed-node-metadata-operator/pkg/machine"
+	"github.com/openshift/managed-node-metadata-operator/pkg/plan"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// Targets of a rollback
+const (
+	// RollbackTargetMachineSet restores the template of the machineset, the operator then syncs the nodes to it
+	RollbackTargetMachineSet = "machineset"
+	// RollbackTargetNodes syncs the machines and nodes to the template without changing the machineset
+	RollbackTargetNodes = "nodes"
+)
+
+// recordHistory records the template of the machineset as applied at its generation
+func (r *MachinesetReconciler) recordHistory(ctx context.Context, machineSet client.Object) error {
+	if r.History == nil {
+		return nil
+	}
+	return r.History.Record(ctx, history.Key(r.provider().Name(), machineSet.GetNamespace(), machineSet.GetName()), history.Snapshot{
+		Generation: machineSet.GetGeneration(),
+		Time:       time.Now().UTC(),
+		Labels:     r.provider().DesiredLabels(machineSet),
+		Taints:     r.provider().DesiredTaints(machineSet),
+	})
+}
+
+// deleteHistory drops the history of a deleted machineset
+func (r *MachinesetReconciler) deleteHistory(ctx context.Context, namespace string, name string) error {
+	if r.History == nil {
+		return nil
+	}
+	return r.History.Delete(ctx, history.Key(r.provider().Name(), namespace, name))
+}
+
+// Rollback restores the template of the Machine API machineset at the generation, on the machineset or directly on
+// its machines and nodes depending on the target. The template of a generation that wasn't recorded because it
+// didn't change it, like a replica change, is the one last recorded before it. It returns the plan of the sync to the restored
+// template, and only plans it in a dry run. Nodes restored directly are synced back to the template of the
+// machineset by the operator's next reconcile, unless it's stopped.
+func Rollback(ctx context.Context, c client.Client, store *history.Store, namespace string, machineSetName string, generation int64, target string, dryRun bool) (*plan.Plan, error) {
+	if target != RollbackTargetMachineSet && target != RollbackTargetNodes {
+		return nil, fmt.Errorf("unknown rollback target %q, expected %s or %s", target, RollbackTargetMachineSet, RollbackTargetNodes)
+	}
+	r := &MachinesetReconciler{Client: c, Provider: m.MachineAPIProvider{}}
+	machineSet := &machinev1beta1.MachineSet{}
+	if err := c.Get(ctx, client.ObjectKey{Namespace: namespace, Name: machineSetName}, machineSet); err != nil {
+		return nil, err
+	}
+	snapshots, err := store.Get(ctx, history.Key(r.provider().Name(), namespace, machineSetName))
+	if err != nil {
+		return nil, err
+	}
+	var snapshot *history.Snapshot
+	if generation <= machineSet.Generation {
+		snapshot = history.Find(snapshots, generation)
+	}
+	if snapshot == nil {
+		generations := make([]int64, 0, len(snapshots))
+		for _, s := range snapshots {
+			generations = append(generations, s.Generation)
+		}
+		return nil, fmt.Errorf("generation %d of machineset %s isn't in its history, recorded generations are %v", generation, machineSetName, generations)
+	}
+
+	restored := machineSet.DeepCopy()
+	restored.Spec.Template.Spec.Labels = snapshot.Labels
+	restored.Spec.Template.Spec.Taints = snapshot.Taints
+	rollbackPlan, err := r.planMachineSet(ctx, restored)
+	if err != nil || dryRun {
+		return rollbackPlan, err
+	}
+
+	if target == RollbackTargetMachineSet {
+		patch := client.MergeFromWithOptions(machineSet.DeepCopy(), client.MergeFromWithOptimisticLock{})
+		machineSet.Spec.Template.Spec.Labels = snapshot.Labels
+		machineSet.Spec.Template.Spec.Taints = snapshot.Taints
+		return rollbackPlan, c.Patch(ctx, machineSet, patch)
+	}
+
+	machines, overlaps, err := r.getMachines(ctx, restored)
+	if err != nil {
+		return nil, err
+	}
+	_, err = r.processMachines(ctx, restored, machines, overlaps)
+	return rollbackPlan, err
+}
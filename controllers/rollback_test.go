@@ -0,0 +1,150 @@
+package controllers
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	machinev1beta1 "github.com/openshift/api/machine/v1beta1"
+	"github.com/openshift/managed-node-metadata-operator/pkg/history"
+	"github.com/openshift/managed-node-metadata-operator/pkg/plan"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+var _ = Describe("Rollback", func() {
+	var (
+		fakeClient client.Client
+		store      *history.Store
+		r          *MachinesetReconciler
+	)
+
+	s := newScheme()
+
+	getMachineSet := func() *machinev1beta1.MachineSet {
+		machineSet := &machinev1beta1.MachineSet{}
+		Expect(fakeClient.Get(context.TODO(), client.ObjectKey{Namespace: "test", Name: "test-machineset"}, machineSet)).To(Succeed())
+		return machineSet
+	}
+
+	getNode := func() *corev1.Node {
+		node := &corev1.Node{}
+		Expect(fakeClient.Get(context.TODO(), client.ObjectKey{Name: "test-node"}, node)).To(Succeed())
+		return node
+	}
+
+	// sync syncs the nodes to the current template, recording it in the history once they converged
+	sync := func() {
+		_, err := r.ProcessMachineSet(context.TODO(), getMachineSet())
+		Expect(err).NotTo(HaveOccurred())
+	}
+
+	// updateTemplate changes the template labels and bumps the generation like the API server does
+	updateTemplate := func(labels map[string]string) {
+		machineSet := getMachineSet()
+		machineSet.Spec.Template.Spec.Labels = labels
+		machineSet.Generation++
+		Expect(fakeClient.Update(context.TODO(), machineSet)).To(Succeed())
+	}
+
+	BeforeEach(func() {
+		machineSet := &machinev1beta1.MachineSet{
+			ObjectMeta: metav1.ObjectMeta{Name: "test-machineset", Namespace: "test", Generation: 1},
+			Spec: machinev1beta1.MachineSetSpec{
+				Selector: metav1.LabelSelector{MatchLabels: map[string]string{"pool": "test"}},
+				Template: machinev1beta1.MachineTemplateSpec{
+					ObjectMeta: machinev1beta1.ObjectMeta{Labels: map[string]string{"pool": "test"}},
+					Spec: machinev1beta1.MachineSpec{
+						ObjectMeta: machinev1beta1.ObjectMeta{Labels: map[string]string{"team": "payments", "tier": "prod"}},
+					},
+				},
+			},
+		}
+		machine := &machinev1beta1.Machine{
+			ObjectMeta: metav1.ObjectMeta{Name: "test-machine", Namespace: "test", Labels: map[string]string{"pool": "test"}},
+			Status:     machinev1beta1.MachineStatus{NodeRef: &corev1.ObjectReference{Name: "test-node"}},
+		}
+		node := &corev1.Node{
+			ObjectMeta: metav1.ObjectMeta{Name: "test-node"},
+			Status: corev1.NodeStatus{Conditions: []corev1.NodeCondition{
+				{Type: corev1.NodeReady, Status: corev1.ConditionTrue},
+			}},
+		}
+		fakeClient = fake.NewClientBuilder().WithScheme(s).WithObjects(machineSet, machine, node).Build()
+		store = &history.Store{Client: fakeClient, Namespace: history.DefaultNamespace, Name: history.DefaultName, MaxGenerations: 10}
+		r = &MachinesetReconciler{Client: fakeClient, Recorder: record.NewFakeRecorder(32), History: store}
+
+		sync()
+		updateTemplate(map[string]string{"team": "payments"})
+		sync()
+	})
+
+	It("should record the template of every converged generation", func() {
+		snapshots, err := store.Get(context.TODO(), history.Key("machineset", "test", "test-machineset"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(snapshots).To(HaveLen(2))
+		Expect(snapshots[0].Generation).To(Equal(int64(1)))
+		Expect(snapshots[0].Labels).To(Equal(map[string]string{"team": "payments", "tier": "prod"}))
+		Expect(getNode().Labels).NotTo(HaveKey("tier"))
+	})
+
+	It("should drop the history of a deleted machineset", func() {
+		machineSet := getMachineSet()
+		Expect(fakeClient.Delete(context.TODO(), machineSet)).To(Succeed())
+		_, err := r.Reconcile(context.TODO(), reconcile.Request{NamespacedName: client.ObjectKeyFromObject(machineSet)})
+		Expect(err).NotTo(HaveOccurred())
+
+		snapshots, err := store.Get(context.TODO(), history.Key("machineset", "test", "test-machineset"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(snapshots).To(BeEmpty())
+	})
+
+	It("should restore the template on the machineset", func() {
+		rollbackPlan, err := Rollback(context.TODO(), fakeClient, store, "test", "test-machineset", 1, RollbackTargetMachineSet, false)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(rollbackPlan.Machines[0].Changes(plan.KindNode, plan.MetadataLabel)).To(HaveLen(1))
+		Expect(getMachineSet().Spec.Template.Spec.Labels).To(Equal(map[string]string{"team": "payments", "tier": "prod"}))
+
+		sync()
+		Expect(getNode().Labels).To(HaveKeyWithValue("tier", "prod"))
+	})
+
+	It("should restore the template directly on the nodes", func() {
+		_, err := Rollback(context.TODO(), fakeClient, store, "test", "test-machineset", 1, RollbackTargetNodes, false)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(getNode().Labels).To(HaveKeyWithValue("tier", "prod"))
+		Expect(getMachineSet().Spec.Template.Spec.Labels).To(Equal(map[string]string{"team": "payments"}))
+	})
+
+	It("should only plan the rollback in a dry run", func() {
+		rollbackPlan, err := Rollback(context.TODO(), fakeClient, store, "test", "test-machineset", 1, RollbackTargetNodes, true)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(rollbackPlan.Machines[0].NodeInSync()).To(BeFalse())
+		Expect(getNode().Labels).NotTo(HaveKey("tier"))
+	})
+
+	It("should restore the template of a generation that only changed the replicas", func() {
+		machineSet := getMachineSet()
+		replicas := int32(3)
+		machineSet.Spec.Replicas = &replicas
+		machineSet.Generation++
+		Expect(fakeClient.Update(context.TODO(), machineSet)).To(Succeed())
+		sync()
+		updateTemplate(map[string]string{"team": "billing"})
+		sync()
+
+		_, err := Rollback(context.TODO(), fakeClient, store, "test", "test-machineset", 3, RollbackTargetMachineSet, false)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(getMachineSet().Spec.Template.Spec.Labels).To(Equal(map[string]string{"team": "payments"}))
+	})
+
+	It("should fail for a generation out of the history", func() {
+		_, err := Rollback(context.TODO(), fakeClient, store, "test", "test-machineset", 5, RollbackTargetMachineSet, false)
+		Expect(err).To(MatchError(ContainSubstring("recorded generations are [1 2]")))
+	})
+})
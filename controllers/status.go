@@ -0,0 +1,28 @@
+package controllers
+
+import (
+	"context"
+
+	"github.com/openshift/managed-node-metadata-operator/pkg/metrics"
+	"github.com/openshift/managed-node-metadata-operator/pkg/reasons"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// reportSyncResult reports a failed sync of the machineset. The failure is logged and counted by the
+// mnmo_machineset_sync_failures_total metric with the reason of the error, and reported by a Warning event unless
+// it's a conflict, which is retried. The machineset status is owned by its controller and is left alone.
+func (r *MachinesetReconciler) reportSyncResult(ctx context.Context, machineSet client.Object, syncErr error) {
+	if syncErr == nil {
+		return
+	}
+	reason := reasons.ReasonFor(syncErr)
+	metrics.IncreaseMachineSetSyncFailure(r.machineSetKey(machineSet.GetName()), string(reason))
+	if reason == reasons.ReasonConflict {
+		log.FromContext(ctx).Info("retrying sync of machineset changed in the meantime", "error", syncErr.Error(), "reason", reason)
+		return
+	}
+	log.FromContext(ctx).Error(syncErr, "failed to sync machineset", "reason", reason)
+	r.Recorder.Eventf(machineSet, corev1.EventTypeWarning, string(reason), "Failed to sync labels and taints: %v", syncErr)
+}
@@ -0,0 +1,95 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	machinev1beta1 "github.com/openshift/api/machine/v1beta1"
+	"github.com/openshift/managed-node-metadata-operator/pkg/metrics"
+	"github.com/openshift/managed-node-metadata-operator/pkg/reasons"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	k8serr "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+var _ = Describe("Sync status", func() {
+	var (
+		fakeClient client.Client
+		recorder   *record.FakeRecorder
+		r          *MachinesetReconciler
+		machineSet *machinev1beta1.MachineSet
+	)
+
+	s := newScheme()
+
+	BeforeEach(func() {
+		machineSet = &machinev1beta1.MachineSet{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "test-machineset",
+				Namespace: "test",
+			},
+			Spec: machinev1beta1.MachineSetSpec{
+				// The selector can't match the template labels
+				Selector: metav1.LabelSelector{
+					MatchLabels: map[string]string{"pool": "test"},
+				},
+				Template: machinev1beta1.MachineTemplateSpec{
+					ObjectMeta: machinev1beta1.ObjectMeta{
+						Labels: map[string]string{"pool": "other"},
+					},
+				},
+			},
+		}
+	})
+
+	JustBeforeEach(func() {
+		fakeClient = fake.NewClientBuilder().WithScheme(s).WithObjects(machineSet).
+			WithStatusSubresource(&machinev1beta1.MachineSet{}).Build()
+		recorder = record.NewFakeRecorder(32)
+		r = &MachinesetReconciler{
+			Client:   fakeClient,
+			Scheme:   scheme.Scheme,
+			Recorder: recorder,
+		}
+	})
+
+	reconcileMachineSet := func() error {
+		_, err := r.Reconcile(context.TODO(), ctrl.Request{NamespacedName: types.NamespacedName{Name: "test-machineset", Namespace: "test"}})
+		return err
+	}
+
+	getConditions := func() machinev1beta1.Conditions {
+		updated := &machinev1beta1.MachineSet{}
+		Expect(fakeClient.Get(context.TODO(), types.NamespacedName{Name: "test-machineset", Namespace: "test"}, updated)).To(Succeed())
+		return updated.Status.Conditions
+	}
+
+	It("should report the reason of a failed sync through an event and a metric, without touching the status", func() {
+		metrics.MachineSetSyncFailures.Reset()
+		Expect(reconcileMachineSet()).To(HaveOccurred())
+
+		Expect(getConditions()).To(BeEmpty())
+		Expect(testutil.ToFloat64(metrics.MachineSetSyncFailures.WithLabelValues("test-machineset", string(reasons.ReasonInvalidSelector)))).To(Equal(1.0))
+		Expect(recorder.Events).To(HaveLen(1))
+		Expect(<-recorder.Events).To(HavePrefix("Warning InvalidSelector"))
+	})
+
+	It("should not emit a Warning event for conflicts, they're retried", func() {
+		metrics.MachineSetSyncFailures.Reset()
+		conflict := k8serr.NewConflict(schema.GroupResource{Resource: "nodes"}, "test-node", errors.New("the object has been modified"))
+		r.reportSyncResult(context.TODO(), machineSet, conflict)
+
+		Expect(recorder.Events).To(BeEmpty())
+		Expect(testutil.ToFloat64(metrics.MachineSetSyncFailures.WithLabelValues("test-machineset", string(reasons.ReasonConflict)))).To(Equal(1.0))
+	})
+})
@@ -0,0 +1,242 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	machinev1beta1 "github.com/openshift/api/machine/v1beta1"
+	m "github.com/openshift/managed-node-metadata-operator/pkg/machine"
+	"github.com/openshift/managed-node-metadata-operator/pkg/plan"
+	"github.com/openshift/managed-node-metadata-operator/pkg/reasons"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// SyncStatePath is the path of the sync state endpoint on the metrics server
+const SyncStatePath = "/debug/sync-state"
+
+// SyncResult is the outcome of the last sync of a machineset
+type SyncResult struct {
+	Time         time.Time `json:"time"`
+	RequeueAfter string    `json:"requeueAfter,omitempty"`
+	Error        string    `json:"error,omitempty"`
+	Reason       string    `json:"reason,omitempty"`
+}
+
+// SyncResults keeps the outcome of the last sync of each machineset, keyed by m.MachineSetKey
+type SyncResults struct {
+	mu      sync.RWMutex
+	results map[string]SyncResult
+}
+
+// NewSyncResults returns an empty SyncResults
+func NewSyncResults() *SyncResults {
+	return &SyncResults{results: map[string]SyncResult{}}
+}
+
+// Record keeps the outcome of a sync of the machineset
+func (s *SyncResults) Record(key string, result reconcile.Result, err error) {
+	syncResult := SyncResult{Time: time.Now().UTC()}
+	if result.RequeueAfter > 0 {
+		syncResult.RequeueAfter = result.RequeueAfter.String()
+	}
+	if err != nil {
+		syncResult.Error = err.Error()
+		syncResult.Reason = string(reasons.ReasonFor(err))
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.results[key] = syncResult
+}
+
+// Get returns the outcome of the last sync of the machineset, if any
+func (s *SyncResults) Get(key string) *SyncResult {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	result, ok := s.results[key]
+	if !ok {
+		return nil
+	}
+	return &result
+}
+
+// Delete forgets the outcome of the syncs of a deleted machineset
+func (s *SyncResults) Delete(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.results, key)
+}
+
+// SyncState is the document served by the sync state endpoint
+type SyncState struct {
+	MachineSets []MachineSetSyncState `json:"machineSets"`
+}
+
+// MachineSetSyncState is the desired metadata of a machineset and the actual metadata of its machines and nodes
+type MachineSetSyncState struct {
+	Provider      string             `json:"provider"`
+	Namespace     string             `json:"namespace"`
+	Name          string             `json:"name"`
+	DesiredLabels map[string]string  `json:"desiredLabels"`
+	DesiredTaints []corev1.Taint     `json:"desiredTaints"`
+	LastSync      *SyncResult        `json:"lastSync,omitempty"`
+	Machines      []MachineSyncState `json:"machines"`
+}
+
+// MachineSyncState is the actual metadata of a machine and its node
+type MachineSyncState struct {
+	Name string `json:"name"`
+	// Skipped is why the machine isn't synced, if it isn't
+	Skipped       string            `json:"skipped,omitempty"`
+	Labels        map[string]string `json:"labels,omitempty"`
+	Taints        []corev1.Taint    `json:"taints,omitempty"`
+	Node          *NodeSyncState    `json:"node,omitempty"`
+	OverlappingBy []string          `json:"overlappingBy,omitempty"`
+}
+
+// NodeSyncState is the expected and actual metadata of a node
+type NodeSyncState struct {
+	Name  string `json:"name"`
+	Ready bool   `json:"ready"`
+	// OwnedLabels are the label keys recorded as set by the operator in the node annotation
+	OwnedLabels    []string          `json:"ownedLabels"`
+	ExpectedLabels map[string]string `json:"expectedLabels"`
+	ManagedLabels  map[string]string `json:"managedLabels"`
+	ExpectedTaints []corev1.Taint    `json:"expectedTaints"`
+	Taints         []corev1.Taint    `json:"taints"`
+	InSync         bool              `json:"inSync"`
+}
+
+// SyncStateHandler serves the desired and actual labels and taints of the managed machinesets, their machines
+// and nodes as JSON. The machineset and node query parameters filter the machinesets and nodes. It never
+// updates any object.
+type SyncStateHandler struct {
+	client.Client
+
+	// Providers give access to the machine sets, machines and nodes
+	Providers []m.Provider
+	// Namespace of the machine sets, all namespaces of the cache are served if empty
+	Namespace string
+	// Results are the outcomes of the last syncs, they're left out if nil
+	Results *SyncResults
+}
+
+func (h *SyncStateHandler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet {
+		http.Error(w, "only GET is supported", http.StatusMethodNotAllowed)
+		return
+	}
+	state, err := h.SyncState(req.Context(), req.URL.Query().Get("machineset"), req.URL.Query().Get("node"))
+	if err != nil {
+		klog.Errorf("failed to compute the sync state: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(state); err != nil {
+		klog.Errorf("failed to write the sync state: %v", err)
+	}
+}
+
+// SyncState returns the sync state of the machinesets, filtered by machineset and node name if not empty
+func (h *SyncStateHandler) SyncState(ctx context.Context, machineSetName string, nodeName string) (*SyncState, error) {
+	state := &SyncState{MachineSets: []MachineSetSyncState{}}
+	for _, provider := range h.Providers {
+		r := &MachinesetReconciler{Client: h.Client, Provider: provider}
+		machineSets := provider.NewMachineSetList()
+		if err := h.List(ctx, machineSets, client.InNamespace(h.Namespace)); err != nil {
+			return nil, err
+		}
+		items, err := meta.ExtractList(machineSets)
+		if err != nil {
+			return nil, err
+		}
+		for _, item := range items {
+			machineSet, ok := item.(client.Object)
+			if !ok || (machineSetName != "" && machineSet.GetName() != machineSetName) {
+				continue
+			}
+			machineSetState, err := h.machineSetSyncState(ctx, r, machineSet, nodeName)
+			if err != nil {
+				return nil, err
+			}
+			if nodeName != "" && len(machineSetState.Machines) == 0 {
+				continue
+			}
+			state.MachineSets = append(state.MachineSets, *machineSetState)
+		}
+	}
+	return state, nil
+}
+
+// machineSetSyncState returns the sync state of the machineset, with only the machine of the node if not empty
+func (h *SyncStateHandler) machineSetSyncState(ctx context.Context, r *MachinesetReconciler, machineSet client.Object, nodeName string) (*MachineSetSyncState, error) {
+	desiredLabels := r.provider().DesiredLabels(machineSet)
+	desiredTaints := r.provider().DesiredTaints(machineSet)
+	state := &MachineSetSyncState{
+		Provider:      r.provider().Name(),
+		Namespace:     machineSet.GetNamespace(),
+		Name:          machineSet.GetName(),
+		DesiredLabels: desiredLabels,
+		DesiredTaints: desiredTaints,
+		Machines:      []MachineSyncState{},
+	}
+	if h.Results != nil {
+		state.LastSync = h.Results.Get(r.machineSetKey(machineSet.GetName()))
+	}
+
+	machines, overlaps, err := r.getMachines(ctx, machineSet)
+	if err != nil {
+		return nil, err
+	}
+	nodes, err := r.getNodes(ctx, machines, overlaps)
+	if err != nil {
+		return nil, err
+	}
+	machineSetPlan := r.planner().Plan(machineSet, machines, nodes, overlaps)
+
+	for i, machine := range machines {
+		if nodeName != "" && m.GetNodeName(machine) != nodeName {
+			continue
+		}
+		machinePlan := machineSetPlan.Machines[i]
+		machineState := MachineSyncState{Name: machine.GetName(), Skipped: machinePlan.Skipped, OverlappingBy: overlaps[machine.GetName()]}
+		if mapiMachine, ok := machine.(*machinev1beta1.Machine); ok {
+			machineState.Labels = mapiMachine.Spec.Labels
+			machineState.Taints = mapiMachine.Spec.Taints
+		}
+		if machinePlan.Skipped == "" {
+			machineState.Node = r.nodeSyncState(machinePlan, nodes[machinePlan.Node])
+		}
+		state.Machines = append(state.Machines, machineState)
+	}
+	return state, nil
+}
+
+// nodeSyncState returns the expected labels and taints of the node in the plan, and its actual ones
+func (r *MachinesetReconciler) nodeSyncState(machinePlan plan.MachinePlan, node *corev1.Node) *NodeSyncState {
+	ownedLabels := []string{}
+	if annotation := node.Annotations[plan.CustomLabelsAnnotation]; annotation != "" {
+		ownedLabels = strings.Split(annotation, ",")
+	}
+
+	return &NodeSyncState{
+		Name:           node.Name,
+		Ready:          m.IsNodeReady(node),
+		OwnedLabels:    ownedLabels,
+		ExpectedLabels: machinePlan.Labels,
+		ManagedLabels:  r.planner().ManagedNodeLabels(node),
+		ExpectedTaints: machinePlan.NodeTaints,
+		Taints:         node.Spec.Taints,
+		InSync:         machinePlan.NodeInSync(),
+	}
+}
@@ -0,0 +1,171 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	machinev1beta1 "github.com/openshift/api/machine/v1beta1"
+	m "github.com/openshift/managed-node-metadata-operator/pkg/machine"
+	"github.com/openshift/managed-node-metadata-operator/pkg/plan"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+var _ = Describe("SyncStateHandler", func() {
+	var (
+		fakeClient client.Client
+		handler    *SyncStateHandler
+		results    *SyncResults
+	)
+
+	s := newScheme()
+
+	newMachine := func(name string, nodeName string) *machinev1beta1.Machine {
+		return &machinev1beta1.Machine{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: "test",
+				Labels:    map[string]string{"pool": "test"},
+			},
+			Status: machinev1beta1.MachineStatus{
+				NodeRef: &corev1.ObjectReference{Name: nodeName},
+			},
+		}
+	}
+
+	newNode := func(name string, labels map[string]string, managedLabels string) *corev1.Node {
+		return &corev1.Node{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        name,
+				Labels:      labels,
+				Annotations: map[string]string{"managed.openshift.com/customlabels": managedLabels},
+			},
+			Status: corev1.NodeStatus{Conditions: []corev1.NodeCondition{
+				{Type: corev1.NodeReady, Status: corev1.ConditionTrue},
+			}},
+		}
+	}
+
+	BeforeEach(func() {
+		fakeClient = fake.NewClientBuilder().WithScheme(s).WithObjects(
+			&machinev1beta1.MachineSet{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "test-machineset",
+					Namespace: "test",
+				},
+				Spec: machinev1beta1.MachineSetSpec{
+					Selector: metav1.LabelSelector{
+						MatchLabels: map[string]string{"pool": "test"},
+					},
+					Template: machinev1beta1.MachineTemplateSpec{
+						ObjectMeta: machinev1beta1.ObjectMeta{
+							Labels: map[string]string{"pool": "test"},
+						},
+						Spec: machinev1beta1.MachineSpec{
+							ObjectMeta: machinev1beta1.ObjectMeta{
+								Labels: map[string]string{"foo": "bar"},
+							},
+						},
+					},
+				},
+			},
+			newMachine("in-sync", "in-sync-node"),
+			newNode("in-sync-node", map[string]string{"foo": "bar"}, "foo"),
+			newMachine("out-of-sync", "out-of-sync-node"),
+			newNode("out-of-sync-node", map[string]string{"foo": "old"}, "foo"),
+		).Build()
+		results = NewSyncResults()
+		handler = &SyncStateHandler{
+			Client:    fakeClient,
+			Providers: []m.Provider{m.MachineAPIProvider{}},
+			Namespace: "test",
+			Results:   results,
+		}
+	})
+
+	get := func(query string) (*httptest.ResponseRecorder, *SyncState) {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, SyncStatePath+query, nil))
+		state := &SyncState{}
+		if rec.Code == http.StatusOK {
+			Expect(json.Unmarshal(rec.Body.Bytes(), state)).To(Succeed())
+		}
+		return rec, state
+	}
+
+	It("should return the desired and actual metadata of the nodes", func() {
+		rec, state := get("")
+		Expect(rec.Code).To(Equal(http.StatusOK))
+		Expect(state.MachineSets).To(HaveLen(1))
+
+		machineSet := state.MachineSets[0]
+		Expect(machineSet.Name).To(Equal("test-machineset"))
+		Expect(machineSet.DesiredLabels).To(HaveKeyWithValue("foo", "bar"))
+		Expect(machineSet.Machines).To(HaveLen(2))
+		for _, machine := range machineSet.Machines {
+			Expect(machine.Node).NotTo(BeNil())
+			Expect(machine.Node.OwnedLabels).To(Equal([]string{"foo"}))
+			Expect(machine.Node.ExpectedLabels).To(HaveKeyWithValue("foo", "bar"))
+			Expect(machine.Node.InSync).To(Equal(machine.Name == "in-sync"))
+		}
+	})
+
+	It("should filter by node", func() {
+		_, state := get("?node=out-of-sync-node")
+		Expect(state.MachineSets).To(HaveLen(1))
+		Expect(state.MachineSets[0].Machines).To(HaveLen(1))
+		Expect(state.MachineSets[0].Machines[0].Node.ManagedLabels).To(HaveKeyWithValue("foo", "old"))
+	})
+
+	It("should filter by machineset", func() {
+		_, state := get("?machineset=other")
+		Expect(state.MachineSets).To(BeEmpty())
+	})
+
+	It("should return the last sync result", func() {
+		r := &MachinesetReconciler{Client: fakeClient, Recorder: record.NewFakeRecorder(32), SyncResults: results}
+		machineSet := &machinev1beta1.MachineSet{}
+		Expect(fakeClient.Get(context.TODO(), client.ObjectKey{Namespace: "test", Name: "test-machineset"}, machineSet)).To(Succeed())
+		_, err := r.ProcessMachineSet(context.TODO(), machineSet)
+		Expect(err).NotTo(HaveOccurred())
+
+		_, state := get("")
+		Expect(state.MachineSets[0].LastSync).NotTo(BeNil())
+		Expect(state.MachineSets[0].LastSync.Error).To(BeEmpty())
+		for _, machine := range state.MachineSets[0].Machines {
+			Expect(machine.Node.InSync).To(BeTrue())
+		}
+	})
+
+	It("should report the machines the sync skips as the reconciler does", func() {
+		failed := newMachine("failed", "deleted-node")
+		phase := m.MachinePhaseFailed
+		failed.Status.Phase = &phase
+		Expect(fakeClient.Create(context.TODO(), failed)).To(Succeed())
+
+		rec, state := get("")
+		Expect(rec.Code).To(Equal(http.StatusOK))
+		skipped := map[string]string{}
+		for _, machine := range state.MachineSets[0].Machines {
+			skipped[machine.Name] = machine.Skipped
+			if machine.Name == "failed" {
+				Expect(machine.Node).To(BeNil())
+			}
+		}
+		Expect(skipped).To(Equal(map[string]string{"in-sync": "", "out-of-sync": "", "failed": plan.ReasonMachineFailed}))
+	})
+
+	It("should be read-only", func() {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, SyncStatePath, nil))
+		Expect(rec.Code).To(Equal(http.StatusMethodNotAllowed))
+	})
+})
@@ -0,0 +1,121 @@
+package controllers
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	machinev1beta1 "github.com/openshift/api/machine/v1beta1"
+	"github.com/openshift/managed-node-metadata-operator/pkg/tracing"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+var _ = Describe("Reconcile tracing", func() {
+	var (
+		recorder         *tracetest.SpanRecorder
+		previousProvider trace.TracerProvider
+		r                *MachinesetReconciler
+		machineSet       *machinev1beta1.MachineSet
+	)
+
+	s := newScheme()
+
+	BeforeEach(func() {
+		previousProvider = otel.GetTracerProvider()
+		recorder = tracetest.NewSpanRecorder()
+		otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+
+		machineSet = &machinev1beta1.MachineSet{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "test-machineset",
+				Namespace: "test",
+			},
+			Spec: machinev1beta1.MachineSetSpec{
+				Selector: metav1.LabelSelector{
+					MatchLabels: map[string]string{"pool": "test"},
+				},
+				Template: machinev1beta1.MachineTemplateSpec{
+					ObjectMeta: machinev1beta1.ObjectMeta{
+						Labels: map[string]string{"pool": "test"},
+					},
+					Spec: machinev1beta1.MachineSpec{
+						ObjectMeta: machinev1beta1.ObjectMeta{
+							Labels: map[string]string{"foo": "bar"},
+						},
+					},
+				},
+			},
+		}
+		machine := &machinev1beta1.Machine{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "test-machine",
+				Namespace: "test",
+				Labels:    map[string]string{"pool": "test"},
+			},
+			Status: machinev1beta1.MachineStatus{
+				NodeRef: &corev1.ObjectReference{Name: "test-node"},
+			},
+		}
+		node := &corev1.Node{
+			ObjectMeta: metav1.ObjectMeta{Name: "test-node"},
+			Status: corev1.NodeStatus{Conditions: []corev1.NodeCondition{
+				{Type: corev1.NodeReady, Status: corev1.ConditionTrue},
+			}},
+		}
+		r = &MachinesetReconciler{
+			Client:   tracing.WrapClient(fake.NewClientBuilder().WithScheme(s).WithObjects(machineSet, machine, node).Build()),
+			Recorder: record.NewFakeRecorder(32),
+		}
+	})
+
+	AfterEach(func() {
+		otel.SetTracerProvider(previousProvider)
+	})
+
+	spansByName := func() map[string]sdktrace.ReadOnlySpan {
+		spans := map[string]sdktrace.ReadOnlySpan{}
+		for _, span := range recorder.Ended() {
+			spans[span.Name()] = span
+		}
+		return spans
+	}
+
+	It("should create a span per reconcile with child spans per machine and node update", func() {
+		_, err := r.ProcessMachineSet(context.TODO(), machineSet)
+		Expect(err).NotTo(HaveOccurred())
+
+		spans := spansByName()
+		Expect(spans).To(HaveKey("ReconcileMachineSet"))
+		Expect(spans).To(HaveKey("UpdateMachine"))
+		Expect(spans).To(HaveKey("UpdateNode"))
+
+		reconcileSpan := spans["ReconcileMachineSet"]
+		Expect(reconcileSpan.Attributes()).To(ContainElement(tracing.MachineSetKey.String("test-machineset")))
+		Expect(reconcileSpan.Attributes()).To(ContainElement(tracing.MachinesKey.Int(1)))
+
+		nodeSpan := spans["UpdateNode"]
+		Expect(nodeSpan.Parent().SpanID()).To(Equal(reconcileSpan.SpanContext().SpanID()))
+		Expect(nodeSpan.Attributes()).To(ContainElement(tracing.NodeKey.String("test-node")))
+		Expect(nodeSpan.Attributes()).To(ContainElement(attribute.Int("mnmo.label.added", 1)))
+		Expect(spans["UpdateMachine"].Parent().SpanID()).To(Equal(reconcileSpan.SpanContext().SpanID()))
+	})
+
+	It("should create a span for each API call", func() {
+		_, err := r.ProcessMachineSet(context.TODO(), machineSet)
+		Expect(err).NotTo(HaveOccurred())
+
+		spans := spansByName()
+		Expect(spans).To(HaveKey("Get Node"))
+		Expect(spans).To(HaveKey("Update Node"))
+		Expect(spans["Update Node"].Parent().SpanID()).To(Equal(spans["UpdateNode"].SpanContext().SpanID()))
+	})
+})
@@ -0,0 +1,106 @@
+package envtest_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/envtest"
+	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+
+	integration "github.com/openshift/managed-node-metadata-operator/int"
+	"github.com/openshift/managed-node-metadata-operator/int/fakemachine"
+	"github.com/openshift/managed-node-metadata-operator/pkg/audit"
+	"github.com/openshift/managed-node-metadata-operator/pkg/history"
+	"github.com/openshift/managed-node-metadata-operator/pkg/machine"
+	"github.com/openshift/managed-node-metadata-operator/pkg/operator"
+)
+
+const machineNamespace = "openshift-machine-api"
+
+var (
+	testEnv   *envtest.Environment
+	k8sClient client.Client
+	i         *integration.Integration
+	cancel    context.CancelFunc
+)
+
+func TestEnvtest(t *testing.T) {
+	// The API server and etcd binaries are set up by make test-envtest
+	if os.Getenv("KUBEBUILDER_ASSETS") == "" {
+		t.Skip("KUBEBUILDER_ASSETS isn't set, run the suite with make test-envtest")
+	}
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Envtest Suite")
+}
+
+var _ = BeforeSuite(func() {
+	ctrl.SetLogger(zap.New(zap.WriteTo(GinkgoWriter), zap.UseDevMode(true)))
+
+	testEnv = &envtest.Environment{
+		CRDDirectoryPaths:     []string{filepath.Join("testdata", "crds")},
+		ErrorIfCRDPathMissing: true,
+	}
+	cfg, err := testEnv.Start()
+	Expect(err).NotTo(HaveOccurred())
+
+	scheme := operator.NewScheme()
+	k8sClient, err = client.New(cfg, client.Options{Scheme: scheme})
+	Expect(err).NotTo(HaveOccurred())
+	for _, namespace := range []string{machineNamespace, history.DefaultNamespace} {
+		err = k8sClient.Create(context.TODO(), &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: namespace}})
+		Expect(err).NotTo(HaveOccurred())
+	}
+
+	// The operator as main.go runs it, without the endpoints and the leader election
+	mgr, err := operator.NewManager(cfg, scheme, operator.Options{
+		MetricsAddr: "0",
+		ProbeAddr:   "0",
+
+		Providers:                 []machine.Provider{machine.MachineAPIProvider{}},
+		Namespace:                 machineNamespace,
+		SyncAutoscalerAnnotations: true,
+		Audit:                     audit.Options{Sink: audit.SinkNone},
+		LivenessStallThreshold:    10 * time.Minute,
+		HistoryMaxGenerations:     10,
+		HistoryNamespace:          history.DefaultNamespace,
+		HistoryName:               history.DefaultName,
+	})
+	Expect(err).NotTo(HaveOccurred())
+	// Nothing turns the Machines into Nodes on envtest
+	Expect(fakemachine.SetupWithManager(mgr)).To(Succeed())
+
+	var ctx context.Context
+	ctx, cancel = context.WithCancel(context.Background())
+	go func() {
+		defer GinkgoRecover()
+		Expect(mgr.Start(ctx)).To(Succeed())
+	}()
+	Expect(mgr.GetCache().WaitForCacheSync(ctx)).To(BeTrue())
+
+	i, err = integration.NewIntegrationForConfig(cfg)
+	Expect(err).NotTo(HaveOccurred())
+	// The fake machine controller provisions in no time
+	i.ProvisionTimeout = integration.DefaultTimeout
+	i.PollInterval = 250 * time.Millisecond
+})
+
+var _ = AfterSuite(func() {
+	if i != nil {
+		i.Stop()
+	}
+	if cancel != nil {
+		cancel()
+	}
+	if testEnv != nil {
+		Expect(testEnv.Stop()).To(Succeed())
+	}
+})
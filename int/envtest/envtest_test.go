@@ -0,0 +1,167 @@
+package envtest_test
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	machinev1 "github.com/openshift/api/machine/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	k8serr "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/openshift/managed-node-metadata-operator/pkg/history"
+	"github.com/openshift/managed-node-metadata-operator/pkg/machine"
+)
+
+// createMachineSet creates a machine set with the template labels and taints, and waits for the fake machine
+// controller to provision a machine with a Ready node for each of its replicas
+func createMachineSet(replicas int32, labels map[string]string, taints []corev1.Taint) *machinev1.MachineSet {
+	base := &machinev1.MachineSet{
+		ObjectMeta: metav1.ObjectMeta{Namespace: machineNamespace, Name: "envtest"},
+		Spec: machinev1.MachineSetSpec{
+			Template: machinev1.MachineTemplateSpec{
+				Spec: machinev1.MachineSpec{
+					ObjectMeta: machinev1.ObjectMeta{Labels: labels},
+					Taints:     taints,
+				},
+			},
+		},
+	}
+	machineSet, err := i.CreateMachineSet(context.TODO(), base, replicas)
+	Expect(err).NotTo(HaveOccurred())
+	DeferCleanup(func() {
+		Expect(i.DeleteMachineSet(context.TODO(), machineSet)).To(Succeed())
+	})
+	return machineSet
+}
+
+// updateTemplate changes the template of the machine set
+func updateTemplate(machineSet *machinev1.MachineSet, update func(*machinev1.MachineSpec)) {
+	Expect(i.UpdateMachineSet(context.TODO(), machineSet, func(ms *machinev1.MachineSet) {
+		update(&ms.Spec.Template.Spec)
+	})).To(Succeed())
+}
+
+// isDeleted tells if the object doesn't exist anymore
+func isDeleted(obj client.Object) func() bool {
+	return func() bool {
+		return k8serr.IsNotFound(k8sClient.Get(context.TODO(), client.ObjectKeyFromObject(obj), obj.DeepCopyObject().(client.Object)))
+	}
+}
+
+var _ = Describe("Operator", func() {
+	taint := corev1.Taint{Key: "envtest-taint", Value: "v1", Effect: corev1.TaintEffectNoSchedule}
+	labels := map[string]string{"envtest-label": "v1"}
+
+	It("syncs the template labels and taints to the machines and nodes", func() {
+		machineSet := createMachineSet(2, labels, []corev1.Taint{taint})
+
+		Expect(i.WaitForNodeLabels(context.TODO(), machineSet, labels)).To(Succeed())
+		Expect(i.WaitForNodeTaints(context.TODO(), machineSet, []corev1.Taint{taint})).To(Succeed())
+		Expect(i.WaitForMachineSetSynced(context.TODO(), machineSet)).To(Succeed())
+	})
+
+	It("syncs changes of the template", func() {
+		machineSet := createMachineSet(1, labels, nil)
+		Expect(i.WaitForNodeLabels(context.TODO(), machineSet, labels)).To(Succeed())
+
+		updateTemplate(machineSet, func(spec *machinev1.MachineSpec) {
+			spec.Labels = map[string]string{"envtest-label": "v2"}
+			spec.Taints = []corev1.Taint{taint}
+		})
+		Expect(i.WaitForNodeLabels(context.TODO(), machineSet, map[string]string{"envtest-label": "v2"})).To(Succeed())
+		Expect(i.WaitForNodeTaints(context.TODO(), machineSet, []corev1.Taint{taint})).To(Succeed())
+	})
+
+	It("removes the labels and taints removed from the template", func() {
+		machineSet := createMachineSet(1, map[string]string{"envtest-label": "v1", "envtest-kept": "v1"}, []corev1.Taint{taint})
+		Expect(i.WaitForNodeLabels(context.TODO(), machineSet, labels)).To(Succeed())
+		Expect(i.WaitForNodeTaints(context.TODO(), machineSet, []corev1.Taint{taint})).To(Succeed())
+
+		updateTemplate(machineSet, func(spec *machinev1.MachineSpec) {
+			delete(spec.Labels, "envtest-label")
+			spec.Taints = nil
+		})
+		Expect(i.WaitForNodeLabelsRemoved(context.TODO(), machineSet, "envtest-label")).To(Succeed())
+		Expect(i.WaitForNodeTaintsRemoved(context.TODO(), machineSet, taint.Key)).To(Succeed())
+		Expect(i.WaitForNodeLabels(context.TODO(), machineSet, map[string]string{"envtest-kept": "v1"})).To(Succeed())
+	})
+
+	It("leaves the node labels it doesn't manage", func() {
+		machineSet := createMachineSet(1, labels, nil)
+		Expect(i.WaitForNodeLabels(context.TODO(), machineSet, labels)).To(Succeed())
+
+		nodes, err := i.GetNodesForMachineSet(machineSet)
+		Expect(err).NotTo(HaveOccurred())
+		for _, node := range nodes {
+			node.Labels["envtest-hand-set"] = "v1"
+			Expect(k8sClient.Update(context.TODO(), node)).To(Succeed())
+		}
+		updateTemplate(machineSet, func(spec *machinev1.MachineSpec) {
+			spec.Labels = map[string]string{"envtest-label": "v2"}
+		})
+		Expect(i.WaitForNodeLabels(context.TODO(), machineSet, map[string]string{"envtest-label": "v2"})).To(Succeed())
+		Expect(i.WaitForMachineSetSynced(context.TODO(), machineSet)).To(Succeed())
+
+		nodes, err = i.GetNodesForMachineSet(machineSet)
+		Expect(err).NotTo(HaveOccurred())
+		for _, node := range nodes {
+			Expect(node.Labels).To(HaveKeyWithValue("envtest-hand-set", "v1"))
+		}
+	})
+
+	It("records the applied templates in the history", func() {
+		machineSet := createMachineSet(1, labels, nil)
+		Expect(i.WaitForMachineSetSynced(context.TODO(), machineSet)).To(Succeed())
+
+		store := &history.Store{Client: k8sClient, Namespace: history.DefaultNamespace, Name: history.DefaultName}
+		key := history.Key(machine.MachineAPIProvider{}.Name(), machineSet.Namespace, machineSet.Name)
+		Eventually(func() ([]history.Snapshot, error) {
+			return store.Get(context.TODO(), key)
+		}).WithTimeout(i.Timeout).WithPolling(i.PollInterval).Should(ContainElement(HaveField("Labels", HaveKeyWithValue("envtest-label", "v1"))))
+	})
+
+	It("syncs the nodes of a scale up", func() {
+		machineSet := createMachineSet(1, labels, []corev1.Taint{taint})
+		Expect(i.WaitForNodeLabels(context.TODO(), machineSet, labels)).To(Succeed())
+
+		Expect(i.ScaleMachineSet(context.TODO(), machineSet, 3)).To(Succeed())
+		Expect(i.WaitForNodeLabels(context.TODO(), machineSet, labels)).To(Succeed())
+		Expect(i.WaitForNodeTaints(context.TODO(), machineSet, []corev1.Taint{taint})).To(Succeed())
+	})
+
+	It("deletes the nodes of a scale down", func() {
+		machineSet := createMachineSet(3, labels, nil)
+		Expect(i.WaitForNodeLabels(context.TODO(), machineSet, labels)).To(Succeed())
+		nodes, err := i.GetNodesForMachineSet(machineSet)
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(i.ScaleMachineSet(context.TODO(), machineSet, 1)).To(Succeed())
+		remaining, err := i.GetNodesForMachineSet(machineSet)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(remaining).To(HaveLen(1))
+		for _, node := range nodes {
+			if node.Name != remaining[0].Name {
+				Eventually(isDeleted(node)).WithTimeout(i.Timeout).WithPolling(i.PollInterval).Should(BeTrue())
+			}
+		}
+		Expect(i.WaitForNodeLabels(context.TODO(), machineSet, labels)).To(Succeed())
+	})
+
+	It("syncs the node of a replaced machine", func() {
+		machineSet := createMachineSet(2, labels, []corev1.Taint{taint})
+		Expect(i.WaitForNodeLabels(context.TODO(), machineSet, labels)).To(Succeed())
+
+		machines, err := machine.GetMachinesForMachineSet(k8sClient, machineSet)
+		Expect(err).NotTo(HaveOccurred())
+		replaced := machines[0]
+		Expect(k8sClient.Delete(context.TODO(), replaced)).To(Succeed())
+		Eventually(isDeleted(replaced)).WithTimeout(i.Timeout).WithPolling(i.PollInterval).Should(BeTrue())
+
+		Expect(i.WaitForMachineSetReady(context.TODO(), machineSet, 2)).To(Succeed())
+		Expect(i.WaitForNodeLabels(context.TODO(), machineSet, labels)).To(Succeed())
+		Expect(i.WaitForNodeTaints(context.TODO(), machineSet, []corev1.Taint{taint})).To(Succeed())
+	})
+})
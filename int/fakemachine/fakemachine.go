@@ -0,0 +1,244 @@
+// Package fakemachine simulates the machine-api controllers for local end-to-end runs, like on envtest where nothing
+// turns Machines into Nodes. It scales the MachineSets into Machines, creates a Ready Node for each Machine and
+// deletes it with its Machine. It doesn't copy the Machine labels and taints to the Nodes like the machine-api's
+// nodelink controller, so the Nodes only get them from the operator.
+package fakemachine
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	machinev1 "github.com/openshift/api/machine/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	k8serr "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+const (
+	// Finalizer holds the deleted Machines until their Node is deleted, like the machine-api's
+	Finalizer = "machine.machine.openshift.io"
+	// ProviderIDPrefix prefixes the provider IDs of the Machines and Nodes
+	ProviderIDPrefix = "fake://"
+)
+
+// ProviderID returns the provider ID of the Machine and its Node
+func ProviderID(machine *machinev1.Machine) string {
+	return ProviderIDPrefix + machine.Namespace + "/" + machine.Name
+}
+
+// MachineSetReconciler creates and deletes the Machines of the MachineSets to match their replicas, and reports the
+// replicas in their status
+type MachineSetReconciler struct {
+	Client client.Client
+	// APIReader lists the Machines from the API server, the cache could miss the Machines just created
+	APIReader client.Reader
+}
+
+// Reconcile scales the MachineSet, the newest Machines are deleted first
+func (r *MachineSetReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	logger := log.FromContext(ctx)
+	machineSet := &machinev1.MachineSet{}
+	if err := r.Client.Get(ctx, req.NamespacedName, machineSet); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+	if machineSet.DeletionTimestamp != nil {
+		return ctrl.Result{}, nil
+	}
+
+	machines, err := r.machines(ctx, machineSet)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	replicas := 0
+	if machineSet.Spec.Replicas != nil {
+		replicas = int(*machineSet.Spec.Replicas)
+	}
+	for i := len(machines); i < replicas; i++ {
+		machine := newMachine(machineSet)
+		if err := r.Client.Create(ctx, machine); err != nil {
+			return ctrl.Result{}, fmt.Errorf("failed to create a machine of machineset %s: %w", machineSet.Name, err)
+		}
+		logger.Info("created machine", "machine", machine.Name)
+		machines = append(machines, machine)
+	}
+	if len(machines) > replicas {
+		sort.SliceStable(machines, func(i, j int) bool {
+			return machines[j].CreationTimestamp.Before(&machines[i].CreationTimestamp)
+		})
+		for _, machine := range machines[:len(machines)-replicas] {
+			if err := r.Client.Delete(ctx, machine); client.IgnoreNotFound(err) != nil {
+				return ctrl.Result{}, fmt.Errorf("failed to delete machine %s: %w", machine.Name, err)
+			}
+			logger.Info("deleted machine", "machine", machine.Name)
+		}
+		machines = machines[len(machines)-replicas:]
+	}
+
+	ready := int32(0)
+	for _, machine := range machines {
+		if machine.Status.NodeRef != nil {
+			ready++
+		}
+	}
+	status := machinev1.MachineSetStatus{
+		Replicas:             int32(len(machines)),
+		FullyLabeledReplicas: int32(len(machines)),
+		ReadyReplicas:        ready,
+		AvailableReplicas:    ready,
+		ObservedGeneration:   machineSet.Generation,
+	}
+	if equality.Semantic.DeepEqual(machineSet.Status, status) {
+		return ctrl.Result{}, nil
+	}
+	machineSet.Status = status
+	return ctrl.Result{}, r.Client.Status().Update(ctx, machineSet)
+}
+
+// machines returns the Machines of the MachineSet that aren't being deleted
+func (r *MachineSetReconciler) machines(ctx context.Context, machineSet *machinev1.MachineSet) ([]*machinev1.Machine, error) {
+	selector, err := metav1.LabelSelectorAsSelector(&machineSet.Spec.Selector)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse the selector of machineset %s: %w", machineSet.Name, err)
+	}
+	list := &machinev1.MachineList{}
+	if err := r.APIReader.List(ctx, list, client.InNamespace(machineSet.Namespace), client.MatchingLabelsSelector{Selector: selector}); err != nil {
+		return nil, err
+	}
+	machines := []*machinev1.Machine{}
+	for i := range list.Items {
+		machine := &list.Items[i]
+		if machine.DeletionTimestamp == nil && metav1.IsControlledBy(machine, machineSet) {
+			machines = append(machines, machine)
+		}
+	}
+	return machines, nil
+}
+
+// newMachine returns a Machine of the template of the MachineSet
+func newMachine(machineSet *machinev1.MachineSet) *machinev1.Machine {
+	template := machineSet.Spec.Template.DeepCopy()
+	return &machinev1.Machine{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:       machineSet.Namespace,
+			GenerateName:    machineSet.Name + "-",
+			Labels:          template.Labels,
+			Annotations:     template.Annotations,
+			OwnerReferences: []metav1.OwnerReference{*metav1.NewControllerRef(machineSet, machinev1.GroupVersion.WithKind("MachineSet"))},
+		},
+		Spec: template.Spec,
+	}
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *MachineSetReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&machinev1.MachineSet{}).
+		Owns(&machinev1.Machine{}).
+		Named("fake_machineset_controller").
+		Complete(r)
+}
+
+// MachineReconciler provisions a Ready Node for each Machine, and deletes it when the Machine is deleted
+type MachineReconciler struct {
+	Client client.Client
+}
+
+// Reconcile provisions or deletes the Node of the Machine. The Node is named after the Machine.
+func (r *MachineReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	logger := log.FromContext(ctx)
+	machine := &machinev1.Machine{}
+	if err := r.Client.Get(ctx, req.NamespacedName, machine); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+
+	if machine.DeletionTimestamp != nil {
+		if !controllerutil.ContainsFinalizer(machine, Finalizer) {
+			return ctrl.Result{}, nil
+		}
+		if machine.Status.NodeRef != nil {
+			node := &corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: machine.Status.NodeRef.Name}}
+			if err := r.Client.Delete(ctx, node); client.IgnoreNotFound(err) != nil {
+				return ctrl.Result{}, fmt.Errorf("failed to delete node %s of machine %s: %w", node.Name, machine.Name, err)
+			}
+			logger.Info("deleted node", "node", node.Name)
+		}
+		controllerutil.RemoveFinalizer(machine, Finalizer)
+		return ctrl.Result{}, r.Client.Update(ctx, machine)
+	}
+
+	providerID := ProviderID(machine)
+	if !controllerutil.ContainsFinalizer(machine, Finalizer) || machine.Spec.ProviderID == nil {
+		controllerutil.AddFinalizer(machine, Finalizer)
+		machine.Spec.ProviderID = &providerID
+		if err := r.Client.Update(ctx, machine); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+	if machine.Status.NodeRef != nil {
+		return ctrl.Result{}, nil
+	}
+
+	node := &corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   machine.Name,
+			Labels: map[string]string{corev1.LabelHostname: machine.Name},
+		},
+		Spec: corev1.NodeSpec{ProviderID: providerID},
+	}
+	// The Node is left from a previous reconcile that failed to update the Machine
+	if err := r.Client.Create(ctx, node); k8serr.IsAlreadyExists(err) {
+		if err := r.Client.Get(ctx, client.ObjectKeyFromObject(node), node); err != nil {
+			return ctrl.Result{}, err
+		}
+	} else if err != nil {
+		return ctrl.Result{}, fmt.Errorf("failed to create node of machine %s: %w", machine.Name, err)
+	}
+	if !isReady(node) {
+		node.Status.Conditions = append(node.Status.Conditions, corev1.NodeCondition{
+			Type:               corev1.NodeReady,
+			Status:             corev1.ConditionTrue,
+			LastTransitionTime: metav1.Now(),
+			Reason:             "KubeletReady",
+		})
+		if err := r.Client.Status().Update(ctx, node); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+	logger.Info("provisioned node", "node", node.Name)
+
+	running := machinev1.PhaseRunning
+	machine.Status.NodeRef = &corev1.ObjectReference{Kind: "Node", Name: node.Name, UID: node.UID}
+	machine.Status.Phase = &running
+	return ctrl.Result{}, r.Client.Status().Update(ctx, machine)
+}
+
+func isReady(node *corev1.Node) bool {
+	for _, condition := range node.Status.Conditions {
+		if condition.Type == corev1.NodeReady {
+			return condition.Status == corev1.ConditionTrue
+		}
+	}
+	return false
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *MachineReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&machinev1.Machine{}).
+		Named("fake_machine_controller").
+		Complete(r)
+}
+
+// SetupWithManager sets up the MachineSet and Machine controllers with the Manager
+func SetupWithManager(mgr ctrl.Manager) error {
+	if err := (&MachineSetReconciler{Client: mgr.GetClient(), APIReader: mgr.GetAPIReader()}).SetupWithManager(mgr); err != nil {
+		return err
+	}
+	return (&MachineReconciler{Client: mgr.GetClient()}).SetupWithManager(mgr)
+}
@@ -0,0 +1,13 @@
+package fakemachine_test
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func TestFakemachine(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Fakemachine Suite")
+}
@@ -0,0 +1,134 @@
+package fakemachine
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	machinev1 "github.com/openshift/api/machine/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	k8serr "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+var _ = Describe("Fake machine controller", func() {
+	var (
+		fakeClient     client.Client
+		machineSets    *MachineSetReconciler
+		machines       *MachineReconciler
+		machineSetName = client.ObjectKey{Namespace: "test", Name: "test-machineset"}
+	)
+
+	s := runtime.NewScheme()
+	Expect(corev1.AddToScheme(s)).To(Succeed())
+	Expect(machinev1.AddToScheme(s)).To(Succeed())
+
+	listMachines := func() []machinev1.Machine {
+		list := &machinev1.MachineList{}
+		Expect(fakeClient.List(context.TODO(), list, client.InNamespace("test"))).To(Succeed())
+		return list.Items
+	}
+	// reconcile runs the controllers until the machine set and its machines settle
+	reconcile := func() {
+		for i := 0; i < 3; i++ {
+			_, err := machineSets.Reconcile(context.TODO(), ctrl.Request{NamespacedName: machineSetName})
+			Expect(err).NotTo(HaveOccurred())
+			for _, machine := range listMachines() {
+				_, err := machines.Reconcile(context.TODO(), ctrl.Request{NamespacedName: client.ObjectKeyFromObject(&machine)})
+				Expect(err).NotTo(HaveOccurred())
+			}
+		}
+	}
+	scale := func(replicas int32) {
+		machineSet := &machinev1.MachineSet{}
+		Expect(fakeClient.Get(context.TODO(), machineSetName, machineSet)).To(Succeed())
+		machineSet.Spec.Replicas = &replicas
+		Expect(fakeClient.Update(context.TODO(), machineSet)).To(Succeed())
+		reconcile()
+	}
+
+	BeforeEach(func() {
+		replicas := int32(2)
+		machineSet := &machinev1.MachineSet{
+			ObjectMeta: metav1.ObjectMeta{Namespace: machineSetName.Namespace, Name: machineSetName.Name, UID: "test-uid"},
+			Spec: machinev1.MachineSetSpec{
+				Replicas: &replicas,
+				Selector: metav1.LabelSelector{MatchLabels: map[string]string{"machineset": "test"}},
+				Template: machinev1.MachineTemplateSpec{
+					ObjectMeta: machinev1.ObjectMeta{Labels: map[string]string{"machineset": "test"}},
+					Spec: machinev1.MachineSpec{
+						ObjectMeta: machinev1.ObjectMeta{Labels: map[string]string{"test-label": "v1"}},
+					},
+				},
+			},
+		}
+		fakeClient = fake.NewClientBuilder().WithScheme(s).WithObjects(machineSet).
+			WithStatusSubresource(&machinev1.MachineSet{}, &machinev1.Machine{}, &corev1.Node{}).Build()
+		machineSets = &MachineSetReconciler{Client: fakeClient, APIReader: fakeClient}
+		machines = &MachineReconciler{Client: fakeClient}
+		reconcile()
+	})
+
+	It("creates the machines of the template with a Ready node each", func() {
+		items := listMachines()
+		Expect(items).To(HaveLen(2))
+		for _, machine := range items {
+			Expect(machine.Spec.Labels).To(HaveKeyWithValue("test-label", "v1"))
+			Expect(metav1.IsControlledBy(&machine, &machinev1.MachineSet{ObjectMeta: metav1.ObjectMeta{UID: "test-uid"}})).To(BeTrue())
+			Expect(machine.Spec.ProviderID).NotTo(BeNil())
+			Expect(*machine.Spec.ProviderID).To(Equal(ProviderID(&machine)))
+			Expect(machine.Status.NodeRef).NotTo(BeNil())
+			Expect(*machine.Status.Phase).To(Equal(machinev1.PhaseRunning))
+
+			node := &corev1.Node{}
+			Expect(fakeClient.Get(context.TODO(), client.ObjectKey{Name: machine.Status.NodeRef.Name}, node)).To(Succeed())
+			Expect(node.Spec.ProviderID).To(Equal(ProviderID(&machine)))
+			Expect(isReady(node)).To(BeTrue())
+			Expect(node.Labels).NotTo(HaveKey("test-label"))
+		}
+	})
+
+	It("reports the replicas in the machineset status", func() {
+		machineSet := &machinev1.MachineSet{}
+		Expect(fakeClient.Get(context.TODO(), machineSetName, machineSet)).To(Succeed())
+		Expect(machineSet.Status.Replicas).To(BeEquivalentTo(2))
+		Expect(machineSet.Status.ReadyReplicas).To(BeEquivalentTo(2))
+	})
+
+	It("scales up", func() {
+		scale(3)
+		Expect(listMachines()).To(HaveLen(3))
+		nodes := &corev1.NodeList{}
+		Expect(fakeClient.List(context.TODO(), nodes)).To(Succeed())
+		Expect(nodes.Items).To(HaveLen(3))
+	})
+
+	It("scales down, deleting the nodes of the deleted machines", func() {
+		scale(1)
+		items := listMachines()
+		Expect(items).To(HaveLen(1))
+		nodes := &corev1.NodeList{}
+		Expect(fakeClient.List(context.TODO(), nodes)).To(Succeed())
+		Expect(nodes.Items).To(HaveLen(1))
+		Expect(nodes.Items[0].Name).To(Equal(items[0].Status.NodeRef.Name))
+	})
+
+	It("replaces a deleted machine", func() {
+		deleted := listMachines()[0]
+		Expect(fakeClient.Delete(context.TODO(), &deleted)).To(Succeed())
+		reconcile()
+
+		items := listMachines()
+		Expect(items).To(HaveLen(2))
+		for _, machine := range items {
+			Expect(machine.Name).NotTo(Equal(deleted.Name))
+		}
+		err := fakeClient.Get(context.TODO(), client.ObjectKey{Name: deleted.Status.NodeRef.Name}, &corev1.Node{})
+		Expect(k8serr.IsNotFound(err)).To(BeTrue())
+	})
+})
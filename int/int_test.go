@@ -2,7 +2,6 @@ package int_test
 
 import (
 	"context"
-	"time"
 
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
@@ -10,15 +9,12 @@ import (
 
 	machinev1 "github.com/openshift/api/machine/v1beta1"
 	. "github.com/openshift/managed-node-metadata-operator/int"
-	m "github.com/openshift/managed-node-metadata-operator/pkg/machine"
 )
 
 var (
 	i *Integration
-)
-
-const (
-	MaxWaitTime = 30 * time.Second
+	// machineSet is a throwaway copy of the worker MachineSet the tests change, instead of the worker pool
+	machineSet *machinev1.MachineSet
 )
 
 var _ = BeforeSuite(func() {
@@ -27,331 +23,98 @@ var _ = BeforeSuite(func() {
 	Expect(err).NotTo(HaveOccurred())
 	err = i.DisableWebhook()
 	Expect(err).NotTo(HaveOccurred())
-})
 
-func setMachineSetLabel(machineset machinev1.MachineSet, label string, value string) {
-	machineset.Spec.Template.Spec.Labels = map[string]string{
-		label: value,
-	}
-	err := i.Client.Update(context.TODO(), &machineset)
+	// Leftovers of an interrupted run
+	Expect(i.DeleteThrowawayMachineSets(context.TODO())).To(Succeed())
+	workers, err := i.GetWorkerMachineSet()
 	Expect(err).NotTo(HaveOccurred())
-}
-
-func setMachineSetTaint(machineset machinev1.MachineSet, key string, value string) {
-	machineset.Spec.Template.Spec.Taints = []corev1.Taint{
-		{
-			Effect: corev1.TaintEffectPreferNoSchedule,
-			Value:  value,
-			Key:    key,
-		},
-	}
-	err := i.Client.Update(context.TODO(), &machineset)
+	machineSet, err = i.CreateMachineSet(context.TODO(), &workers, 1)
 	Expect(err).NotTo(HaveOccurred())
-}
+})
 
-func setNodeLabel(machineset machinev1.MachineSet, label string, value string) {
-	machines, err := m.GetMachinesForMachineSet(i.Client, &machineset)
-	Expect(err).ToNot(HaveOccurred())
-	Expect(len(machines)).To(BeNumerically(">", 0))
-	for _, machine := range machines {
-		node, err := m.GetNodeForMachine(i.Client, machine)
-		Expect(err).ToNot(HaveOccurred())
-		node.Labels[label] = value
-		err = i.Client.Update(context.TODO(), node)
-		Expect(err).NotTo(HaveOccurred())
+var _ = AfterSuite(func() {
+	if machineSet != nil {
+		Expect(i.DeleteMachineSet(context.TODO(), machineSet)).To(Succeed())
 	}
-}
+	i.Stop()
+})
 
-func cleanupMachineSetLabels(machineset machinev1.MachineSet) {
-	machineset.Spec.Template.Spec.Labels = map[string]string{}
-	err := i.Client.Update(context.TODO(), &machineset)
-	Expect(err).NotTo(HaveOccurred())
+func setMachineSetLabels(labels map[string]string) {
+	Expect(i.UpdateMachineSet(context.TODO(), machineSet, func(ms *machinev1.MachineSet) {
+		ms.Spec.Template.Spec.Labels = labels
+	})).To(Succeed())
 }
 
-func cleanupMachineSetTaint(machineset machinev1.MachineSet) {
-	machineset.Spec.Template.Spec.Taints = []corev1.Taint{}
-	err := i.Client.Update(context.TODO(), &machineset)
-	Expect(err).NotTo(HaveOccurred())
+func setMachineSetTaints(taints []corev1.Taint) {
+	Expect(i.UpdateMachineSet(context.TODO(), machineSet, func(ms *machinev1.MachineSet) {
+		ms.Spec.Template.Spec.Taints = taints
+	})).To(Succeed())
 }
 
-func removeMachineSetLabel(machineset machinev1.MachineSet, label string) {
-	delete(machineset.Spec.Template.Spec.Labels, label)
-	err := i.Client.Update(context.TODO(), &machineset)
-	Expect(err).NotTo(HaveOccurred())
-}
-
-func removeMachineSetTaint(machineset machinev1.MachineSet, key string) {
-	var newTaints []corev1.Taint
-
-	for i, taint := range machineset.Spec.Template.Spec.Taints {
-		if taint.Key == key {
-			continue
-		}
-		newTaints = append(newTaints, machineset.Spec.Template.Spec.Taints[i])
-	}
-	machineset.Spec.Template.Spec.Taints = newTaints
-	err := i.Client.Update(context.TODO(), &machineset)
+func setNodeLabel(label string, value string) {
+	nodes, err := i.GetNodesForMachineSet(machineSet)
 	Expect(err).NotTo(HaveOccurred())
-}
-
-func waitForNodeLabel(machineset machinev1.MachineSet, label string, value string, nodeOnly bool) {
-	lastFailure := ""
-
-	// Wait for a maximum of MaxWaitTime, if the timer goes off, mark the test as failed
-	timer := time.NewTimer(MaxWaitTime)
-	go func() {
-		<-timer.C
-		Fail("Label '" + label + "' did not get the expected value '" + value + "' after " + MaxWaitTime.String() + " on " + lastFailure)
-	}()
-
-	for {
-		time.Sleep(1 * time.Second)
-		machines, err := m.GetMachinesForMachineSet(i.Client, &machineset)
-		Expect(err).ToNot(HaveOccurred())
-		Expect(len(machines)).To(BeNumerically(">", 0))
-		allMachinesOk := true
-		for _, machine := range machines {
-			if !nodeOnly {
-				machineLabelValue, ok := machine.Spec.Labels[label]
-				if !ok {
-					allMachinesOk = false
-					lastFailure = "machine/" + machine.Name
-					continue
-				}
-				Expect(machineLabelValue).To(Equal(value))
-
-			}
-
-			node, err := m.GetNodeForMachine(i.Client, machine)
-			Expect(err).NotTo(HaveOccurred())
-			nodeLabelValue, ok := node.Labels[label]
-			if !ok {
-				allMachinesOk = false
-				lastFailure = "node/" + node.Name
-				continue
-			}
-			Expect(nodeLabelValue).To(Equal(value))
-		}
-		if allMachinesOk {
-			return
-		}
-	}
-}
-
-func waitForNodeTaint(machineset machinev1.MachineSet, key string, value string, nodeOnly bool) {
-	lastFailure := ""
-
-	// Wait for a maximum of MaxWaitTime, if the timer goes off, mark the test as failed
-	timer := time.NewTimer(MaxWaitTime)
-	go func() {
-		<-timer.C
-		Fail("Taint '" + key + "' did not get the expected value '" + value + "' after " + MaxWaitTime.String() + " on " + lastFailure)
-	}()
-
-	for {
-		time.Sleep(1 * time.Second)
-		machines, err := m.GetMachinesForMachineSet(i.Client, &machineset)
-		Expect(err).ToNot(HaveOccurred())
-		allMachinesOk := true
-		for _, machine := range machines {
-			if !nodeOnly {
-				machineTaintKeyExist := false
-				for _, taint := range machine.Spec.Taints {
-					if taint.Key == key {
-						machineTaintKeyExist = true
-						Expect(taint.Value).To(Equal(value))
-						break
-					}
-				}
-				if machineTaintKeyExist == false {
-					allMachinesOk = false
-					lastFailure = "machine/" + machine.Name
-					continue
-				}
-			}
-
-			node, err := m.GetNodeForMachine(i.Client, machine)
-			Expect(err).NotTo(HaveOccurred())
-			nodeTaintKeyExist := false
-			for _, taint := range node.Spec.Taints {
-				if taint.Key == key {
-					nodeTaintKeyExist = true
-					Expect(taint.Value).To(Equal(value))
-					break
-				}
-			}
-			if nodeTaintKeyExist == false {
-				allMachinesOk = false
-				lastFailure = "node/" + node.Name
-				continue
-			}
-		}
-		if allMachinesOk {
-			return
-		}
-	}
-}
-
-func waitForNodeLabelAbsence(machineset machinev1.MachineSet, label string) {
-	lastFailure := ""
-
-	// Wait for a maximum of MaxWaitTime, if the timer goes off, mark the test as failed
-	timer := time.NewTimer(MaxWaitTime)
-	go func() {
-		<-timer.C
-		Fail("Label '" + label + "' did not get removed as expected after " + MaxWaitTime.String() + " on " + lastFailure)
-	}()
-
-	for {
-		time.Sleep(1 * time.Second)
-		machines, err := m.GetMachinesForMachineSet(i.Client, &machineset)
-		Expect(err).ToNot(HaveOccurred())
-		Expect(len(machines)).To(BeNumerically(">", 0))
-		allMachinesOk := true
-		for _, machine := range machines {
-			_, ok := machine.Spec.Labels[label]
-			if ok {
-				allMachinesOk = false
-				lastFailure = "machine/" + machine.Name
-				continue
-			}
-
-			node, err := m.GetNodeForMachine(i.Client, machine)
-			Expect(err).NotTo(HaveOccurred())
-			_, ok = node.Labels[label]
-			if ok {
-				allMachinesOk = false
-				lastFailure = "node/" + node.Name
-				continue
-			}
-		}
-		if allMachinesOk {
-			return
-		}
-	}
-}
-
-func waitForNodeTaintAbsence(machineset machinev1.MachineSet, key string) {
-	lastFailure := ""
-
-	// Wait for a maximum of MaxWaitTime, if the timer goes off, mark the test as failed
-	timer := time.NewTimer(MaxWaitTime)
-	go func() {
-		<-timer.C
-		Fail("Taint '" + key + "' did not get removed as expected after " + MaxWaitTime.String() + " on " + lastFailure)
-	}()
-
-	for {
-		time.Sleep(1 * time.Second)
-		machines, err := m.GetMachinesForMachineSet(i.Client, &machineset)
-		Expect(err).ToNot(HaveOccurred())
-		allMachinesOk := true
-		for _, machine := range machines {
-			for _, taint := range machine.Spec.Taints {
-				if taint.Key == key {
-					allMachinesOk = false
-					lastFailure = "machine/" + machine.Name
-					continue
-				}
-			}
-
-			node, err := m.GetNodeForMachine(i.Client, machine)
-			Expect(err).NotTo(HaveOccurred())
-			for _, taint := range node.Spec.Taints {
-				if taint.Key == key {
-					allMachinesOk = false
-					lastFailure = "node/" + node.Name
-					continue
-				}
-			}
-		}
-		if allMachinesOk {
-			return
-		}
+	Expect(nodes).NotTo(BeEmpty())
+	for _, node := range nodes {
+		node.Labels[label] = value
+		Expect(i.Client.Update(context.TODO(), node)).To(Succeed())
 	}
 }
 
 var _ = Describe("Integrationtests", func() {
-	var (
-		TestLabel      string
-		TestTaint      string
-		TestValue      string
-		TestValueTaint string
-		workers        machinev1.MachineSet
+	const (
+		TestLabel      = "Fake-Node-Label"
+		TestValue      = "Fake-Node-Label-Value"
+		TestTaint      = "Fake-Node-Taint"
+		TestValueTaint = "Fake-Node-Taint-Value"
 	)
-	BeforeEach(func() {
-		var err error
-		workers, err = i.GetWorkerMachineSet()
-		Expect(err).NotTo(HaveOccurred())
-	})
+	testTaint := corev1.Taint{Key: TestTaint, Value: TestValueTaint, Effect: corev1.TaintEffectPreferNoSchedule}
 
 	Context("When adding a label to a MachineSet", func() {
 		Context("When the label doesn't exist on the Node", func() {
 			BeforeEach(func() {
-				TestLabel = "Fake-Node-Label"
-				TestValue = "Fake-Node-Label-Value"
-
 				//Make sure the label is not set before adding it
-				cleanupMachineSetLabels(workers)
-				waitForNodeLabelAbsence(workers, TestLabel)
-
-				//refresh workers
-				var err error
-				workers, err = i.GetWorkerMachineSet()
-				Expect(err).NotTo(HaveOccurred())
+				setMachineSetLabels(map[string]string{})
+				Expect(i.WaitForNodeLabelsRemoved(context.TODO(), machineSet, TestLabel)).To(Succeed())
 			})
 
 			It("Is applied to the Nodes and Machines of the MachineSet", func() {
-				setMachineSetLabel(workers, TestLabel, TestValue)
-				waitForNodeLabel(workers, TestLabel, TestValue, false)
+				setMachineSetLabels(map[string]string{TestLabel: TestValue})
+				Expect(i.WaitForNodeLabels(context.TODO(), machineSet, map[string]string{TestLabel: TestValue})).To(Succeed())
 			})
 
 			AfterEach(func() {
-				//refresh workers
-				var err error
-				workers, err = i.GetWorkerMachineSet()
-				Expect(err).NotTo(HaveOccurred())
-
 				//Clean up
-				cleanupMachineSetLabels(workers)
-				waitForNodeLabelAbsence(workers, TestLabel)
+				setMachineSetLabels(map[string]string{})
+				Expect(i.WaitForNodeLabelsRemoved(context.TODO(), machineSet, TestLabel)).To(Succeed())
 			})
 
 		})
 		Context("When overriding a label of a Node", func() {
 			BeforeEach(func() {
-				TestLabel = "Fake-Node-Label"
-				TestValue = "Fake-Node-Label-Value"
-
 				//Make sure the label is not set before adding it
-				cleanupMachineSetLabels(workers)
-				waitForNodeLabelAbsence(workers, TestLabel)
-
-				//refresh workers
-				var err error
-				workers, err = i.GetWorkerMachineSet()
-				Expect(err).NotTo(HaveOccurred())
+				setMachineSetLabels(map[string]string{})
+				Expect(i.WaitForNodeLabelsRemoved(context.TODO(), machineSet, TestLabel)).To(Succeed())
 			})
 			It("Doesn't change", func() {
-				workers.Spec.Template.Spec.Labels = map[string]string{
+				setMachineSetLabels(map[string]string{
 					TestLabel:                        TestValue,
 					"node-role.kubernetes.io/worker": "overruled",
-				}
-				err := i.Client.Update(context.TODO(), &workers)
+				})
+				Expect(i.WaitForNodeLabels(context.TODO(), machineSet, map[string]string{TestLabel: TestValue})).To(Succeed())
+				Expect(i.WaitForMachineSetSynced(context.TODO(), machineSet)).To(Succeed())
+
+				nodes, err := i.GetNodesForMachineSet(machineSet)
 				Expect(err).NotTo(HaveOccurred())
-				waitForNodeLabel(workers, TestLabel, TestValue, false)
-				waitForNodeLabel(workers, "node-role.kubernetes.io/worker", "", true)
+				for _, node := range nodes {
+					Expect(node.Labels).To(HaveKeyWithValue("node-role.kubernetes.io/worker", ""))
+				}
 			})
 			AfterEach(func() {
-				//refresh workers
-				var err error
-				workers, err = i.GetWorkerMachineSet()
-				Expect(err).NotTo(HaveOccurred())
-
 				//Clean up
-				cleanupMachineSetLabels(workers)
-				waitForNodeLabelAbsence(workers, TestLabel)
-				setNodeLabel(workers, "node-role.kubernetes.io/worker", "")
+				setMachineSetLabels(map[string]string{})
+				Expect(i.WaitForNodeLabelsRemoved(context.TODO(), machineSet, TestLabel)).To(Succeed())
+				setNodeLabel("node-role.kubernetes.io/worker", "")
 			})
 		})
 	})
@@ -359,22 +122,14 @@ var _ = Describe("Integrationtests", func() {
 	Context("When removing a label from a MachineSet", func() {
 		Context("When the label exists on the Node", func() {
 			BeforeEach(func() {
-				TestLabel = "Fake-Node-Label"
-				TestValue = "Fake-Node-Label-Value"
-
 				//Add Label and wait for it to appear, so we have something to remove
-				setMachineSetLabel(workers, TestLabel, TestValue)
-				waitForNodeLabel(workers, TestLabel, TestValue, false)
-
-				//refresh workers
-				var err error
-				workers, err = i.GetWorkerMachineSet()
-				Expect(err).NotTo(HaveOccurred())
+				setMachineSetLabels(map[string]string{TestLabel: TestValue})
+				Expect(i.WaitForNodeLabels(context.TODO(), machineSet, map[string]string{TestLabel: TestValue})).To(Succeed())
 			})
 
 			It("Is removed from Nodes and Machines of the MachineSet", func() {
-				removeMachineSetLabel(workers, TestLabel)
-				waitForNodeLabelAbsence(workers, TestLabel)
+				setMachineSetLabels(map[string]string{})
+				Expect(i.WaitForNodeLabelsRemoved(context.TODO(), machineSet, TestLabel)).To(Succeed())
 			})
 		})
 	})
@@ -382,33 +137,20 @@ var _ = Describe("Integrationtests", func() {
 	Context("When adding a taint to a MachineSet", func() {
 		Context("When the taint doesn't exist on the Node", func() {
 			BeforeEach(func() {
-				TestTaint = "Fake-Node-Taint"
-				TestValueTaint = "Fake-Node-Taint-Value"
-
 				//Make sure the taint is not set before adding it
-				cleanupMachineSetTaint(workers)
-				waitForNodeTaintAbsence(workers, TestTaint)
-
-				//refresh workers
-				var err error
-				workers, err = i.GetWorkerMachineSet()
-				Expect(err).NotTo(HaveOccurred())
+				setMachineSetTaints([]corev1.Taint{})
+				Expect(i.WaitForNodeTaintsRemoved(context.TODO(), machineSet, TestTaint)).To(Succeed())
 			})
 
 			It("Is applied to the Nodes and Machines of the MachineSet", func() {
-				setMachineSetTaint(workers, TestTaint, TestValueTaint)
-				waitForNodeTaint(workers, TestTaint, TestValueTaint, false)
+				setMachineSetTaints([]corev1.Taint{testTaint})
+				Expect(i.WaitForNodeTaints(context.TODO(), machineSet, []corev1.Taint{testTaint})).To(Succeed())
 			})
 
 			AfterEach(func() {
-				//refresh workers
-				var err error
-				workers, err = i.GetWorkerMachineSet()
-				Expect(err).NotTo(HaveOccurred())
-
 				//Clean up
-				cleanupMachineSetTaint(workers)
-				waitForNodeTaintAbsence(workers, TestTaint)
+				setMachineSetTaints([]corev1.Taint{})
+				Expect(i.WaitForNodeTaintsRemoved(context.TODO(), machineSet, TestTaint)).To(Succeed())
 			})
 		})
 	})
@@ -416,22 +158,14 @@ var _ = Describe("Integrationtests", func() {
 	Context("When removing a taint from a MachineSet", func() {
 		Context("When the taint exists on the Node", func() {
 			BeforeEach(func() {
-				TestTaint = "Fake-Node-Taint"
-				TestValueTaint = "Fake-Node-Taint-Value"
-
 				//Add Taint and wait for it to appear, so we have something to remove
-				setMachineSetTaint(workers, TestTaint, TestValueTaint)
-				waitForNodeTaint(workers, TestTaint, TestValueTaint, false)
-
-				//refresh workers
-				var err error
-				workers, err = i.GetWorkerMachineSet()
-				Expect(err).NotTo(HaveOccurred())
+				setMachineSetTaints([]corev1.Taint{testTaint})
+				Expect(i.WaitForNodeTaints(context.TODO(), machineSet, []corev1.Taint{testTaint})).To(Succeed())
 			})
 
 			It("Is removed from Nodes and Machines of the MachineSet", func() {
-				removeMachineSetTaint(workers, TestTaint)
-				waitForNodeTaintAbsence(workers, TestTaint)
+				setMachineSetTaints([]corev1.Taint{})
+				Expect(i.WaitForNodeTaintsRemoved(context.TODO(), machineSet, TestTaint)).To(Succeed())
 			})
 		})
 	})
@@ -3,63 +3,138 @@ package int
 import (
 	"context"
 	"fmt"
+	"sort"
+	"strings"
 	"time"
 
 	machinev1 "github.com/openshift/api/machine/v1beta1"
 	admissionregistration "k8s.io/api/admissionregistration/v1"
+	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	utilrand "k8s.io/apimachinery/pkg/util/rand"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/apimachinery/pkg/util/wait"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/util/retry"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/manager"
+	metricsserver "sigs.k8s.io/controller-runtime/pkg/metrics/server"
 	"sigs.k8s.io/controller-runtime/pkg/webhook"
+
+	"github.com/openshift/managed-node-metadata-operator/controllers"
+	m "github.com/openshift/managed-node-metadata-operator/pkg/machine"
+)
+
+const (
+	// MachineAPINamespace is the namespace of the Machine API machine sets
+	MachineAPINamespace = "openshift-machine-api"
+	// ThrowawayLabel marks the MachineSets created by CreateMachineSet, so leftovers can be deleted
+	ThrowawayLabel = "managed.openshift.com/integration-test"
+	// MachineSetLabel selects the Machines of a MachineSet
+	MachineSetLabel = "machine.openshift.io/cluster-api-machineset"
+
+	// DefaultTimeout is how long the Wait helpers wait for the operator to sync
+	DefaultTimeout = 30 * time.Second
+	// DefaultProvisionTimeout is how long to wait for Machines to be provisioned or deleted
+	DefaultProvisionTimeout = 15 * time.Minute
+	// DefaultPollInterval is how often the Wait helpers check the cluster
+	DefaultPollInterval = time.Second
 )
 
 // Integration is an integration testing toolset, providing utilities to get resources from a cluster
 type Integration struct {
 	Client client.Client
+	// Timeout bounds the waits for the operator to sync
+	Timeout time.Duration
+	// ProvisionTimeout bounds the waits for Machines to be provisioned or deleted
+	ProvisionTimeout time.Duration
+	// PollInterval is how often the waits check the cluster
+	PollInterval time.Duration
+
 	mgr    manager.Manager
+	cancel context.CancelFunc
 }
 
 // NewIntegration creates a new integration testing toolset, providing utilities to get resources from a cluster
 func NewIntegration() (*Integration, error) {
+	return NewIntegrationForConfig(ctrl.GetConfigOrDie())
+}
+
+// NewIntegrationForConfig creates a new integration testing toolset for the cluster of the config, and returns once
+// its cache of the MachineSets, Machines and Nodes is synced
+func NewIntegrationForConfig(cfg *rest.Config) (*Integration, error) {
 	scheme := runtime.NewScheme()
 	utilruntime.Must(clientgoscheme.AddToScheme(scheme))
 
 	utilruntime.Must(machinev1.AddToScheme(scheme))
 	utilruntime.Must(admissionregistration.AddToScheme(scheme))
 
-	mgr, err := ctrl.NewManager(ctrl.GetConfigOrDie(), ctrl.Options{
+	mgr, err := ctrl.NewManager(cfg, ctrl.Options{
 		Scheme:        scheme,
+		Metrics:       metricsserver.Options{BindAddress: "0"},
 		WebhookServer: webhook.NewServer(webhook.Options{Port: 9999}),
 	})
 	if err != nil {
 		return &Integration{}, err
 	}
-	i := Integration{mgr.GetClient(), mgr}
-	go func() {
-		err := mgr.GetCache().Start(context.TODO())
-		if err != nil {
-			panic(err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	i := Integration{
+		Client:           mgr.GetClient(),
+		Timeout:          DefaultTimeout,
+		ProvisionTimeout: DefaultProvisionTimeout,
+		PollInterval:     DefaultPollInterval,
+		mgr:              mgr,
+		cancel:           cancel,
+	}
+	// The informers are set up before the cache starts, so the sync covers them
+	for _, obj := range []client.Object{&machinev1.MachineSet{}, &machinev1.Machine{}, &corev1.Node{}} {
+		if _, err := mgr.GetCache().GetInformer(ctx, obj); err != nil {
+			cancel()
+			return &Integration{}, err
 		}
+	}
+	cacheErr := make(chan error, 1)
+	go func() {
+		cacheErr <- mgr.GetCache().Start(ctx)
 	}()
 
-	// Wait for cache to start. Is there a better way?
-	time.Sleep(2 * time.Second)
+	syncCtx, syncCancel := context.WithTimeout(ctx, DefaultTimeout)
+	defer syncCancel()
+	if !mgr.GetCache().WaitForCacheSync(syncCtx) {
+		cancel()
+		select {
+		case err := <-cacheErr:
+			return &Integration{}, fmt.Errorf("failed to start the cache: %w", err)
+		default:
+			return &Integration{}, fmt.Errorf("cache didn't sync within %s", DefaultTimeout)
+		}
+	}
 	return &i, nil
 }
 
+// Stop stops the cache of the toolset
+func (i *Integration) Stop() {
+	if i.cancel != nil {
+		i.cancel()
+	}
+}
+
 // GetWorkerMachineSet returns the MachineSet with the worker label
 func (i *Integration) GetWorkerMachineSet() (machinev1.MachineSet, error) {
 	msList := &machinev1.MachineSetList{}
-	err := i.Client.List(context.Background(), msList, client.InNamespace("openshift-machine-api"))
+	err := i.Client.List(context.Background(), msList, client.InNamespace(MachineAPINamespace))
 	if err != nil {
 		return machinev1.MachineSet{}, err
 	}
 	for _, ms := range msList.Items {
+		if ms.Labels[ThrowawayLabel] != "" {
+			continue
+		}
 		role, ok := ms.Labels["hive.openshift.io/machine-pool"]
 		if ok && role == "worker" {
 			return ms, nil
@@ -86,3 +161,332 @@ func (i *Integration) DisableWebhook() error {
 	}
 	return err
 }
+
+// CreateMachineSet creates a throwaway MachineSet with the spec of the base MachineSet, like the worker MachineSet,
+// and waits for its replicas to have a Ready Node. Tests change it instead of the base, and delete it with
+// DeleteMachineSet.
+func (i *Integration) CreateMachineSet(ctx context.Context, base *machinev1.MachineSet, replicas int32) (*machinev1.MachineSet, error) {
+	name := base.Name + "-" + utilrand.String(5)
+	machineSet := &machinev1.MachineSet{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: base.Namespace,
+			Name:      name,
+			Labels:    map[string]string{ThrowawayLabel: "true"},
+		},
+		Spec: *base.Spec.DeepCopy(),
+	}
+	machineSet.Spec.Replicas = &replicas
+	if machineSet.Spec.Selector.MatchLabels == nil {
+		machineSet.Spec.Selector.MatchLabels = map[string]string{}
+	}
+	machineSet.Spec.Selector.MatchLabels[MachineSetLabel] = name
+	if machineSet.Spec.Template.Labels == nil {
+		machineSet.Spec.Template.Labels = map[string]string{}
+	}
+	machineSet.Spec.Template.Labels[MachineSetLabel] = name
+
+	if err := i.Client.Create(ctx, machineSet); err != nil {
+		return nil, err
+	}
+	return machineSet, i.WaitForMachineSetReady(ctx, machineSet, replicas)
+}
+
+// ScaleMachineSet changes the replicas of the MachineSet and waits for them to have a Ready Node
+func (i *Integration) ScaleMachineSet(ctx context.Context, machineSet *machinev1.MachineSet, replicas int32) error {
+	if err := i.UpdateMachineSet(ctx, machineSet, func(ms *machinev1.MachineSet) {
+		ms.Spec.Replicas = &replicas
+	}); err != nil {
+		return err
+	}
+	return i.WaitForMachineSetReady(ctx, machineSet, replicas)
+}
+
+// UpdateMachineSet applies the update to the latest MachineSet, retrying on conflicts with the operator's patches
+func (i *Integration) UpdateMachineSet(ctx context.Context, machineSet *machinev1.MachineSet, update func(*machinev1.MachineSet)) error {
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		if err := i.Client.Get(ctx, client.ObjectKeyFromObject(machineSet), machineSet); err != nil {
+			return err
+		}
+		update(machineSet)
+		return i.Client.Update(ctx, machineSet)
+	})
+}
+
+// DeleteMachineSet scales the MachineSet down to delete its Machines, as nothing garbage collects them on envtest,
+// then deletes it
+func (i *Integration) DeleteMachineSet(ctx context.Context, machineSet *machinev1.MachineSet) error {
+	if err := i.ScaleMachineSet(ctx, machineSet, 0); err != nil {
+		return err
+	}
+	return client.IgnoreNotFound(i.Client.Delete(ctx, machineSet))
+}
+
+// DeleteThrowawayMachineSets deletes the MachineSets created by CreateMachineSet, like the ones left by an
+// interrupted run
+func (i *Integration) DeleteThrowawayMachineSets(ctx context.Context) error {
+	msList := &machinev1.MachineSetList{}
+	if err := i.Client.List(ctx, msList, client.InNamespace(MachineAPINamespace), client.HasLabels{ThrowawayLabel}); err != nil {
+		return err
+	}
+	for idx := range msList.Items {
+		if err := i.DeleteMachineSet(ctx, &msList.Items[idx]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WaitForMachineSetReady waits until the MachineSet has the replicas, each with a Ready Node
+func (i *Integration) WaitForMachineSetReady(ctx context.Context, machineSet *machinev1.MachineSet, replicas int32) error {
+	return i.wait(ctx, machineSet, fmt.Sprintf("%d ready replicas", replicas), i.ProvisionTimeout, func() error {
+		machines, err := m.GetMachinesForMachineSet(i.Client, machineSet)
+		if err != nil {
+			return err
+		}
+		if len(machines) != int(replicas) {
+			return fmt.Errorf("%d machines", len(machines))
+		}
+		for _, machine := range machines {
+			node, err := i.nodeOf(machine)
+			if err != nil {
+				return err
+			}
+			if !m.IsNodeReady(node) {
+				return fmt.Errorf("node %s isn't Ready", node.Name)
+			}
+		}
+		return nil
+	})
+}
+
+// WaitForMachineSetSynced waits until the operator synced the labels and taints of the template of the MachineSet to
+// all its Machines and their Nodes
+func (i *Integration) WaitForMachineSetSynced(ctx context.Context, machineSet *machinev1.MachineSet) error {
+	return i.wait(ctx, machineSet, "synced", i.Timeout, func() error {
+		current := &machinev1.MachineSet{}
+		if err := i.Client.Get(ctx, client.ObjectKeyFromObject(machineSet), current); err != nil {
+			return err
+		}
+		synced, err := i.machineSetSynced(ctx, current)
+		if err != nil {
+			return err
+		}
+		if !synced {
+			return fmt.Errorf("generation %d isn't synced", current.Generation)
+		}
+		return nil
+	})
+}
+
+// machineSetSynced tells if the Machines of the MachineSet and their Nodes have the labels and taints of its template,
+// as planned by mnmoctl diff. Machines without a Node yet aren't synced.
+func (i *Integration) machineSetSynced(ctx context.Context, machineSet *machinev1.MachineSet) (bool, error) {
+	plans, err := controllers.Diff(ctx, i.Client, []m.Provider{m.MachineAPIProvider{}}, machineSet.Namespace, machineSet.Name)
+	if err != nil {
+		return false, err
+	}
+	if len(plans) != 1 {
+		return false, fmt.Errorf("machineset %s/%s not found", machineSet.Namespace, machineSet.Name)
+	}
+	return plans[0].Synced(), nil
+}
+
+// WaitForNodeLabels waits until the Machines and Nodes of the MachineSet have the labels
+func (i *Integration) WaitForNodeLabels(ctx context.Context, machineSet *machinev1.MachineSet, labels map[string]string) error {
+	return i.waitForMachines(ctx, machineSet, "labels "+formatLabels(labels), func(machineLabels map[string]string, _ []corev1.Taint, node *corev1.Node) error {
+		for key, value := range labels {
+			if machineLabels[key] != value {
+				return fmt.Errorf("machine label %s is %q, expected %q", key, machineLabels[key], value)
+			}
+			if node.Labels[key] != value {
+				return fmt.Errorf("node %s label %s is %q, expected %q", node.Name, key, node.Labels[key], value)
+			}
+		}
+		return nil
+	})
+}
+
+// WaitForNodeLabelsRemoved waits until the Machines and Nodes of the MachineSet don't have labels of the keys
+func (i *Integration) WaitForNodeLabelsRemoved(ctx context.Context, machineSet *machinev1.MachineSet, keys ...string) error {
+	return i.waitForMachines(ctx, machineSet, "labels "+strings.Join(keys, ",")+" removed", func(machineLabels map[string]string, _ []corev1.Taint, node *corev1.Node) error {
+		for _, key := range keys {
+			if _, ok := machineLabels[key]; ok {
+				return fmt.Errorf("machine still has label %s", key)
+			}
+			if _, ok := node.Labels[key]; ok {
+				return fmt.Errorf("node %s still has label %s", node.Name, key)
+			}
+		}
+		return nil
+	})
+}
+
+// WaitForNodeTaints waits until the Machines and Nodes of the MachineSet have the taints, with their values
+func (i *Integration) WaitForNodeTaints(ctx context.Context, machineSet *machinev1.MachineSet, taints []corev1.Taint) error {
+	return i.waitForMachines(ctx, machineSet, "taints "+formatTaints(taints), func(_ map[string]string, machineTaints []corev1.Taint, node *corev1.Node) error {
+		for _, taint := range taints {
+			if !hasTaint(machineTaints, taint) {
+				return fmt.Errorf("machine doesn't have taint %s", taint.ToString())
+			}
+			if !hasTaint(node.Spec.Taints, taint) {
+				return fmt.Errorf("node %s doesn't have taint %s", node.Name, taint.ToString())
+			}
+		}
+		return nil
+	})
+}
+
+// WaitForNodeTaintsRemoved waits until the Machines and Nodes of the MachineSet don't have taints of the keys
+func (i *Integration) WaitForNodeTaintsRemoved(ctx context.Context, machineSet *machinev1.MachineSet, keys ...string) error {
+	return i.waitForMachines(ctx, machineSet, "taints "+strings.Join(keys, ",")+" removed", func(_ map[string]string, machineTaints []corev1.Taint, node *corev1.Node) error {
+		for _, key := range keys {
+			for _, taint := range machineTaints {
+				if taint.Key == key {
+					return fmt.Errorf("machine still has taint %s", taint.ToString())
+				}
+			}
+			for _, taint := range node.Spec.Taints {
+				if taint.Key == key {
+					return fmt.Errorf("node %s still has taint %s", node.Name, taint.ToString())
+				}
+			}
+		}
+		return nil
+	})
+}
+
+// waitForMachines waits until the check passes on all the Machines of the MachineSet and their Nodes
+func (i *Integration) waitForMachines(ctx context.Context, machineSet *machinev1.MachineSet, expected string, check func(machineLabels map[string]string, machineTaints []corev1.Taint, node *corev1.Node) error) error {
+	return i.wait(ctx, machineSet, expected, i.Timeout, func() error {
+		machines, err := m.GetMachinesForMachineSet(i.Client, machineSet)
+		if err != nil {
+			return err
+		}
+		if len(machines) == 0 {
+			return fmt.Errorf("no machines")
+		}
+		for _, machine := range machines {
+			node, err := i.nodeOf(machine)
+			if err != nil {
+				return err
+			}
+			if err := check(machine.Spec.Labels, machine.Spec.Taints, node); err != nil {
+				return fmt.Errorf("machine %s: %w", machine.Name, err)
+			}
+		}
+		return nil
+	})
+}
+
+// wait polls the condition until it passes or the timeout expires, and then returns the last failure of the
+// condition with a dump of the MachineSet, its Machines and their Nodes
+func (i *Integration) wait(ctx context.Context, machineSet *machinev1.MachineSet, expected string, timeout time.Duration, condition func() error) error {
+	var lastErr error
+	err := wait.PollUntilContextTimeout(ctx, i.PollInterval, timeout, true, func(context.Context) (bool, error) {
+		lastErr = condition()
+		return lastErr == nil, nil
+	})
+	if err == nil {
+		return nil
+	}
+	if lastErr == nil {
+		lastErr = err
+	}
+	return fmt.Errorf("machineset %s didn't get %s within %s: %w\n%s", machineSet.Name, expected, timeout, lastErr, i.Dump(ctx, machineSet))
+}
+
+// GetNodesForMachineSet returns the Nodes of the Machines of the MachineSet, the Machines without a Node yet are left
+// out
+func (i *Integration) GetNodesForMachineSet(machineSet *machinev1.MachineSet) ([]*corev1.Node, error) {
+	machines, err := m.GetMachinesForMachineSet(i.Client, machineSet)
+	if err != nil {
+		return nil, err
+	}
+	nodes := []*corev1.Node{}
+	for _, machine := range machines {
+		if machine.Status.NodeRef == nil {
+			continue
+		}
+		node, err := m.GetNodeForMachine(i.Client, machine)
+		if err != nil {
+			return nil, err
+		}
+		nodes = append(nodes, node)
+	}
+	return nodes, nil
+}
+
+// nodeOf returns the Node of the Machine
+func (i *Integration) nodeOf(machine *machinev1.Machine) (*corev1.Node, error) {
+	if machine.Status.NodeRef == nil {
+		return nil, fmt.Errorf("machine %s has no node", machine.Name)
+	}
+	return m.GetNodeForMachine(i.Client, machine)
+}
+
+// Dump describes the MachineSet, its Machines and their Nodes, with their labels and taints, to diagnose a failure
+func (i *Integration) Dump(ctx context.Context, machineSet *machinev1.MachineSet) string {
+	var b strings.Builder
+	current := &machinev1.MachineSet{}
+	if err := i.Client.Get(ctx, client.ObjectKeyFromObject(machineSet), current); err != nil {
+		fmt.Fprintf(&b, "machineset %s/%s: %v\n", machineSet.Namespace, machineSet.Name, err)
+		return b.String()
+	}
+	synced, err := i.machineSetSynced(ctx, current)
+	if err != nil {
+		fmt.Fprintf(&b, "machineset %s/%s generation %d, synced: %v\n", current.Namespace, current.Name, current.Generation, err)
+	} else {
+		fmt.Fprintf(&b, "machineset %s/%s generation %d, synced %t\n", current.Namespace, current.Name, current.Generation, synced)
+	}
+	fmt.Fprintf(&b, "  template labels: %s\n", formatLabels(current.Spec.Template.Spec.Labels))
+	fmt.Fprintf(&b, "  template taints: %s\n", formatTaints(current.Spec.Template.Spec.Taints))
+	machines, err := m.GetMachinesForMachineSet(i.Client, current)
+	if err != nil {
+		fmt.Fprintf(&b, "  machines: %v\n", err)
+		return b.String()
+	}
+	for _, machine := range machines {
+		phase := ""
+		if machine.Status.Phase != nil {
+			phase = *machine.Status.Phase
+		}
+		fmt.Fprintf(&b, "  machine %s phase %q\n", machine.Name, phase)
+		fmt.Fprintf(&b, "    labels: %s\n", formatLabels(machine.Spec.Labels))
+		fmt.Fprintf(&b, "    taints: %s\n", formatTaints(machine.Spec.Taints))
+		node, err := i.nodeOf(machine)
+		if err != nil {
+			fmt.Fprintf(&b, "    node: %v\n", err)
+			continue
+		}
+		fmt.Fprintf(&b, "    node %s ready %t\n", node.Name, m.IsNodeReady(node))
+		fmt.Fprintf(&b, "      labels: %s\n", formatLabels(node.Labels))
+		fmt.Fprintf(&b, "      taints: %s\n", formatTaints(node.Spec.Taints))
+	}
+	return b.String()
+}
+
+func hasTaint(taints []corev1.Taint, expected corev1.Taint) bool {
+	for _, taint := range taints {
+		if taint.MatchTaint(&expected) && taint.Value == expected.Value {
+			return true
+		}
+	}
+	return false
+}
+
+func formatLabels(labels map[string]string) string {
+	formatted := make([]string, 0, len(labels))
+	for key, value := range labels {
+		formatted = append(formatted, key+"="+value)
+	}
+	sort.Strings(formatted)
+	return "[" + strings.Join(formatted, ",") + "]"
+}
+
+func formatTaints(taints []corev1.Taint) string {
+	formatted := make([]string, 0, len(taints))
+	for _, taint := range taints {
+		formatted = append(formatted, taint.ToString())
+	}
+	return "[" + strings.Join(formatted, ",") + "]"
+}
@@ -17,50 +17,122 @@ limitations under the License.
 package main
 
 import (
+	"context"
 	"flag"
 	"os"
+	"time"
 
 	// Import all Kubernetes client auth plugins (e.g. Azure, GCP, OIDC, etc.)
 	// to ensure that exec-entrypoint and run can make use of them.
-	machinev1 "github.com/openshift/api/machine/v1beta1"
-	"k8s.io/apimachinery/pkg/runtime"
-	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
-	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/discovery"
 	_ "k8s.io/client-go/plugin/pkg/client/auth/gcp"
 	ctrl "sigs.k8s.io/controller-runtime"
-	"sigs.k8s.io/controller-runtime/pkg/cache"
-	"sigs.k8s.io/controller-runtime/pkg/healthz"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
-	metricsserver "sigs.k8s.io/controller-runtime/pkg/metrics/server"
-	"sigs.k8s.io/controller-runtime/pkg/webhook"
 
 	"github.com/openshift/managed-node-metadata-operator/controllers"
+	"github.com/openshift/managed-node-metadata-operator/pkg/audit"
+	"github.com/openshift/managed-node-metadata-operator/pkg/history"
+	"github.com/openshift/managed-node-metadata-operator/pkg/machine"
+	"github.com/openshift/managed-node-metadata-operator/pkg/metrics"
+	"github.com/openshift/managed-node-metadata-operator/pkg/operator"
+	"github.com/openshift/managed-node-metadata-operator/pkg/tracing"
 	// +kubebuilder:scaffold:imports
 )
 
 var (
-	scheme   = runtime.NewScheme()
+	scheme   = operator.NewScheme()
 	setupLog = ctrl.Log.WithName("setup")
 )
 
-func init() {
-	utilruntime.Must(clientgoscheme.AddToScheme(scheme))
-
-	utilruntime.Must(machinev1.AddToScheme(scheme))
-	//+kubebuilder:scaffold:scheme
-}
-
 func main() {
 	var metricsAddr string
+	var secureMetrics bool
+	var metricsCertDir, metricsCertName, metricsKeyName string
 	var enableLeaderElection bool
 	var probeAddr string
-	namespace := "openshift-machine-api"
-
-	flag.StringVar(&metricsAddr, "metrics-bind-address", ":8080", "The address the metric endpoint binds to.")
+	var enableControlPlaneMachineSet bool
+	var machineProvider string
+	var clusterAPIVersion string
+	var namespace string
+	var syncAutoscalerAnnotations bool
+	var driftEvaluationInterval time.Duration
+	var failureMetricNodeLabel bool
+	var tracingOpts tracing.Options
+	var auditOpts audit.Options
+	var livenessStallThreshold time.Duration
+	var historyMaxGenerations int
+	var historyNamespace, historyName string
+	var uninstall bool
+	var uninstallPolicy, uninstallReport string
+
+	flag.StringVar(&metricsAddr, "metrics-bind-address", ":8443", "The address the metric endpoint binds to.")
+	flag.BoolVar(&secureMetrics, "metrics-secure", true,
+		"Serve the metrics over HTTPS to authenticated and authorized clients only. "+
+			"Set to false to serve them over HTTP without authentication.")
+	flag.StringVar(&metricsCertDir, "metrics-cert-dir", "",
+		"The directory of the metrics server certificate. A self-signed certificate is generated if it has none.")
+	flag.StringVar(&metricsCertName, "metrics-cert-name", "tls.crt", "The name of the metrics server certificate file.")
+	flag.StringVar(&metricsKeyName, "metrics-cert-key", "tls.key", "The name of the metrics server key file.")
 	flag.StringVar(&probeAddr, "health-probe-bind-address", ":8081", "The address the probe endpoint binds to.")
 	flag.BoolVar(&enableLeaderElection, "leader-elect", false,
 		"Enable leader election for controller manager. "+
 			"Enabling this will ensure there is only one active controller manager.")
+	flag.BoolVar(&enableControlPlaneMachineSet, "enable-control-plane-machineset", false,
+		"Sync labels and taints of the ControlPlaneMachineSet to the control plane nodes.")
+	flag.StringVar(&machineProvider, "machine-provider", machine.ProviderAuto,
+		"The machine management API to sync from: auto, machine-api or cluster-api. "+
+			"With auto, the API is detected from the APIs served by the cluster.")
+	flag.StringVar(&clusterAPIVersion, "cluster-api-version", "v1beta1",
+		"The cluster.x-k8s.io API version to use when the cluster-api provider is selected explicitly.")
+	flag.StringVar(&namespace, "machine-namespace", "",
+		"The namespace of the machine sets. Defaults to openshift-machine-api for the Machine API, "+
+			"required for the Cluster API.")
+	flag.BoolVar(&syncAutoscalerAnnotations, "sync-autoscaler-annotations", true,
+		"Keep the cluster-autoscaler scale-from-zero labels and taints annotations of the machine sets "+
+			"in step with their template labels and taints.")
+	flag.DurationVar(&driftEvaluationInterval, "drift-evaluation-interval", 5*time.Minute,
+		"How often to count the nodes whose labels or taints are out of sync with their machine set. "+
+			"Set to 0 to disable the evaluation.")
+	flag.BoolVar(&failureMetricNodeLabel, "failure-metric-node-label", true,
+		"Record reconciliation failures per node. Disable on very large clusters to bound the number of series.")
+	flag.StringVar(&tracingOpts.Exporter, "tracing-exporter", tracing.ExporterNone,
+		"Where to export the OpenTelemetry spans of the reconciles: none, otlp, stdout or file.")
+	flag.StringVar(&tracingOpts.Endpoint, "tracing-endpoint", "",
+		"The URL of the OTLP/HTTP collector, e.g. http://collector:4318. "+
+			"Defaults to the OTEL_EXPORTER_OTLP_ENDPOINT environment variable.")
+	flag.StringVar(&tracingOpts.File, "tracing-file", "",
+		"The file the spans are appended to with the file exporter.")
+	flag.DurationVar(&livenessStallThreshold, "liveness-stall-threshold", 10*time.Minute,
+		"How long a controller may go without processing its queued items, or keep processing the same item, "+
+			"before the liveness check fails.")
+	flag.StringVar(&auditOpts.Sink, "audit-sink", audit.SinkNone,
+		"Where to write a record of every label and taint change: none, stdout, file or configmap.")
+	flag.StringVar(&auditOpts.File, "audit-file", "",
+		"The file the audit records are appended to with the file sink.")
+	flag.IntVar(&auditOpts.MaxFileSizeMB, "audit-file-max-size", 100,
+		"The size in megabytes of the audit file before it's rotated.")
+	flag.IntVar(&auditOpts.MaxFileBackups, "audit-file-max-backups", 5,
+		"The number of rotated audit files to keep.")
+	flag.StringVar(&auditOpts.ConfigMapNamespace, "audit-configmap-namespace", "openshift-managed-node-metadata-operator",
+		"The namespace of the ConfigMap of the configmap sink.")
+	flag.StringVar(&auditOpts.ConfigMapName, "audit-configmap-name", "managed-node-metadata-operator-audit",
+		"The name of the ConfigMap of the configmap sink.")
+	flag.IntVar(&auditOpts.MaxConfigMapRecords, "audit-configmap-max-records", 500,
+		"The number of audit records kept in the ConfigMap, older records are dropped.")
+	flag.IntVar(&historyMaxGenerations, "history-max-generations", 10,
+		"The number of templates kept per MachineSet in the history ConfigMap, 0 disables the history.")
+	flag.StringVar(&historyNamespace, "history-configmap-namespace", history.DefaultNamespace,
+		"The namespace of the history ConfigMap.")
+	flag.StringVar(&historyName, "history-configmap-name", history.DefaultName,
+		"The name of the history ConfigMap.")
+	flag.BoolVar(&uninstall, "uninstall", false,
+		"Remove what the operator applied to the nodes and the machine sets and exit, instead of running the controllers. "+
+			"The operator must be stopped first.")
+	flag.StringVar(&uninstallPolicy, "uninstall-policy", controllers.CleanupPolicyKeep,
+		"What the uninstall does with the labels and taints the operator applied: keep or remove.")
+	flag.StringVar(&uninstallReport, "uninstall-report", "",
+		"The file the uninstall writes its JSON report to. The report is written to the standard output if empty.")
 	opts := zap.Options{
 		Development: true,
 	}
@@ -68,47 +140,84 @@ func main() {
 	flag.Parse()
 
 	ctrl.SetLogger(zap.New(zap.UseFlagOptions(&opts)))
+	metrics.SetFailureNodeLabel(failureMetricNodeLabel)
 
-	mgr, err := ctrl.NewManager(ctrl.GetConfigOrDie(), ctrl.Options{
-		Scheme: scheme,
-		Metrics: metricsserver.Options{
-			BindAddress: metricsAddr,
-		},
-		WebhookServer:          webhook.NewServer(webhook.Options{Port: 9443}),
-		HealthProbeBindAddress: probeAddr,
-		LeaderElection:         enableLeaderElection,
-		LeaderElectionID:       "cdb62f5e.managed.openshift.com",
-		Cache: cache.Options{
-			DefaultNamespaces: map[string]cache.Config{
-				namespace: {},
-			},
-		}})
+	shutdownTracing, err := tracing.Setup(context.Background(), tracingOpts)
 	if err != nil {
-		setupLog.Error(err, "unable to start manager")
+		setupLog.Error(err, "unable to set up tracing")
 		os.Exit(1)
 	}
 
-	if err = (&controllers.MachinesetReconciler{
-		Client:   mgr.GetClient(),
-		Scheme:   mgr.GetScheme(),
-		Recorder: mgr.GetEventRecorderFor("machineset_controller"),
-	}).SetupWithManager(mgr); err != nil {
-		setupLog.Error(err, "unable to create controller", "controller", "Machineset")
+	cfg := ctrl.GetConfigOrDie()
+
+	dc, err := discovery.NewDiscoveryClientForConfig(cfg)
+	if err != nil {
+		setupLog.Error(err, "unable to create discovery client")
 		os.Exit(1)
 	}
-	//+kubebuilder:scaffold:builder
-
-	if err := mgr.AddHealthzCheck("healthz", healthz.Ping); err != nil {
-		setupLog.Error(err, "unable to set up health check")
+	providers, namespace, err := machine.Providers(dc, machineProvider, clusterAPIVersion, namespace)
+	if err != nil {
+		setupLog.Error(err, "unable to select machine provider")
 		os.Exit(1)
 	}
-	if err := mgr.AddReadyzCheck("readyz", healthz.Ping); err != nil {
-		setupLog.Error(err, "unable to set up ready check")
+	setupLog.Info("selected machine provider", "provider", providers[0].Name(), "namespace", namespace)
+
+	if uninstall {
+		c, err := client.New(cfg, client.Options{Scheme: scheme})
+		if err != nil {
+			setupLog.Error(err, "unable to create client")
+			os.Exit(1)
+		}
+		setupLog.Info("removing what the operator applied", "policy", uninstallPolicy)
+		report, err := controllers.Cleanup(ctrl.SetupSignalHandler(), c, providers, namespace, uninstallPolicy, false)
+		if report != nil {
+			if err := controllers.WriteCleanupReport(report, uninstallReport); err != nil {
+				setupLog.Error(err, "unable to write the uninstall report")
+				os.Exit(1)
+			}
+		}
+		if err != nil {
+			setupLog.Error(err, "uninstall failed")
+			os.Exit(1)
+		}
+		setupLog.Info("uninstall complete", "objects", len(report.Actions))
+		return
+	}
+
+	mgr, err := operator.NewManager(cfg, scheme, operator.Options{
+		MetricsAddr:     metricsAddr,
+		SecureMetrics:   secureMetrics,
+		MetricsCertDir:  metricsCertDir,
+		MetricsCertName: metricsCertName,
+		MetricsKeyName:  metricsKeyName,
+		ProbeAddr:       probeAddr,
+		LeaderElection:  enableLeaderElection,
+
+		Providers:                    providers,
+		Namespace:                    namespace,
+		EnableControlPlaneMachineSet: enableControlPlaneMachineSet,
+		SyncAutoscalerAnnotations:    syncAutoscalerAnnotations,
+		DriftEvaluationInterval:      driftEvaluationInterval,
+		TraceClient:                  tracingOpts.Exporter != tracing.ExporterNone,
+		Audit:                        auditOpts,
+		LivenessStallThreshold:       livenessStallThreshold,
+		HistoryMaxGenerations:        historyMaxGenerations,
+		HistoryNamespace:             historyNamespace,
+		HistoryName:                  historyName,
+	})
+	if err != nil {
+		setupLog.Error(err, "unable to set up manager")
 		os.Exit(1)
 	}
 
 	setupLog.Info("starting manager")
-	if err := mgr.Start(ctrl.SetupSignalHandler()); err != nil {
+	err = mgr.Start(ctrl.SetupSignalHandler())
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if shutdownErr := shutdownTracing(ctx); shutdownErr != nil {
+		setupLog.Error(shutdownErr, "failed to flush the spans")
+	}
+	if err != nil {
 		setupLog.Error(err, "problem running manager")
 		os.Exit(1)
 	}
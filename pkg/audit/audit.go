@@ -0,0 +1,55 @@
+// Package audit writes a structured record of every label and taint the operator adds, changes or removes on
+// machines and nodes, so a change can be traced back to the machine set that made it.
+package audit
+
+import (
+	"context"
+	"time"
+)
+
+const (
+	// ReasonSetByMachineSet means the key was added or changed to match the machine set template
+	ReasonSetByMachineSet = "SetByMachineSet"
+	// ReasonRemovedFromMachineSet means the key was removed because it isn't in the machine set template anymore
+	ReasonRemovedFromMachineSet = "RemovedFromMachineSet"
+)
+
+// Record is a change of a single label or taint
+type Record struct {
+	Timestamp  time.Time `json:"timestamp"`
+	MachineSet string    `json:"machineSet"`
+	Generation int64     `json:"generation"`
+	Machine    string    `json:"machine,omitempty"`
+	Node       string    `json:"node,omitempty"`
+	// Kind is the kind of the changed object, Machine or Node
+	Kind string `json:"kind"`
+	// Metadata is label or taint
+	Metadata string `json:"metadata"`
+	// Key is the label key, or the taint key and effect as key:effect
+	Key string `json:"key"`
+	// Change is added, changed or removed
+	Change string `json:"change"`
+	// OldValue is nil when the key is added
+	OldValue *string `json:"oldValue"`
+	// NewValue is nil when the key is removed
+	NewValue *string `json:"newValue"`
+	Reason   string  `json:"reason"`
+}
+
+// Sink stores the records. Writes must be safe for concurrent use.
+type Sink interface {
+	Write(ctx context.Context, records []Record) error
+}
+
+type recordKey struct{}
+
+// WithRecord returns a context carrying the machine set, machine and node the records written with it are about
+func WithRecord(ctx context.Context, record Record) context.Context {
+	return context.WithValue(ctx, recordKey{}, record)
+}
+
+// FromContext returns the record carried by the context, or an empty record
+func FromContext(ctx context.Context) Record {
+	record, _ := ctx.Value(recordKey{}).(Record)
+	return record
+}
@@ -0,0 +1,171 @@
+package audit
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+
+	"gopkg.in/natefinch/lumberjack.v2"
+	corev1 "k8s.io/api/core/v1"
+	k8serr "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/util/retry"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// SinkNone disables the audit
+	SinkNone = "none"
+	// SinkStdout writes the records to stdout
+	SinkStdout = "stdout"
+	// SinkFile writes the records to a file rotated by size
+	SinkFile = "file"
+	// SinkConfigMap keeps the last records in a ConfigMap
+	SinkConfigMap = "configmap"
+
+	// ConfigMapKey is the key of the ConfigMap data holding the records, as JSON lines
+	ConfigMapKey = "audit.jsonl"
+)
+
+// Options configures the sink of the records
+type Options struct {
+	// Sink is one of none, stdout, file or configmap
+	Sink string
+	// File is the path of the file sink
+	File string
+	// MaxFileSizeMB is the size of the file before it's rotated
+	MaxFileSizeMB int
+	// MaxFileBackups is the number of rotated files kept
+	MaxFileBackups int
+	// ConfigMapNamespace and ConfigMapName identify the ConfigMap of the configmap sink
+	ConfigMapNamespace string
+	ConfigMapName      string
+	// MaxConfigMapRecords is the number of records kept in the ConfigMap, older records are dropped
+	MaxConfigMapRecords int
+}
+
+// NewSink returns the sink selected by the options, or nil if the audit is disabled. The client is only used
+// by the configmap sink, it must not read through a cache restricted to other namespaces.
+func NewSink(opts Options, c client.Client) (Sink, error) {
+	switch opts.Sink {
+	case "", SinkNone:
+		return nil, nil
+	case SinkStdout:
+		return NewWriterSink(os.Stdout), nil
+	case SinkFile:
+		if opts.File == "" {
+			return nil, fmt.Errorf("the file sink needs a file path")
+		}
+		return NewWriterSink(&lumberjack.Logger{
+			Filename:   opts.File,
+			MaxSize:    opts.MaxFileSizeMB,
+			MaxBackups: opts.MaxFileBackups,
+		}), nil
+	case SinkConfigMap:
+		if opts.ConfigMapNamespace == "" || opts.ConfigMapName == "" {
+			return nil, fmt.Errorf("the configmap sink needs a ConfigMap namespace and name")
+		}
+		return &ConfigMapSink{
+			Client:     c,
+			Namespace:  opts.ConfigMapNamespace,
+			Name:       opts.ConfigMapName,
+			MaxRecords: opts.MaxConfigMapRecords,
+		}, nil
+	}
+	return nil, fmt.Errorf("unknown audit sink %q", opts.Sink)
+}
+
+// WriterSink appends the records to a writer as JSON lines
+type WriterSink struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+// NewWriterSink returns a sink appending the records to the writer
+func NewWriterSink(w io.Writer) *WriterSink {
+	return &WriterSink{w: w}
+}
+
+func (s *WriterSink) Write(_ context.Context, records []Record) error {
+	lines, err := marshalLines(records)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, err = s.w.Write(lines)
+	return err
+}
+
+// ConfigMapSink keeps the last records in a ConfigMap, as JSON lines under the ConfigMapKey key. The ConfigMap
+// is created if it doesn't exist.
+type ConfigMapSink struct {
+	Client    client.Client
+	Namespace string
+	Name      string
+	// MaxRecords bounds the number of records kept, so the ConfigMap stays well below the size limit of objects
+	MaxRecords int
+
+	mu sync.Mutex
+}
+
+func (s *ConfigMapSink) Write(ctx context.Context, records []Record) error {
+	lines, err := marshalLines(records)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		cm := &corev1.ConfigMap{}
+		err := s.Client.Get(ctx, client.ObjectKey{Namespace: s.Namespace, Name: s.Name}, cm)
+		if k8serr.IsNotFound(err) {
+			cm = &corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{Namespace: s.Namespace, Name: s.Name},
+				Data:       map[string]string{ConfigMapKey: s.trim(string(lines))},
+			}
+			return s.Client.Create(ctx, cm)
+		}
+		if err != nil {
+			return err
+		}
+		if cm.Data == nil {
+			cm.Data = map[string]string{}
+		}
+		cm.Data[ConfigMapKey] = s.trim(cm.Data[ConfigMapKey] + string(lines))
+		return s.Client.Update(ctx, cm)
+	})
+}
+
+// trim drops the oldest records beyond MaxRecords
+func (s *ConfigMapSink) trim(data string) string {
+	if s.MaxRecords <= 0 {
+		return data
+	}
+	lines := strings.SplitAfter(data, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	if len(lines) <= s.MaxRecords {
+		return data
+	}
+	return strings.Join(lines[len(lines)-s.MaxRecords:], "")
+}
+
+// marshalLines returns the records as JSON lines
+func marshalLines(records []Record) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	encoder := json.NewEncoder(buf)
+	for _, record := range records {
+		if err := encoder.Encode(record); err != nil {
+			return nil, err
+		}
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,118 @@
+package audit
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+var _ = Describe("Audit sinks", func() {
+	s := runtime.NewScheme()
+	Expect(corev1.AddToScheme(s)).To(Succeed())
+
+	newRecord := func(key string) Record {
+		value := "bar"
+		return Record{MachineSet: "test-machineset", Node: "test-node", Kind: "Node", Metadata: "label",
+			Key: key, Change: "added", NewValue: &value, Reason: ReasonSetByMachineSet}
+	}
+
+	decode := func(data string) []Record {
+		var records []Record
+		for _, line := range strings.Split(strings.TrimSuffix(data, "\n"), "\n") {
+			record := Record{}
+			Expect(json.Unmarshal([]byte(line), &record)).To(Succeed())
+			records = append(records, record)
+		}
+		return records
+	}
+
+	Describe("NewSink", func() {
+		It("should return no sink when the audit is disabled", func() {
+			sink, err := NewSink(Options{Sink: SinkNone}, nil)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(sink).To(BeNil())
+		})
+
+		It("should fail for an unknown sink", func() {
+			_, err := NewSink(Options{Sink: "syslog"}, nil)
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("should fail for the file sink without a file", func() {
+			_, err := NewSink(Options{Sink: SinkFile}, nil)
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("should append the records to the file", func() {
+			file := filepath.Join(GinkgoT().TempDir(), "audit.jsonl")
+			sink, err := NewSink(Options{Sink: SinkFile, File: file, MaxFileSizeMB: 1}, nil)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(sink.Write(context.TODO(), []Record{newRecord("foo")})).To(Succeed())
+			Expect(sink.Write(context.TODO(), []Record{newRecord("baz")})).To(Succeed())
+
+			content, err := os.ReadFile(file)
+			Expect(err).NotTo(HaveOccurred())
+			records := decode(string(content))
+			Expect(records).To(HaveLen(2))
+			Expect(records[1].Key).To(Equal("baz"))
+		})
+	})
+
+	Describe("WriterSink", func() {
+		It("should write a JSON line per record", func() {
+			buf := &bytes.Buffer{}
+			sink := NewWriterSink(buf)
+			Expect(sink.Write(context.TODO(), []Record{newRecord("foo"), newRecord("baz")})).To(Succeed())
+
+			records := decode(buf.String())
+			Expect(records).To(HaveLen(2))
+			Expect(records[0].Key).To(Equal("foo"))
+			Expect(*records[0].NewValue).To(Equal("bar"))
+			Expect(records[0].OldValue).To(BeNil())
+		})
+	})
+
+	Describe("ConfigMapSink", func() {
+		var (
+			c    client.Client
+			sink *ConfigMapSink
+		)
+
+		BeforeEach(func() {
+			c = fake.NewClientBuilder().WithScheme(s).Build()
+			sink = &ConfigMapSink{Client: c, Namespace: "test", Name: "audit", MaxRecords: 2}
+		})
+
+		getRecords := func() []Record {
+			cm := &corev1.ConfigMap{}
+			Expect(c.Get(context.TODO(), client.ObjectKey{Namespace: "test", Name: "audit"}, cm)).To(Succeed())
+			return decode(cm.Data[ConfigMapKey])
+		}
+
+		It("should create the ConfigMap", func() {
+			Expect(sink.Write(context.TODO(), []Record{newRecord("foo")})).To(Succeed())
+			Expect(getRecords()).To(HaveLen(1))
+		})
+
+		It("should only keep the last records", func() {
+			Expect(sink.Write(context.TODO(), []Record{newRecord("a"), newRecord("b")})).To(Succeed())
+			Expect(sink.Write(context.TODO(), []Record{newRecord("c")})).To(Succeed())
+
+			records := getRecords()
+			Expect(records).To(HaveLen(2))
+			Expect(records[0].Key).To(Equal("b"))
+			Expect(records[1].Key).To(Equal("c"))
+		})
+	})
+})
@@ -0,0 +1,162 @@
+// Package health provides the readiness and liveness checks of the operator
+package health
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"k8s.io/client-go/discovery"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/healthz"
+)
+
+// Names of the workqueue metrics of controller-runtime read by the liveness check
+const (
+	workqueueDepthMetric          = "workqueue_depth"
+	workqueueWorkDurationMetric   = "workqueue_work_duration_seconds"
+	workqueueLongestRunningMetric = "workqueue_longest_running_processor_seconds"
+)
+
+// CacheSynced returns a checker failing until the informers of the objects have synced
+func CacheSynced(informers cache.Informers, objs ...client.Object) healthz.Checker {
+	return func(req *http.Request) error {
+		for _, obj := range objs {
+			informer, err := informers.GetInformer(req.Context(), obj, cache.BlockUntilSynced(false))
+			if err != nil {
+				return fmt.Errorf("failed to get the informer of %s: %w", kindOf(obj), err)
+			}
+			if !informer.HasSynced() {
+				return fmt.Errorf("the cache of %s hasn't synced yet", kindOf(obj))
+			}
+		}
+		return nil
+	}
+}
+
+// APIServer returns a checker failing when the version of the API server can't be read
+func APIServer(c discovery.ServerVersionInterface) healthz.Checker {
+	return func(_ *http.Request) error {
+		if _, err := c.ServerVersion(); err != nil {
+			return fmt.Errorf("failed to reach the API server: %w", err)
+		}
+		return nil
+	}
+}
+
+// WorkqueueProgress checks from the workqueue metrics that the controllers keep processing their queues.
+// A controller is stalled when an item has been processed for longer than the threshold, or when items
+// are queued but none has been processed for longer than the threshold. Controllers without metrics,
+// e.g. because they aren't started on a replica that isn't the leader, are ignored.
+type WorkqueueProgress struct {
+	// Gatherer exposes the workqueue metrics, usually the controller-runtime metrics registry
+	Gatherer prometheus.Gatherer
+	// Controllers are the names of the checked controllers
+	Controllers []string
+	// StallThreshold is how long a controller may go without progress
+	StallThreshold time.Duration
+
+	mu       sync.Mutex
+	progress map[string]queueProgress
+	now      func() time.Time
+}
+
+// queueStats are the workqueue metrics of a controller
+type queueStats struct {
+	depth          float64
+	processed      uint64
+	longestRunning float64
+}
+
+// queueProgress is the number of items processed by a controller and when it last changed
+type queueProgress struct {
+	processed uint64
+	at        time.Time
+}
+
+// Check is the healthz.Checker of the workqueue progress
+func (w *WorkqueueProgress) Check(_ *http.Request) error {
+	stats, err := w.gather()
+	if err != nil {
+		return err
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.progress == nil {
+		w.progress = map[string]queueProgress{}
+	}
+	now := time.Now()
+	if w.now != nil {
+		now = w.now()
+	}
+
+	for _, controller := range w.Controllers {
+		s, ok := stats[controller]
+		if !ok {
+			continue
+		}
+		if longestRunning := time.Duration(s.longestRunning * float64(time.Second)); longestRunning > w.StallThreshold {
+			return fmt.Errorf("controller %s has been processing an item for %s", controller, longestRunning.Round(time.Second))
+		}
+		previous, seen := w.progress[controller]
+		if !seen || s.processed != previous.processed || s.depth == 0 {
+			w.progress[controller] = queueProgress{processed: s.processed, at: now}
+			continue
+		}
+		if stalled := now.Sub(previous.at); stalled > w.StallThreshold {
+			return fmt.Errorf("controller %s hasn't processed any of its %v queued items for %s", controller, s.depth, stalled.Round(time.Second))
+		}
+	}
+	return nil
+}
+
+// gather returns the workqueue metrics per controller name
+func (w *WorkqueueProgress) gather() (map[string]*queueStats, error) {
+	families, err := w.Gatherer.Gather()
+	if err != nil {
+		return nil, fmt.Errorf("failed to gather the workqueue metrics: %w", err)
+	}
+	stats := map[string]*queueStats{}
+	for _, family := range families {
+		for _, metric := range family.GetMetric() {
+			name := ""
+			for _, label := range metric.GetLabel() {
+				if label.GetName() == "name" {
+					name = label.GetValue()
+				}
+			}
+			if name == "" {
+				continue
+			}
+			s, ok := stats[name]
+			if !ok {
+				s = &queueStats{}
+			}
+			switch family.GetName() {
+			case workqueueDepthMetric:
+				s.depth += metric.GetGauge().GetValue()
+			case workqueueWorkDurationMetric:
+				s.processed = metric.GetHistogram().GetSampleCount()
+			case workqueueLongestRunningMetric:
+				s.longestRunning = metric.GetGauge().GetValue()
+			default:
+				continue
+			}
+			stats[name] = s
+		}
+	}
+	return stats, nil
+}
+
+// kindOf returns the kind of the object for the check errors
+func kindOf(obj client.Object) string {
+	if kind := obj.GetObjectKind().GroupVersionKind().Kind; kind != "" {
+		return kind
+	}
+	return reflect.TypeOf(obj).Elem().Name()
+}
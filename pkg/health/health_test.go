@@ -0,0 +1,116 @@
+package health
+
+import (
+	"fmt"
+	"net/http/httptest"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/prometheus/client_golang/prometheus"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	fakediscovery "k8s.io/client-go/discovery/fake"
+	clienttesting "k8s.io/client-go/testing"
+	toolscache "k8s.io/client-go/tools/cache"
+	"sigs.k8s.io/controller-runtime/pkg/cache/informertest"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllertest"
+)
+
+var _ = Describe("Health checks", func() {
+	req := httptest.NewRequest("GET", "/readyz", nil)
+
+	Describe("CacheSynced", func() {
+		It("should fail until the informers have synced", func() {
+			s := runtime.NewScheme()
+			Expect(corev1.AddToScheme(s)).To(Succeed())
+			informer := controllertest.NewFakeInformer()
+			informers := &informertest.FakeInformers{
+				Scheme: s,
+				InformersByGVK: map[schema.GroupVersionKind]toolscache.SharedIndexInformer{
+					corev1.SchemeGroupVersion.WithKind("Node"): informer,
+				},
+			}
+			check := CacheSynced(informers, &corev1.Node{})
+
+			Expect(check(req)).To(MatchError(ContainSubstring("Node")))
+			informer.Synced()
+			Expect(check(req)).To(Succeed())
+		})
+	})
+
+	Describe("APIServer", func() {
+		It("should fail when the API server can't be reached", func() {
+			fake := &fakediscovery.FakeDiscovery{Fake: &clienttesting.Fake{}}
+			check := APIServer(fake)
+			Expect(check(req)).To(Succeed())
+
+			fake.PrependReactor("get", "version", func(clienttesting.Action) (bool, runtime.Object, error) {
+				return true, nil, fmt.Errorf("connection refused")
+			})
+			Expect(check(req)).To(HaveOccurred())
+		})
+	})
+
+	Describe("WorkqueueProgress", func() {
+		var (
+			depth          *prometheus.GaugeVec
+			workDuration   *prometheus.HistogramVec
+			longestRunning *prometheus.GaugeVec
+			now            time.Time
+			progress       *WorkqueueProgress
+		)
+
+		BeforeEach(func() {
+			registry := prometheus.NewRegistry()
+			depth = prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: workqueueDepthMetric}, []string{"name", "controller"})
+			workDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{Name: workqueueWorkDurationMetric}, []string{"name", "controller"})
+			longestRunning = prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: workqueueLongestRunningMetric}, []string{"name", "controller"})
+			registry.MustRegister(depth, workDuration, longestRunning)
+
+			now = time.Now()
+			progress = &WorkqueueProgress{
+				Gatherer:       registry,
+				Controllers:    []string{"machineset_controller"},
+				StallThreshold: time.Minute,
+				now:            func() time.Time { return now },
+			}
+			depth.WithLabelValues("machineset_controller", "machineset_controller").Set(0)
+			longestRunning.WithLabelValues("machineset_controller", "machineset_controller").Set(0)
+		})
+
+		It("should succeed for an idle controller", func() {
+			Expect(progress.Check(req)).To(Succeed())
+			now = now.Add(time.Hour)
+			Expect(progress.Check(req)).To(Succeed())
+		})
+
+		It("should succeed while queued items are processed", func() {
+			depth.WithLabelValues("machineset_controller", "machineset_controller").Set(3)
+			Expect(progress.Check(req)).To(Succeed())
+			now = now.Add(2 * time.Minute)
+			workDuration.WithLabelValues("machineset_controller", "machineset_controller").Observe(0.1)
+			Expect(progress.Check(req)).To(Succeed())
+		})
+
+		It("should fail when queued items aren't processed", func() {
+			depth.WithLabelValues("machineset_controller", "machineset_controller").Set(3)
+			Expect(progress.Check(req)).To(Succeed())
+			now = now.Add(2 * time.Minute)
+			Expect(progress.Check(req)).To(MatchError(ContainSubstring("machineset_controller")))
+		})
+
+		It("should fail when an item is processed for too long", func() {
+			longestRunning.WithLabelValues("machineset_controller", "machineset_controller").Set(120)
+			Expect(progress.Check(req)).To(HaveOccurred())
+		})
+
+		It("should ignore controllers without metrics", func() {
+			progress.Controllers = []string{"controlplanemachineset_controller"}
+			longestRunning.WithLabelValues("machineset_controller", "machineset_controller").Set(120)
+			Expect(progress.Check(req)).To(Succeed())
+		})
+	})
+})
@@ -0,0 +1,156 @@
+// Package history keeps the labels and taints of the templates of the machine sets the operator applied, per
+// machine set generation, so a previous template can be restored.
+package history
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	k8serr "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/util/retry"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// DefaultNamespace is the namespace of the history ConfigMap, the namespace of the operator
+	DefaultNamespace = "openshift-managed-node-metadata-operator"
+	// DefaultName is the name of the history ConfigMap
+	DefaultName = "managed-node-metadata-operator-history"
+)
+
+// Snapshot is the labels and taints of the template of a machine set at a generation
+type Snapshot struct {
+	Generation int64             `json:"generation"`
+	Time       time.Time         `json:"time"`
+	Labels     map[string]string `json:"labels"`
+	Taints     []corev1.Taint    `json:"taints"`
+}
+
+// Key returns the key of the history of a machine set in the ConfigMap
+func Key(provider string, namespace string, name string) string {
+	return provider + "." + namespace + "." + name
+}
+
+// Store keeps the snapshots of each machine set in a ConfigMap, as a JSON list under the key of the machine set,
+// oldest first. The ConfigMap is created if it doesn't exist.
+type Store struct {
+	Client    client.Client
+	Namespace string
+	Name      string
+	// MaxGenerations bounds the number of snapshots kept per machine set, older snapshots are dropped
+	MaxGenerations int
+
+	mu sync.Mutex
+}
+
+// Record adds the snapshot to the history of the machine set of the key, unless the template is the one of its
+// last snapshot, like after a generation only changing the replicas
+func (s *Store) Record(ctx context.Context, key string, snapshot Snapshot) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		cm := &corev1.ConfigMap{}
+		err := s.Client.Get(ctx, client.ObjectKey{Namespace: s.Namespace, Name: s.Name}, cm)
+		create := k8serr.IsNotFound(err)
+		if err != nil && !create {
+			return err
+		}
+		if cm.Data == nil {
+			cm.Data = map[string]string{}
+		}
+		snapshots, err := decode(cm.Data[key])
+		if err != nil {
+			return fmt.Errorf("failed to decode the history of %s: %w", key, err)
+		}
+		if len(snapshots) > 0 && sameTemplate(snapshots[len(snapshots)-1], snapshot) {
+			return nil
+		}
+		snapshots = append(snapshots, snapshot)
+		if s.MaxGenerations > 0 && len(snapshots) > s.MaxGenerations {
+			snapshots = snapshots[len(snapshots)-s.MaxGenerations:]
+		}
+		data, err := json.Marshal(snapshots)
+		if err != nil {
+			return err
+		}
+		cm.Data[key] = string(data)
+
+		if create {
+			cm.ObjectMeta = metav1.ObjectMeta{Namespace: s.Namespace, Name: s.Name}
+			return s.Client.Create(ctx, cm)
+		}
+		return s.Client.Update(ctx, cm)
+	})
+}
+
+// Get returns the snapshots of the machine set of the key, oldest first
+func (s *Store) Get(ctx context.Context, key string) ([]Snapshot, error) {
+	cm := &corev1.ConfigMap{}
+	err := s.Client.Get(ctx, client.ObjectKey{Namespace: s.Namespace, Name: s.Name}, cm)
+	if k8serr.IsNotFound(err) {
+		return []Snapshot{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return decode(cm.Data[key])
+}
+
+// Delete drops the history of the machine set of the key, like once the machine set is deleted
+func (s *Store) Delete(ctx context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		cm := &corev1.ConfigMap{}
+		err := s.Client.Get(ctx, client.ObjectKey{Namespace: s.Namespace, Name: s.Name}, cm)
+		if k8serr.IsNotFound(err) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if _, ok := cm.Data[key]; !ok {
+			return nil
+		}
+		delete(cm.Data, key)
+		return s.Client.Update(ctx, cm)
+	})
+}
+
+// Find returns the snapshot holding the template of the generation: the last snapshot recorded at or before it, as
+// the generations that don't change the template aren't recorded. It returns nil if the generation is older than
+// the snapshots.
+func Find(snapshots []Snapshot, generation int64) *Snapshot {
+	var found *Snapshot
+	for i := range snapshots {
+		if snapshots[i].Generation <= generation {
+			found = &snapshots[i]
+		}
+	}
+	return found
+}
+
+func decode(data string) ([]Snapshot, error) {
+	snapshots := []Snapshot{}
+	if data == "" {
+		return snapshots, nil
+	}
+	if err := json.Unmarshal([]byte(data), &snapshots); err != nil {
+		return nil, err
+	}
+	return snapshots, nil
+}
+
+// sameTemplate tells if the snapshots have the same labels and taints, an empty and a nil template are the same
+func sameTemplate(a Snapshot, b Snapshot) bool {
+	return (len(a.Labels) == 0 && len(b.Labels) == 0 || reflect.DeepEqual(a.Labels, b.Labels)) &&
+		(len(a.Taints) == 0 && len(b.Taints) == 0 || reflect.DeepEqual(a.Taints, b.Taints))
+}
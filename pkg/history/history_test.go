@@ -0,0 +1,111 @@
+package history
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+var _ = Describe("Store", func() {
+	var store *Store
+
+	s := runtime.NewScheme()
+	Expect(corev1.AddToScheme(s)).To(Succeed())
+
+	key := Key("machineset", "openshift-machine-api", "infra-a")
+
+	snapshot := func(generation int64, team string) Snapshot {
+		return Snapshot{
+			Generation: generation,
+			Time:       time.Date(2026, 10, 18, 10, 0, int(generation), 0, time.UTC),
+			Labels:     map[string]string{"team": team},
+			Taints:     []corev1.Taint{{Key: "dedicated", Value: "infra", Effect: corev1.TaintEffectNoSchedule}},
+		}
+	}
+
+	BeforeEach(func() {
+		store = &Store{
+			Client:         fake.NewClientBuilder().WithScheme(s).Build(),
+			Namespace:      DefaultNamespace,
+			Name:           DefaultName,
+			MaxGenerations: 2,
+		}
+	})
+
+	It("should create the ConfigMap with the first snapshot", func() {
+		Expect(store.Record(context.TODO(), key, snapshot(1, "payments"))).To(Succeed())
+		cm := &corev1.ConfigMap{}
+		Expect(store.Client.Get(context.TODO(), client.ObjectKey{Namespace: DefaultNamespace, Name: DefaultName}, cm)).To(Succeed())
+		Expect(cm.Data).To(HaveKey("machineset.openshift-machine-api.infra-a"))
+
+		snapshots, err := store.Get(context.TODO(), key)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(snapshots).To(Equal([]Snapshot{snapshot(1, "payments")}))
+	})
+
+	It("should skip the generations that don't change the template", func() {
+		Expect(store.Record(context.TODO(), key, snapshot(1, "payments"))).To(Succeed())
+		Expect(store.Record(context.TODO(), key, snapshot(2, "payments"))).To(Succeed())
+		snapshots, err := store.Get(context.TODO(), key)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(snapshots).To(HaveLen(1))
+		Expect(Find(snapshots, 2).Labels).To(Equal(map[string]string{"team": "payments"}))
+	})
+
+	It("should find the template of the generations that weren't recorded", func() {
+		snapshots := []Snapshot{snapshot(2, "payments"), snapshot(5, "billing")}
+		Expect(Find(snapshots, 4).Generation).To(Equal(int64(2)))
+		Expect(Find(snapshots, 5).Generation).To(Equal(int64(5)))
+		Expect(Find(snapshots, 7).Generation).To(Equal(int64(5)))
+		Expect(Find(snapshots, 1)).To(BeNil())
+	})
+
+	It("should keep the last generations", func() {
+		for generation, team := range []string{"payments", "platform", "billing"} {
+			Expect(store.Record(context.TODO(), key, snapshot(int64(generation+1), team))).To(Succeed())
+		}
+		snapshots, err := store.Get(context.TODO(), key)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(snapshots).To(HaveLen(2))
+		Expect(snapshots[0].Generation).To(Equal(int64(2)))
+		Expect(Find(snapshots, 3).Labels).To(Equal(map[string]string{"team": "billing"}))
+		Expect(Find(snapshots, 1)).To(BeNil())
+	})
+
+	It("should keep the machinesets apart", func() {
+		Expect(store.Record(context.TODO(), key, snapshot(1, "payments"))).To(Succeed())
+		snapshots, err := store.Get(context.TODO(), Key("machineset", "openshift-machine-api", "infra-b"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(snapshots).To(BeEmpty())
+	})
+
+	It("should drop the history of a machineset", func() {
+		other := Key("machineset", "openshift-machine-api", "infra-b")
+		Expect(store.Record(context.TODO(), key, snapshot(1, "payments"))).To(Succeed())
+		Expect(store.Record(context.TODO(), other, snapshot(1, "platform"))).To(Succeed())
+
+		Expect(store.Delete(context.TODO(), key)).To(Succeed())
+		cm := &corev1.ConfigMap{}
+		Expect(store.Client.Get(context.TODO(), client.ObjectKey{Namespace: DefaultNamespace, Name: DefaultName}, cm)).To(Succeed())
+		Expect(cm.Data).NotTo(HaveKey(key))
+		Expect(cm.Data).To(HaveKey(other))
+		Expect(store.Delete(context.TODO(), key)).To(Succeed())
+	})
+
+	It("should delete nothing without the ConfigMap", func() {
+		Expect(store.Delete(context.TODO(), key)).To(Succeed())
+	})
+
+	It("should return an empty history without the ConfigMap", func() {
+		snapshots, err := store.Get(context.TODO(), key)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(snapshots).To(BeEmpty())
+	})
+})
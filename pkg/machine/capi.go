@@ -0,0 +1,212 @@
+package machine
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/openshift/managed-node-metadata-operator/pkg/reasons"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// ClusterAPIMachineSetKind is the kind of cluster.x-k8s.io MachineSets
+	ClusterAPIMachineSetKind = "MachineSet"
+	// ClusterAPIMachineDeploymentKind is the kind of cluster.x-k8s.io MachineDeployments
+	ClusterAPIMachineDeploymentKind = "MachineDeployment"
+
+	// taintPropagationOnInitialization is the propagation of the machine taints only applied when the node registers
+	taintPropagationOnInitialization = "OnInitialization"
+)
+
+// versionsWithoutTaints are the cluster.x-k8s.io API versions whose machine templates have no taints
+var versionsWithoutTaints = map[string]bool{
+	"v1alpha3": true,
+	"v1alpha4": true,
+	"v1beta1":  true,
+}
+
+// ClusterAPIProvider is the Provider for cluster.x-k8s.io MachineSets and MachineDeployments.
+// The objects are handled as unstructured, so any served version of the API can be used.
+//
+// Node labels are read from the machine template labels, without the selector labels and the
+// labels of the cluster.x-k8s.io domain, which describe the machine rather than the node.
+// Node taints are read from the machine template taints of the v1beta2 API and later, without the taints only
+// applied when the node registers. The machine templates of the older APIs have no taints, the bootstrap provider
+// registers the nodes with their taints, so the provider doesn't sync taints and the node taints are left alone.
+type ClusterAPIProvider struct {
+	// Version of the cluster.x-k8s.io API, e.g. v1beta1
+	Version string
+	// Kind of the machine set, either MachineSet or MachineDeployment
+	Kind string
+}
+
+func (p ClusterAPIProvider) groupVersionKind(kind string) schema.GroupVersionKind {
+	return schema.GroupVersionKind{Group: clusterAPIGroup, Version: p.Version, Kind: kind}
+}
+
+func (p ClusterAPIProvider) Name() string {
+	return "capi_" + strings.ToLower(p.Kind)
+}
+
+func (p ClusterAPIProvider) NewMachineSet() client.Object {
+	machineSet := &unstructured.Unstructured{}
+	machineSet.SetGroupVersionKind(p.groupVersionKind(p.Kind))
+	return machineSet
+}
+
+func (p ClusterAPIProvider) NewMachineSetList() client.ObjectList {
+	machineSets := &unstructured.UnstructuredList{}
+	machineSets.SetGroupVersionKind(p.groupVersionKind(p.Kind + "List"))
+	return machineSets
+}
+
+func (p ClusterAPIProvider) NewMachine() client.Object {
+	machine := &unstructured.Unstructured{}
+	machine.SetGroupVersionKind(p.groupVersionKind("Machine"))
+	return machine
+}
+
+// GetMachines returns the machines matching the selector of the machine set.
+// MachineSets controlled by a MachineDeployment have no machines of their own, they're handled through the MachineDeployment.
+func (p ClusterAPIProvider) GetMachines(ctx context.Context, c client.Client, machineSet client.Object) ([]client.Object, error) {
+	ms, ok := machineSet.(*unstructured.Unstructured)
+	if !ok {
+		return nil, fmt.Errorf("unexpected machine set type %T", machineSet)
+	}
+
+	if p.Kind == ClusterAPIMachineSetKind {
+		if owner := metav1.GetControllerOf(ms); owner != nil && owner.Kind == ClusterAPIMachineDeploymentKind {
+			return []client.Object{}, nil
+		}
+	}
+
+	labelSelector := &metav1.LabelSelector{}
+	rawSelector, _, err := unstructured.NestedMap(ms.Object, "spec", "selector")
+	if err != nil {
+		return nil, reasons.New(reasons.ReasonInvalidSelector, "failed to read %s %q label selector: %w", p.Kind, ms.GetName(), err)
+	}
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(rawSelector, labelSelector); err != nil {
+		return nil, reasons.New(reasons.ReasonInvalidSelector, "failed to read %s %q label selector: %w", p.Kind, ms.GetName(), err)
+	}
+	selector, err := metav1.LabelSelectorAsSelector(labelSelector)
+	if err != nil {
+		return nil, reasons.New(reasons.ReasonInvalidSelector, "failed to parse %s %q label selector: %w", p.Kind, ms.GetName(), err)
+	}
+	// If a machine set with a nil or empty selector creeps in, it should match nothing, not everything.
+	if selector.Empty() {
+		return []client.Object{}, nil
+	}
+
+	allMachines := &unstructured.UnstructuredList{}
+	allMachines.SetGroupVersionKind(p.groupVersionKind("MachineList"))
+	err = c.List(ctx, allMachines, client.InNamespace(ms.GetNamespace()), client.MatchingLabelsSelector{Selector: selector})
+	if err != nil {
+		return nil, err
+	}
+
+	machines := []client.Object{}
+	for idx := range allMachines.Items {
+		machine := &allMachines.Items[idx]
+		if machine.GetDeletionTimestamp() != nil {
+			continue
+		}
+		// Machines of a MachineDeployment are controlled by its MachineSets
+		if p.Kind == ClusterAPIMachineSetKind && metav1.GetControllerOf(machine) != nil && !metav1.IsControlledBy(machine, ms) {
+			continue
+		}
+		machines = append(machines, machine)
+	}
+
+	return machines, nil
+}
+
+func (p ClusterAPIProvider) GetNode(ctx context.Context, c client.Client, machine client.Object) (*corev1.Node, error) {
+	mm, ok := machine.(*unstructured.Unstructured)
+	if !ok {
+		return nil, fmt.Errorf("unexpected machine type %T", machine)
+	}
+	nodeName, _, err := unstructured.NestedString(mm.Object, "status", "nodeRef", "name")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read node reference of machine %s: %w", mm.GetName(), err)
+	}
+	if nodeName == "" {
+		return nil, nil
+	}
+
+	node := &corev1.Node{}
+	if err := c.Get(ctx, types.NamespacedName{Name: nodeName}, node); err != nil {
+		return nil, err
+	}
+	return node, nil
+}
+
+func (p ClusterAPIProvider) DesiredLabels(machineSet client.Object) map[string]string {
+	ms, ok := machineSet.(*unstructured.Unstructured)
+	if !ok {
+		return nil
+	}
+	templateLabels, found, err := unstructured.NestedStringMap(ms.Object, "spec", "template", "metadata", "labels")
+	if err != nil || !found {
+		return nil
+	}
+	selectorLabels, _, _ := unstructured.NestedStringMap(ms.Object, "spec", "selector", "matchLabels")
+
+	result := map[string]string{}
+	for k, v := range templateLabels {
+		if _, isSelectorLabel := selectorLabels[k]; isSelectorLabel || isClusterAPILabel(k) {
+			continue
+		}
+		result[k] = v
+	}
+	return result
+}
+
+// DesiredTaints returns the taints of the machine template, without the ones only applied when the node registers
+func (p ClusterAPIProvider) DesiredTaints(machineSet client.Object) []corev1.Taint {
+	ms, ok := machineSet.(*unstructured.Unstructured)
+	if !ok || !p.SyncsTaints() {
+		return nil
+	}
+	templateTaints, found, err := unstructured.NestedSlice(ms.Object, "spec", "template", "spec", "taints")
+	if err != nil || !found {
+		return nil
+	}
+
+	result := []corev1.Taint{}
+	for _, templateTaint := range templateTaints {
+		fields, ok := templateTaint.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		propagation, _, _ := unstructured.NestedString(fields, "propagation")
+		if propagation == taintPropagationOnInitialization {
+			continue
+		}
+		key, _, _ := unstructured.NestedString(fields, "key")
+		value, _, _ := unstructured.NestedString(fields, "value")
+		effect, _, _ := unstructured.NestedString(fields, "effect")
+		result = append(result, corev1.Taint{Key: key, Value: value, Effect: corev1.TaintEffect(effect)})
+	}
+	return result
+}
+
+// SyncsTaints tells if the machine templates of the API version have taints
+func (p ClusterAPIProvider) SyncsTaints() bool {
+	return !versionsWithoutTaints[p.Version]
+}
+
+// isClusterAPILabel returns true for labels in the cluster.x-k8s.io domain or its subdomains
+func isClusterAPILabel(key string) bool {
+	prefix, _, found := strings.Cut(key, "/")
+	if !found {
+		return false
+	}
+	return prefix == clusterAPIGroup || strings.HasSuffix(prefix, "."+clusterAPIGroup)
+}
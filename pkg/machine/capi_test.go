@@ -0,0 +1,188 @@
+package machine
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func newClusterAPIMachine(name string, labels map[string]interface{}, nodeName string) *unstructured.Unstructured {
+	machine := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "cluster.x-k8s.io/v1beta1",
+		"kind":       "Machine",
+		"metadata": map[string]interface{}{
+			"name":      name,
+			"namespace": "test-namespace",
+			"labels":    labels,
+		},
+	}}
+	if nodeName != "" {
+		machine.Object["status"] = map[string]interface{}{
+			"nodeRef": map[string]interface{}{"name": nodeName},
+		}
+	}
+	return machine
+}
+
+var _ = Describe("ClusterAPIProvider", func() {
+	var (
+		provider   ClusterAPIProvider
+		machineSet *unstructured.Unstructured
+		fakeClient client.Client
+		objects    []client.Object
+	)
+
+	BeforeEach(func() {
+		provider = ClusterAPIProvider{Version: "v1beta1", Kind: ClusterAPIMachineSetKind}
+		machineSet = &unstructured.Unstructured{Object: map[string]interface{}{
+			"apiVersion": "cluster.x-k8s.io/v1beta1",
+			"kind":       "MachineSet",
+			"metadata": map[string]interface{}{
+				"name":      "test-machineset",
+				"namespace": "test-namespace",
+				"uid":       "machineset-uid",
+			},
+			"spec": map[string]interface{}{
+				"selector": map[string]interface{}{
+					"matchLabels": map[string]interface{}{
+						"pool": "test",
+					},
+				},
+				"template": map[string]interface{}{
+					"metadata": map[string]interface{}{
+						"labels": map[string]interface{}{
+							"pool":                            "test",
+							"cluster.x-k8s.io/cluster-name":   "test-cluster",
+							"topology.cluster.x-k8s.io/owned": "",
+							"node-role.kubernetes.io/infra":   "",
+							"example.com/team":                "payments",
+						},
+					},
+					"spec": map[string]interface{}{
+						"clusterName": "test-cluster",
+					},
+				},
+			},
+		}}
+		objects = []client.Object{}
+	})
+
+	JustBeforeEach(func() {
+		scheme := runtime.NewScheme()
+		_ = corev1.AddToScheme(scheme)
+		fakeClient = fake.NewClientBuilder().WithScheme(scheme).WithObjects(objects...).Build()
+	})
+
+	Describe("DesiredLabels", func() {
+		It("should return the template labels without the selector and cluster.x-k8s.io labels", func() {
+			Expect(provider.DesiredLabels(machineSet)).To(Equal(map[string]string{
+				"node-role.kubernetes.io/infra": "",
+				"example.com/team":              "payments",
+			}))
+		})
+	})
+
+	Describe("DesiredTaints", func() {
+		It("should return no taints with v1beta1, the machine templates have none", func() {
+			Expect(provider.DesiredTaints(machineSet)).To(BeNil())
+			Expect(provider.SyncsTaints()).To(BeFalse())
+		})
+
+		Context("With v1beta2", func() {
+			BeforeEach(func() {
+				provider.Version = "v1beta2"
+				Expect(unstructured.SetNestedSlice(machineSet.Object, []interface{}{
+					map[string]interface{}{"key": "dedicated", "value": "payments", "effect": "NoSchedule", "propagation": "Always"},
+					map[string]interface{}{"key": "example.com/gpu", "effect": "NoExecute"},
+					map[string]interface{}{"key": "example.com/bootstrap", "effect": "NoSchedule", "propagation": "OnInitialization"},
+				}, "spec", "template", "spec", "taints")).To(Succeed())
+			})
+
+			It("should return the template taints that are always propagated", func() {
+				Expect(provider.SyncsTaints()).To(BeTrue())
+				Expect(provider.DesiredTaints(machineSet)).To(Equal([]corev1.Taint{
+					{Key: "dedicated", Value: "payments", Effect: corev1.TaintEffectNoSchedule},
+					{Key: "example.com/gpu", Effect: corev1.TaintEffectNoExecute},
+				}))
+			})
+		})
+	})
+
+	Describe("GetMachines", func() {
+		BeforeEach(func() {
+			controller := true
+			owned := newClusterAPIMachine("owned", map[string]interface{}{"pool": "test"}, "")
+			owned.SetOwnerReferences([]metav1.OwnerReference{
+				{APIVersion: "cluster.x-k8s.io/v1beta1", Kind: "MachineSet", Name: "other", UID: "other-uid", Controller: &controller},
+			})
+			objects = []client.Object{
+				newClusterAPIMachine("matching", map[string]interface{}{"pool": "test"}, "node-1"),
+				newClusterAPIMachine("not-matching", map[string]interface{}{"pool": "other"}, "node-2"),
+				owned,
+			}
+		})
+
+		It("should return the machines matching the selector", func() {
+			machines, err := provider.GetMachines(context.TODO(), fakeClient, machineSet)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(machines).To(HaveLen(1))
+			Expect(machines[0].GetName()).To(Equal("matching"))
+		})
+
+		Context("When the MachineSet is controlled by a MachineDeployment", func() {
+			BeforeEach(func() {
+				controller := true
+				machineSet.SetOwnerReferences([]metav1.OwnerReference{
+					{APIVersion: "cluster.x-k8s.io/v1beta1", Kind: "MachineDeployment", Name: "md", UID: "md-uid", Controller: &controller},
+				})
+			})
+
+			It("should leave the machines to the MachineDeployment", func() {
+				machines, err := provider.GetMachines(context.TODO(), fakeClient, machineSet)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(machines).To(BeEmpty())
+			})
+		})
+
+		Context("When the provider handles MachineDeployments", func() {
+			BeforeEach(func() {
+				provider.Kind = ClusterAPIMachineDeploymentKind
+				machineSet.SetKind("MachineDeployment")
+			})
+
+			It("should return the machines of all MachineSets matching the selector", func() {
+				machines, err := provider.GetMachines(context.TODO(), fakeClient, machineSet)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(machines).To(HaveLen(2))
+			})
+		})
+	})
+
+	Describe("GetNode", func() {
+		BeforeEach(func() {
+			objects = []client.Object{
+				&corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node-1"}},
+			}
+		})
+
+		It("should return the node referenced by the machine", func() {
+			node, err := provider.GetNode(context.TODO(), fakeClient, newClusterAPIMachine("m", nil, "node-1"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(node.Name).To(Equal("node-1"))
+		})
+
+		It("should return no node when the machine has no node reference", func() {
+			node, err := provider.GetNode(context.TODO(), fakeClient, newClusterAPIMachine("m", nil, ""))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(node).To(BeNil())
+		})
+	})
+})
@@ -0,0 +1,52 @@
+package machine
+
+import (
+	machinev1 "github.com/openshift/api/machine/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// MachinePhaseFailed is the phase of machines that can't be provisioned or lost their instance.
+// Both the Machine API and the Cluster API use it.
+const MachinePhaseFailed = "Failed"
+
+// GetMachinePhase returns the phase of a Machine API or Cluster API machine, or an empty string if it isn't set
+func GetMachinePhase(machine client.Object) string {
+	switch mm := machine.(type) {
+	case *machinev1.Machine:
+		if mm.Status.Phase == nil {
+			return ""
+		}
+		return *mm.Status.Phase
+	case *unstructured.Unstructured:
+		phase, _, _ := unstructured.NestedString(mm.Object, "status", "phase")
+		return phase
+	}
+	return ""
+}
+
+// GetNodeName returns the name of the node of a Machine API or Cluster API machine, or an empty string if it has none
+func GetNodeName(machine client.Object) string {
+	switch mm := machine.(type) {
+	case *machinev1.Machine:
+		if mm.Status.NodeRef == nil {
+			return ""
+		}
+		return mm.Status.NodeRef.Name
+	case *unstructured.Unstructured:
+		nodeName, _, _ := unstructured.NestedString(mm.Object, "status", "nodeRef", "name")
+		return nodeName
+	}
+	return ""
+}
+
+// IsNodeReady returns true if the node reports the Ready condition as True
+func IsNodeReady(node *corev1.Node) bool {
+	for _, condition := range node.Status.Conditions {
+		if condition.Type == corev1.NodeReady {
+			return condition.Status == corev1.ConditionTrue
+		}
+	}
+	return false
+}
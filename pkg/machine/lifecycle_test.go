@@ -0,0 +1,69 @@
+package machine
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	machinev1 "github.com/openshift/api/machine/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+var _ = Describe("Lifecycle", func() {
+
+	Describe("GetMachinePhase", func() {
+		It("should return the phase of a Machine API machine", func() {
+			phase := MachinePhaseFailed
+			Expect(GetMachinePhase(&machinev1.Machine{Status: machinev1.MachineStatus{Phase: &phase}})).To(Equal(MachinePhaseFailed))
+		})
+
+		It("should return an empty phase when a Machine API machine has no phase yet", func() {
+			Expect(GetMachinePhase(&machinev1.Machine{})).To(BeEmpty())
+		})
+
+		It("should return the phase of a Cluster API machine", func() {
+			machine := &unstructured.Unstructured{Object: map[string]interface{}{
+				"status": map[string]interface{}{"phase": "Provisioning"},
+			}}
+			Expect(GetMachinePhase(machine)).To(Equal("Provisioning"))
+		})
+	})
+
+	Describe("GetNodeName", func() {
+		It("should return the node of a Machine API machine", func() {
+			machine := &machinev1.Machine{Status: machinev1.MachineStatus{NodeRef: &corev1.ObjectReference{Name: "node-1"}}}
+			Expect(GetNodeName(machine)).To(Equal("node-1"))
+		})
+
+		It("should return an empty name when a Machine API machine has no node", func() {
+			Expect(GetNodeName(&machinev1.Machine{})).To(BeEmpty())
+		})
+
+		It("should return the node of a Cluster API machine", func() {
+			machine := &unstructured.Unstructured{Object: map[string]interface{}{
+				"status": map[string]interface{}{"nodeRef": map[string]interface{}{"name": "node-1"}},
+			}}
+			Expect(GetNodeName(machine)).To(Equal("node-1"))
+		})
+	})
+
+	Describe("IsNodeReady", func() {
+		It("should return true when the node is Ready", func() {
+			node := &corev1.Node{Status: corev1.NodeStatus{Conditions: []corev1.NodeCondition{
+				{Type: corev1.NodeReady, Status: corev1.ConditionTrue},
+			}}}
+			Expect(IsNodeReady(node)).To(BeTrue())
+		})
+
+		It("should return false when the node isn't Ready", func() {
+			node := &corev1.Node{Status: corev1.NodeStatus{Conditions: []corev1.NodeCondition{
+				{Type: corev1.NodeReady, Status: corev1.ConditionUnknown},
+			}}}
+			Expect(IsNodeReady(node)).To(BeFalse())
+		})
+
+		It("should return false when the node has no Ready condition", func() {
+			Expect(IsNodeReady(&corev1.Node{})).To(BeFalse())
+		})
+	})
+})
@@ -2,9 +2,10 @@ package machine
 
 import (
 	"context"
-	"fmt"
 
+	cpmsv1 "github.com/openshift/api/machine/v1"
 	machinev1 "github.com/openshift/api/machine/v1beta1"
+	"github.com/openshift/managed-node-metadata-operator/pkg/reasons"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/labels"
@@ -24,31 +25,107 @@ func GetMachinesForMachineSet(c client.Client, machineSet *machinev1.MachineSet)
 	// Make sure that label selector can match template's labels.
 	selector, err := metav1.LabelSelectorAsSelector(&machineSet.Spec.Selector)
 	if err != nil {
-		return nil, fmt.Errorf("failed to parse MachineSet %q label selector: %w", machineSet.Name, err)
+		return nil, reasons.New(reasons.ReasonInvalidSelector, "failed to parse MachineSet %q label selector: %w", machineSet.Name, err)
 	}
 
 	if !selector.Matches(labels.Set(machineSet.Spec.Template.Labels)) {
-		return nil, fmt.Errorf("failed validation on MachineSet %q label selector, cannot match any machines ", machineSet.Name)
+		return nil, reasons.New(reasons.ReasonInvalidSelector, "failed validation on MachineSet %q label selector, cannot match any machines ", machineSet.Name)
 	}
 
-	// Filter out irrelevant machines (deleting/mismatch labels)
+	return filterMachines(machineSet, &machineSet.Spec.Selector, allMachines.Items), nil
+}
+
+// GetMachinesForControlPlaneMachineSet returns all machines matching the ControlPlaneMachineSet
+func GetMachinesForControlPlaneMachineSet(c client.Client, cpms *cpmsv1.ControlPlaneMachineSet) ([]*machinev1.Machine, error) {
+	allMachines := &machinev1.MachineList{}
+
+	err := c.List(context.Background(), allMachines, client.InNamespace(cpms.Namespace))
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := metav1.LabelSelectorAsSelector(&cpms.Spec.Selector); err != nil {
+		return nil, reasons.New(reasons.ReasonInvalidSelector, "failed to parse ControlPlaneMachineSet %q label selector: %w", cpms.Name, err)
+	}
+
+	return filterMachines(cpms, &cpms.Spec.Selector, allMachines.Items), nil
+}
+
+// GetControlPlaneMachineSetsForMachine returns the ControlPlaneMachineSets in the namespace of the machine whose
+// selector matches it
+func GetControlPlaneMachineSetsForMachine(c client.Client, machine *machinev1.Machine) ([]*cpmsv1.ControlPlaneMachineSet, error) {
+	allControlPlaneMachineSets := &cpmsv1.ControlPlaneMachineSetList{}
+
+	err := c.List(context.Background(), allControlPlaneMachineSets, client.InNamespace(machine.Namespace))
+	if err != nil {
+		return nil, err
+	}
+
+	controlPlaneMachineSets := []*cpmsv1.ControlPlaneMachineSet{}
+	for idx := range allControlPlaneMachineSets.Items {
+		cpms := &allControlPlaneMachineSets.Items[idx]
+		if selectorMatchesMachine(&cpms.Spec.Selector, machine) {
+			controlPlaneMachineSets = append(controlPlaneMachineSets, cpms)
+		}
+	}
+
+	return controlPlaneMachineSets, nil
+}
+
+// GetOverlappingMachineSets returns the names of the other MachineSets in the namespace whose selector also
+// matches the given machines, keyed by machine name. Machines controlled by the MachineSet and machines matched
+// by no other MachineSet are not part of the result.
+func GetOverlappingMachineSets(c client.Client, machineSet *machinev1.MachineSet, machines []*machinev1.Machine) (map[string][]string, error) {
+	allMachineSets := &machinev1.MachineSetList{}
+
+	err := c.List(context.Background(), allMachineSets, client.InNamespace(machineSet.Namespace))
+	if err != nil {
+		return nil, err
+	}
+
+	overlaps := map[string][]string{}
+	for _, machine := range machines {
+		if metav1.IsControlledBy(machine, machineSet) {
+			continue
+		}
+		for idx := range allMachineSets.Items {
+			other := &allMachineSets.Items[idx]
+			if other.Name == machineSet.Name || other.DeletionTimestamp != nil {
+				continue
+			}
+			if shouldExcludeMachine(other, machine) {
+				continue
+			}
+			overlaps[machine.Name] = append(overlaps[machine.Name], other.Name)
+		}
+	}
+
+	return overlaps, nil
+}
+
+// filterMachines filters out irrelevant machines (deleting/mismatch labels/other owner)
+func filterMachines(owner metav1.Object, selector *metav1.LabelSelector, allMachines []machinev1.Machine) []*machinev1.Machine {
 	machines := []*machinev1.Machine{}
-	for idx := range allMachines.Items {
-		machine := &allMachines.Items[idx]
-		if shouldExcludeMachine(machineSet, machine) {
+	for idx := range allMachines {
+		machine := &allMachines[idx]
+		if shouldExcludeMachineForOwner(owner, selector, machine) {
 			continue
 		}
 
 		machines = append(machines, machine)
 	}
 
-	return machines, nil
+	return machines
 }
 
 // shouldExcludeMachine returns true if the machine should be filtered out, false otherwise.
 func shouldExcludeMachine(machineSet *machinev1.MachineSet, machine *machinev1.Machine) bool {
+	return shouldExcludeMachineForOwner(machineSet, &machineSet.Spec.Selector, machine)
+}
+
+func shouldExcludeMachineForOwner(owner metav1.Object, selector *metav1.LabelSelector, machine *machinev1.Machine) bool {
 	// Ignore inactive machines.
-	if metav1.GetControllerOf(machine) != nil && !metav1.IsControlledBy(machine, machineSet) {
+	if metav1.GetControllerOf(machine) != nil && !metav1.IsControlledBy(machine, owner) {
 		return true
 	}
 
@@ -56,7 +133,7 @@ func shouldExcludeMachine(machineSet *machinev1.MachineSet, machine *machinev1.M
 		return true
 	}
 
-	if !hasMatchingLabels(machineSet, machine) {
+	if !selectorMatchesMachine(selector, machine) {
 		return true
 	}
 
@@ -64,7 +141,11 @@ func shouldExcludeMachine(machineSet *machinev1.MachineSet, machine *machinev1.M
 }
 
 func hasMatchingLabels(machineSet *machinev1.MachineSet, machine *machinev1.Machine) bool {
-	selector, err := metav1.LabelSelectorAsSelector(&machineSet.Spec.Selector)
+	return selectorMatchesMachine(&machineSet.Spec.Selector, machine)
+}
+
+func selectorMatchesMachine(labelSelector *metav1.LabelSelector, machine *machinev1.Machine) bool {
+	selector, err := metav1.LabelSelectorAsSelector(labelSelector)
 	if err != nil {
 		return false
 	}
@@ -7,10 +7,13 @@ import (
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
 
+	cpmsv1 "github.com/openshift/api/machine/v1"
 	machinev1 "github.com/openshift/api/machine/v1beta1"
+	"github.com/openshift/managed-node-metadata-operator/pkg/reasons"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/client/fake"
 )
@@ -293,6 +296,230 @@ var _ = Describe("Machine", func() {
 		})
 	})
 
+	Describe("GetMachinesForControlPlaneMachineSet", func() {
+		var (
+			fakeClient client.Client
+			cpms       *cpmsv1.ControlPlaneMachineSet
+		)
+
+		BeforeEach(func() {
+			scheme := runtime.NewScheme()
+			_ = machinev1.AddToScheme(scheme)
+			_ = cpmsv1.AddToScheme(scheme)
+			fakeClient = fake.NewClientBuilder().WithScheme(scheme).Build()
+
+			cpms = &cpmsv1.ControlPlaneMachineSet{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "cluster",
+					Namespace: "test-namespace",
+					UID:       "cpms-uid",
+				},
+				Spec: cpmsv1.ControlPlaneMachineSetSpec{
+					Selector: metav1.LabelSelector{
+						MatchLabels: map[string]string{
+							"machine.openshift.io/cluster-api-machine-role": "master",
+						},
+					},
+				},
+			}
+		})
+
+		Context("When control plane machines exist", func() {
+			BeforeEach(func() {
+				controller := true
+				for _, machine := range []*machinev1.Machine{
+					{
+						ObjectMeta: metav1.ObjectMeta{
+							Name:      "master-0",
+							Namespace: "test-namespace",
+							Labels: map[string]string{
+								"machine.openshift.io/cluster-api-machine-role": "master",
+							},
+						},
+					},
+					{
+						ObjectMeta: metav1.ObjectMeta{
+							Name:      "master-1",
+							Namespace: "test-namespace",
+							Labels: map[string]string{
+								"machine.openshift.io/cluster-api-machine-role": "master",
+							},
+							OwnerReferences: []metav1.OwnerReference{
+								{
+									Name:       "cluster",
+									Kind:       "ControlPlaneMachineSet",
+									UID:        "cpms-uid",
+									Controller: &controller,
+								},
+							},
+						},
+					},
+					{
+						ObjectMeta: metav1.ObjectMeta{
+							Name:      "worker-0",
+							Namespace: "test-namespace",
+							Labels: map[string]string{
+								"machine.openshift.io/cluster-api-machine-role": "worker",
+							},
+						},
+					},
+				} {
+					Expect(fakeClient.Create(context.Background(), machine)).To(Succeed())
+				}
+			})
+
+			It("should return the control plane machines only", func() {
+				machines, err := GetMachinesForControlPlaneMachineSet(fakeClient, cpms)
+				Expect(err).To(BeNil())
+				Expect(machines).To(HaveLen(2))
+				Expect(machines[0].Name).To(Equal("master-0"))
+				Expect(machines[1].Name).To(Equal("master-1"))
+			})
+		})
+
+		Context("When label selector is invalid", func() {
+			BeforeEach(func() {
+				cpms.Spec.Selector = metav1.LabelSelector{
+					MatchExpressions: []metav1.LabelSelectorRequirement{
+						{
+							Key:      "invalid",
+							Operator: "InvalidOperator",
+						},
+					},
+				}
+			})
+
+			It("should return error", func() {
+				machines, err := GetMachinesForControlPlaneMachineSet(fakeClient, cpms)
+				Expect(err).ToNot(BeNil())
+				Expect(err.Error()).To(ContainSubstring("failed to parse ControlPlaneMachineSet"))
+				Expect(reasons.ReasonFor(err)).To(Equal(reasons.ReasonInvalidSelector))
+				Expect(machines).To(BeNil())
+			})
+		})
+	})
+
+	Describe("GetControlPlaneMachineSetsForMachine", func() {
+		var fakeClient client.Client
+
+		BeforeEach(func() {
+			scheme := runtime.NewScheme()
+			_ = machinev1.AddToScheme(scheme)
+			_ = cpmsv1.AddToScheme(scheme)
+			fakeClient = fake.NewClientBuilder().WithScheme(scheme).WithObjects(
+				&cpmsv1.ControlPlaneMachineSet{
+					ObjectMeta: metav1.ObjectMeta{Name: "cluster", Namespace: "test-namespace"},
+					Spec: cpmsv1.ControlPlaneMachineSetSpec{
+						Selector: metav1.LabelSelector{
+							MatchLabels: map[string]string{"machine.openshift.io/cluster-api-machine-role": "master"},
+						},
+					},
+				},
+			).Build()
+		})
+
+		It("should return the ControlPlaneMachineSet selecting the machine", func() {
+			machine := &machinev1.Machine{ObjectMeta: metav1.ObjectMeta{
+				Name:      "master-0",
+				Namespace: "test-namespace",
+				Labels:    map[string]string{"machine.openshift.io/cluster-api-machine-role": "master"},
+			}}
+			cpmsList, err := GetControlPlaneMachineSetsForMachine(fakeClient, machine)
+			Expect(err).To(BeNil())
+			Expect(cpmsList).To(HaveLen(1))
+			Expect(cpmsList[0].Name).To(Equal("cluster"))
+		})
+
+		It("should not return it for worker machines or machines of other namespaces", func() {
+			for _, machine := range []*machinev1.Machine{
+				{ObjectMeta: metav1.ObjectMeta{
+					Name:      "worker-0",
+					Namespace: "test-namespace",
+					Labels:    map[string]string{"machine.openshift.io/cluster-api-machine-role": "worker"},
+				}},
+				{ObjectMeta: metav1.ObjectMeta{
+					Name:      "master-0",
+					Namespace: "other-namespace",
+					Labels:    map[string]string{"machine.openshift.io/cluster-api-machine-role": "master"},
+				}},
+			} {
+				cpmsList, err := GetControlPlaneMachineSetsForMachine(fakeClient, machine)
+				Expect(err).To(BeNil())
+				Expect(cpmsList).To(BeEmpty())
+			}
+		})
+	})
+
+	Describe("GetOverlappingMachineSets", func() {
+		var (
+			fakeClient client.Client
+			machines   []*machinev1.Machine
+		)
+
+		newMachineSet := func(name string, pool string) *machinev1.MachineSet {
+			return &machinev1.MachineSet{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      name,
+					Namespace: "test-namespace",
+					UID:       types.UID(name + "-uid"),
+				},
+				Spec: machinev1.MachineSetSpec{
+					Selector: metav1.LabelSelector{
+						MatchLabels: map[string]string{"pool": pool},
+					},
+				},
+			}
+		}
+
+		BeforeEach(func() {
+			scheme := runtime.NewScheme()
+			_ = machinev1.AddToScheme(scheme)
+			fakeClient = fake.NewClientBuilder().WithScheme(scheme).WithObjects(
+				newMachineSet("first", "shared"),
+				newMachineSet("second", "shared"),
+				newMachineSet("other", "other"),
+			).Build()
+
+			controller := true
+			machines = []*machinev1.Machine{
+				{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "orphan",
+						Namespace: "test-namespace",
+						Labels:    map[string]string{"pool": "shared"},
+					},
+				},
+				{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "owned",
+						Namespace: "test-namespace",
+						Labels:    map[string]string{"pool": "shared"},
+						OwnerReferences: []metav1.OwnerReference{
+							{
+								Name:       "first",
+								Kind:       "MachineSet",
+								UID:        "first-uid",
+								Controller: &controller,
+							},
+						},
+					},
+				},
+			}
+		})
+
+		It("should return the other machinesets selecting orphan machines", func() {
+			overlaps, err := GetOverlappingMachineSets(fakeClient, newMachineSet("first", "shared"), machines)
+			Expect(err).To(BeNil())
+			Expect(overlaps).To(Equal(map[string][]string{"orphan": {"second"}}))
+		})
+
+		It("should not report machines controlled by the machineset", func() {
+			overlaps, err := GetOverlappingMachineSets(fakeClient, newMachineSet("first", "shared"), machines[1:])
+			Expect(err).To(BeNil())
+			Expect(overlaps).To(BeEmpty())
+		})
+	})
+
 	Describe("GetNodeForMachine", func() {
 		var (
 			fakeClient client.Client
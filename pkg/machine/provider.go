@@ -0,0 +1,213 @@
+package machine
+
+import (
+	"context"
+	"fmt"
+
+	machinev1 "github.com/openshift/api/machine/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/discovery"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// ProviderAuto detects the provider from the APIs served by the cluster
+	ProviderAuto = "auto"
+	// ProviderMachineAPI selects the OpenShift Machine API (machine.openshift.io)
+	ProviderMachineAPI = "machine-api"
+	// ProviderClusterAPI selects the upstream Cluster API (cluster.x-k8s.io)
+	ProviderClusterAPI = "cluster-api"
+
+	// ControlPlaneMachineSetKind is the kind of machine.openshift.io/v1 ControlPlaneMachineSets
+	ControlPlaneMachineSetKind = "ControlPlaneMachineSet"
+
+	machineAPIGroup = "machine.openshift.io"
+	clusterAPIGroup = "cluster.x-k8s.io"
+)
+
+// Provider gives the sync pipeline access to the machine sets, machines and nodes of a machine management API
+type Provider interface {
+	// Name identifies the provider and the kind of machine set it handles
+	Name() string
+	// NewMachineSet returns an empty machine set object of the provider
+	NewMachineSet() client.Object
+	// NewMachineSetList returns an empty machine set list of the provider
+	NewMachineSetList() client.ObjectList
+	// NewMachine returns an empty machine object of the provider
+	NewMachine() client.Object
+	// GetMachines returns all machines belonging to the machine set
+	GetMachines(ctx context.Context, c client.Client, machineSet client.Object) ([]client.Object, error)
+	// GetNode returns the node of the machine, or nil if the machine has no node yet
+	GetNode(ctx context.Context, c client.Client, machine client.Object) (*corev1.Node, error)
+	// DesiredLabels returns the node labels requested by the machine set template
+	DesiredLabels(machineSet client.Object) map[string]string
+	// DesiredTaints returns the node taints requested by the machine set template
+	DesiredTaints(machineSet client.Object) []corev1.Taint
+	// SyncsTaints tells if the machine set templates carry node taints. The node taints of providers that don't
+	// are left alone.
+	SyncsTaints() bool
+}
+
+// DetectProvider returns the provider name for the machine management API served by the cluster.
+// The Machine API is preferred when both APIs are served. For the Cluster API the preferred version is returned too.
+func DetectProvider(dc discovery.ServerGroupsInterface) (string, string, error) {
+	groups, err := dc.ServerGroups()
+	if err != nil {
+		return "", "", fmt.Errorf("failed to discover API groups: %w", err)
+	}
+
+	clusterAPIVersion := ""
+	for _, group := range groups.Groups {
+		switch group.Name {
+		case machineAPIGroup:
+			return ProviderMachineAPI, "", nil
+		case clusterAPIGroup:
+			clusterAPIVersion = group.PreferredVersion.Version
+		}
+	}
+	if clusterAPIVersion != "" {
+		return ProviderClusterAPI, clusterAPIVersion, nil
+	}
+
+	return "", "", fmt.Errorf("neither %s nor %s is served by the cluster", machineAPIGroup, clusterAPIGroup)
+}
+
+// Providers returns the providers of the machine management API and the namespace of its machine sets. With
+// ProviderAuto, the API is detected from the APIs served by the cluster. An empty namespace defaults to
+// openshift-machine-api for the Machine API. The Cluster API machine sets live in the namespaces of their
+// clusters, so the namespace must be given for the Cluster API.
+func Providers(dc discovery.ServerGroupsInterface, name string, clusterAPIVersion string, namespace string) ([]Provider, string, error) {
+	if name == ProviderAuto {
+		var err error
+		name, clusterAPIVersion, err = DetectProvider(dc)
+		if err != nil {
+			return nil, "", err
+		}
+	}
+
+	switch name {
+	case ProviderMachineAPI:
+		if namespace == "" {
+			namespace = "openshift-machine-api"
+		}
+		return []Provider{MachineAPIProvider{}}, namespace, nil
+	case ProviderClusterAPI:
+		if namespace == "" {
+			return nil, "", fmt.Errorf("the namespace of the %s machine sets must be given", clusterAPIGroup)
+		}
+		return []Provider{
+			ClusterAPIProvider{Version: clusterAPIVersion, Kind: ClusterAPIMachineSetKind},
+			ClusterAPIProvider{Version: clusterAPIVersion, Kind: ClusterAPIMachineDeploymentKind},
+		}, namespace, nil
+	}
+	return nil, "", fmt.Errorf("unknown machine provider %q", name)
+}
+
+// MachineSetKey returns the key of a machine set of the provider in the metrics and the sync results: the name of
+// MachineSets, and the kind and name of MachineDeployments and ControlPlaneMachineSets, so they don't share a key
+// with a MachineSet of the same name
+func MachineSetKey(p Provider, name string) string {
+	switch provider := p.(type) {
+	case ClusterAPIProvider:
+		if provider.Kind != ClusterAPIMachineSetKind {
+			return provider.Kind + "/" + name
+		}
+	case ControlPlaneMachineSetProvider:
+		return ControlPlaneMachineSetKind + "/" + name
+	}
+	return name
+}
+
+// MachineAPIProvider is the Provider for machine.openshift.io MachineSets
+type MachineAPIProvider struct{}
+
+func (MachineAPIProvider) Name() string {
+	return "machineset"
+}
+
+func (MachineAPIProvider) NewMachineSet() client.Object {
+	return &machinev1.MachineSet{}
+}
+
+func (MachineAPIProvider) NewMachineSetList() client.ObjectList {
+	return &machinev1.MachineSetList{}
+}
+
+func (MachineAPIProvider) NewMachine() client.Object {
+	return &machinev1.Machine{}
+}
+
+func (MachineAPIProvider) GetMachines(ctx context.Context, c client.Client, machineSet client.Object) ([]client.Object, error) {
+	ms, ok := machineSet.(*machinev1.MachineSet)
+	if !ok {
+		return nil, fmt.Errorf("unexpected machine set type %T", machineSet)
+	}
+	machines, err := GetMachinesForMachineSet(c, ms)
+	if err != nil {
+		return nil, err
+	}
+	return MachineObjects(machines), nil
+}
+
+func (MachineAPIProvider) GetNode(ctx context.Context, c client.Client, machine client.Object) (*corev1.Node, error) {
+	mm, ok := machine.(*machinev1.Machine)
+	if !ok {
+		return nil, fmt.Errorf("unexpected machine type %T", machine)
+	}
+	if mm.Status.NodeRef == nil || mm.Status.NodeRef.Name == "" {
+		return nil, nil
+	}
+	return GetNodeForMachine(c, mm)
+}
+
+func (MachineAPIProvider) DesiredLabels(machineSet client.Object) map[string]string {
+	ms, ok := machineSet.(*machinev1.MachineSet)
+	if !ok {
+		return nil
+	}
+	return copyLabels(ms.Spec.Template.Spec.Labels)
+}
+
+func (MachineAPIProvider) DesiredTaints(machineSet client.Object) []corev1.Taint {
+	ms, ok := machineSet.(*machinev1.MachineSet)
+	if !ok {
+		return nil
+	}
+	return ms.Spec.Template.Spec.Taints
+}
+
+func (MachineAPIProvider) SyncsTaints() bool {
+	return true
+}
+
+// ControlPlaneMachineSetProvider is the Provider for the control plane machines of a machine.openshift.io/v1
+// ControlPlaneMachineSet. The ControlPlaneMachineSet is handled as a MachineSet carrying its selector and
+// machine template, only its name and key differ from the MachineAPIProvider.
+type ControlPlaneMachineSetProvider struct {
+	MachineAPIProvider
+}
+
+func (ControlPlaneMachineSetProvider) Name() string {
+	return "controlplanemachineset"
+}
+
+// MachineObjects converts a list of machines to client objects
+func MachineObjects(machines []*machinev1.Machine) []client.Object {
+	objects := make([]client.Object, 0, len(machines))
+	for _, machine := range machines {
+		objects = append(objects, machine)
+	}
+	return objects
+}
+
+// copyLabels returns a copy of the labels, keeping a nil map nil
+func copyLabels(labels map[string]string) map[string]string {
+	if labels == nil {
+		return nil
+	}
+	result := make(map[string]string, len(labels))
+	for k, v := range labels {
+		result[k] = v
+	}
+	return result
+}
@@ -0,0 +1,165 @@
+package machine
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	machinev1 "github.com/openshift/api/machine/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	fakediscovery "k8s.io/client-go/discovery/fake"
+	clienttesting "k8s.io/client-go/testing"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+var _ = Describe("Provider", func() {
+
+	Describe("Providers", func() {
+		var dc *fakediscovery.FakeDiscovery
+
+		BeforeEach(func() {
+			dc = &fakediscovery.FakeDiscovery{Fake: &clienttesting.Fake{}}
+		})
+
+		It("should return the Machine API provider", func() {
+			providers, namespace, err := Providers(dc, ProviderMachineAPI, "", "")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(providers).To(Equal([]Provider{MachineAPIProvider{}}))
+			Expect(namespace).To(Equal("openshift-machine-api"))
+		})
+
+		It("should keep the given namespace", func() {
+			_, namespace, err := Providers(dc, ProviderMachineAPI, "", "machines")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(namespace).To(Equal("machines"))
+		})
+
+		It("should return the Cluster API providers of the detected version", func() {
+			dc.Resources = []*metav1.APIResourceList{
+				{GroupVersion: "cluster.x-k8s.io/v1beta2"},
+			}
+			providers, namespace, err := Providers(dc, ProviderAuto, "", "clusters")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(providers).To(Equal([]Provider{
+				ClusterAPIProvider{Version: "v1beta2", Kind: ClusterAPIMachineSetKind},
+				ClusterAPIProvider{Version: "v1beta2", Kind: ClusterAPIMachineDeploymentKind},
+			}))
+			Expect(namespace).To(Equal("clusters"))
+		})
+
+		It("should require the namespace of the Cluster API machine sets", func() {
+			_, _, err := Providers(dc, ProviderClusterAPI, "v1beta1", "")
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("should reject unknown providers", func() {
+			_, _, err := Providers(dc, "other", "", "")
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	Describe("MachineSetKey", func() {
+		It("should key the MachineSets by name and the MachineDeployments and ControlPlaneMachineSets by kind and name", func() {
+			Expect(MachineSetKey(MachineAPIProvider{}, "infra")).To(Equal("infra"))
+			Expect(MachineSetKey(ClusterAPIProvider{Version: "v1beta1", Kind: ClusterAPIMachineSetKind}, "infra")).To(Equal("infra"))
+			Expect(MachineSetKey(ClusterAPIProvider{Version: "v1beta1", Kind: ClusterAPIMachineDeploymentKind}, "infra")).To(Equal("MachineDeployment/infra"))
+			Expect(MachineSetKey(ControlPlaneMachineSetProvider{}, "cluster")).To(Equal("ControlPlaneMachineSet/cluster"))
+		})
+	})
+
+	Describe("DetectProvider", func() {
+		var dc *fakediscovery.FakeDiscovery
+
+		BeforeEach(func() {
+			dc = &fakediscovery.FakeDiscovery{Fake: &clienttesting.Fake{}}
+		})
+
+		Context("When the Machine API is served", func() {
+			BeforeEach(func() {
+				dc.Resources = []*metav1.APIResourceList{
+					{GroupVersion: "cluster.x-k8s.io/v1beta1"},
+					{GroupVersion: "machine.openshift.io/v1beta1"},
+				}
+			})
+
+			It("should prefer the Machine API", func() {
+				provider, _, err := DetectProvider(dc)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(provider).To(Equal(ProviderMachineAPI))
+			})
+		})
+
+		Context("When only the Cluster API is served", func() {
+			BeforeEach(func() {
+				dc.Resources = []*metav1.APIResourceList{
+					{GroupVersion: "cluster.x-k8s.io/v1beta2"},
+				}
+			})
+
+			It("should select the Cluster API with its preferred version", func() {
+				provider, version, err := DetectProvider(dc)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(provider).To(Equal(ProviderClusterAPI))
+				Expect(version).To(Equal("v1beta2"))
+			})
+		})
+
+		Context("When no machine management API is served", func() {
+			It("should return an error", func() {
+				_, _, err := DetectProvider(dc)
+				Expect(err).To(HaveOccurred())
+			})
+		})
+	})
+
+	Describe("MachineAPIProvider", func() {
+		var (
+			provider   MachineAPIProvider
+			machineSet *machinev1.MachineSet
+		)
+
+		BeforeEach(func() {
+			machineSet = &machinev1.MachineSet{
+				Spec: machinev1.MachineSetSpec{
+					Template: machinev1.MachineTemplateSpec{
+						Spec: machinev1.MachineSpec{
+							ObjectMeta: machinev1.ObjectMeta{
+								Labels: map[string]string{"foo": "bar"},
+							},
+							Taints: []corev1.Taint{
+								{Key: "foo", Value: "bar", Effect: corev1.TaintEffectNoSchedule},
+							},
+						},
+					},
+				},
+			}
+		})
+
+		It("should return a copy of the template labels", func() {
+			labels := provider.DesiredLabels(machineSet)
+			Expect(labels).To(Equal(map[string]string{"foo": "bar"}))
+			delete(labels, "foo")
+			Expect(machineSet.Spec.Template.Spec.Labels).To(HaveKey("foo"))
+		})
+
+		It("should return the template taints", func() {
+			Expect(provider.DesiredTaints(machineSet)).To(Equal(machineSet.Spec.Template.Spec.Taints))
+		})
+
+		Context("When the machine has no node yet", func() {
+			It("should return no node", func() {
+				scheme := runtime.NewScheme()
+				_ = machinev1.AddToScheme(scheme)
+				_ = corev1.AddToScheme(scheme)
+				fakeClient := fake.NewClientBuilder().WithScheme(scheme).Build()
+
+				node, err := provider.GetNode(context.TODO(), fakeClient, &machinev1.Machine{})
+				Expect(err).NotTo(HaveOccurred())
+				Expect(node).To(BeNil())
+			})
+		})
+	})
+})
@@ -1,6 +1,10 @@
 package metrics
 
 import (
+	"sync/atomic"
+	"time"
+
+	"github.com/openshift/managed-node-metadata-operator/pkg/plan"
 	"github.com/prometheus/client_golang/prometheus"
 	"sigs.k8s.io/controller-runtime/pkg/metrics"
 )
@@ -10,14 +14,197 @@ var (
 		Name:        "mnmo_node_reconciliation_failure",
 		Help:        "Reconciliation failures occurring when updating a specific node",
 		ConstLabels: map[string]string{},
-	}, []string{"node"})
+	}, []string{"machineset", "node", "reason"})
+
+	MachineSetSyncFailures = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name:        "mnmo_machineset_sync_failures_total",
+		Help:        "Failed syncs of a machineset",
+		ConstLabels: map[string]string{},
+	}, []string{"machineset", "reason"})
+
+	// recordFailureNode tells whether the node label of the failure metric is recorded
+	recordFailureNode atomic.Bool
+
+	ContestedMachines = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name:        "mnmo_contested_machines",
+		Help:        "Machines of a machineset that are also selected by other machinesets and are not synced",
+		ConstLabels: map[string]string{},
+	}, []string{"machineset"})
+
+	LifecycleDecisions = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name:        "mnmo_lifecycle_decisions_total",
+		Help:        "Decisions taken because of the phase of a machine or the readiness of its node",
+		ConstLabels: map[string]string{},
+	}, []string{"machineset", "decision"})
+
+	ReconcileDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:        "mnmo_reconcile_duration_seconds",
+		Help:        "Time taken to reconcile a machineset",
+		ConstLabels: map[string]string{},
+		Buckets:     prometheus.DefBuckets,
+	}, []string{"machineset"})
+
+	MetadataChanges = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name:        "mnmo_metadata_changes_total",
+		Help:        "Labels and taints added, changed or removed on machines and nodes",
+		ConstLabels: map[string]string{},
+	}, []string{"kind", "metadata", "change"})
+
+	ManagedKeys = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name:        "mnmo_managed_keys",
+		Help:        "Labels and taints managed by the operator for the nodes of a machineset",
+		ConstLabels: map[string]string{},
+	}, []string{"machineset", "metadata"})
+
+	LabelConflicts = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name:        "mnmo_label_conflicts_total",
+		Help:        "Labels not synced because they were already set on the node by someone else",
+		ConstLabels: map[string]string{},
+	}, []string{"machineset"})
+
+	NodesOutOfSync = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name:        "mnmo_nodes_out_of_sync",
+		Help:        "Nodes whose labels or taints differ from their machineset template",
+		ConstLabels: map[string]string{},
+	}, []string{"machineset", "kind"})
+
+	MachineSetConvergenceDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:        "mnmo_machineset_convergence_duration_seconds",
+		Help:        "Time from the operator seeing a new machineset generation to all its nodes matching it",
+		ConstLabels: map[string]string{},
+		Buckets:     convergenceBuckets,
+	}, []string{"machineset"})
+
+	NodeConvergenceDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:        "mnmo_node_convergence_duration_seconds",
+		Help:        "Time from the operator seeing a new machineset generation to a node of the machineset being updated to it",
+		ConstLabels: map[string]string{},
+		Buckets:     convergenceBuckets,
+	}, []string{"machineset"})
+)
+
+// convergenceBuckets go from a second to a bit more than two hours
+var convergenceBuckets = prometheus.ExponentialBuckets(1, 2, 14)
+
+const (
+	// DecisionFailedMachineSkipped is recorded when a machine in the Failed phase is not synced
+	DecisionFailedMachineSkipped = "failed_machine_skipped"
+	// DecisionNodeNotReady is recorded when the labels of a node that isn't Ready yet are synced
+	DecisionNodeNotReady = "node_not_ready"
+	// DecisionNoExecuteTaintsHeld is recorded when NoExecute taints are held back until the node is Ready
+	DecisionNoExecuteTaintsHeld = "noexecute_taints_held"
 )
 
 func init() {
+	recordFailureNode.Store(true)
 	metrics.Registry.MustRegister(NodeReconciliationFailure)
+	metrics.Registry.MustRegister(MachineSetSyncFailures)
+	metrics.Registry.MustRegister(ContestedMachines)
+	metrics.Registry.MustRegister(LifecycleDecisions)
+	metrics.Registry.MustRegister(ReconcileDuration)
+	metrics.Registry.MustRegister(MetadataChanges)
+	metrics.Registry.MustRegister(ManagedKeys)
+	metrics.Registry.MustRegister(LabelConflicts)
+	metrics.Registry.MustRegister(NodesOutOfSync)
+	metrics.Registry.MustRegister(MachineSetConvergenceDuration)
+	metrics.Registry.MustRegister(NodeConvergenceDuration)
+}
+
+// IncreaseNodeReconciliationFailure Adds 1 to the failures of the node of the machineset with the given reason
+func IncreaseNodeReconciliationFailure(machineSet string, node string, reason string) {
+	if !recordFailureNode.Load() {
+		// An empty label value is the same as no label for Prometheus
+		node = ""
+	}
+	NodeReconciliationFailure.WithLabelValues(machineSet, node, reason).Add(1.0)
+}
+
+// IncreaseMachineSetSyncFailure Adds 1 to the failed syncs of the machineset with the given reason
+func IncreaseMachineSetSyncFailure(machineSet string, reason string) {
+	MachineSetSyncFailures.WithLabelValues(machineSet, reason).Add(1.0)
+}
+
+// SetFailureNodeLabel sets whether failures are recorded per node. Dropping the node label bounds the number of
+// series on very large clusters.
+func SetFailureNodeLabel(enabled bool) {
+	recordFailureNode.Store(enabled)
+}
+
+// DeleteNodeMetrics removes the series of a node that no longer exists
+func DeleteNodeMetrics(node string) {
+	if node == "" {
+		return
+	}
+	NodeReconciliationFailure.DeletePartialMatch(prometheus.Labels{"node": node})
+}
+
+// DeleteMachineSetMetrics removes the series of a machineset that no longer exists
+func DeleteMachineSetMetrics(machineSet string) {
+	labels := prometheus.Labels{"machineset": machineSet}
+	NodeReconciliationFailure.DeletePartialMatch(labels)
+	MachineSetSyncFailures.DeletePartialMatch(labels)
+	ContestedMachines.DeletePartialMatch(labels)
+	LifecycleDecisions.DeletePartialMatch(labels)
+	ReconcileDuration.DeletePartialMatch(labels)
+	ManagedKeys.DeletePartialMatch(labels)
+	LabelConflicts.DeletePartialMatch(labels)
+	NodesOutOfSync.DeletePartialMatch(labels)
+	MachineSetConvergenceDuration.DeletePartialMatch(labels)
+	NodeConvergenceDuration.DeletePartialMatch(labels)
+}
+
+// SetContestedMachines sets the number of contested machines of the machineset
+func SetContestedMachines(machineSet string, count int) {
+	ContestedMachines.WithLabelValues(machineSet).Set(float64(count))
+}
+
+// IncreaseLifecycleDecision Adds 1 to the decision taken for a machine of the machineset
+func IncreaseLifecycleDecision(machineSet string, decision string) {
+	LifecycleDecisions.WithLabelValues(machineSet, decision).Add(1.0)
+}
+
+// ObserveReconcileDuration records the time taken to reconcile the machineset
+func ObserveReconcileDuration(machineSet string, duration time.Duration) {
+	ReconcileDuration.WithLabelValues(machineSet).Observe(duration.Seconds())
+}
+
+// RecordMetadataChanges Adds the labels or taints added, changed and removed on an object of the given kind
+func RecordMetadataChanges(kind string, metadata string, added int, changed int, removed int) {
+	for change, count := range map[string]int{plan.ChangeAdded: added, plan.ChangeChanged: changed, plan.ChangeRemoved: removed} {
+		if count > 0 {
+			MetadataChanges.WithLabelValues(kind, metadata, change).Add(float64(count))
+		}
+	}
+}
+
+// SetManagedKeys sets the number of labels or taints managed for the nodes of the machineset
+func SetManagedKeys(machineSet string, metadata string, count int) {
+	ManagedKeys.WithLabelValues(machineSet, metadata).Set(float64(count))
+}
+
+// IncreaseLabelConflicts Adds the labels of a node of the machineset that weren't synced because of conflicts
+func IncreaseLabelConflicts(machineSet string, count int) {
+	if count > 0 {
+		LabelConflicts.WithLabelValues(machineSet).Add(float64(count))
+	}
+}
+
+// SetNodesOutOfSync sets the number of nodes of the machineset whose labels or taints, as given by kind, are out of sync
+func SetNodesOutOfSync(machineSet string, kind string, count int) {
+	NodesOutOfSync.WithLabelValues(machineSet, kind).Set(float64(count))
+}
+
+// DeleteNodesOutOfSync removes the out of sync nodes of a machineset that no longer exists
+func DeleteNodesOutOfSync(machineSet string) {
+	NodesOutOfSync.DeletePartialMatch(prometheus.Labels{"machineset": machineSet})
+}
+
+// ObserveMachineSetConvergence records the time taken for all the nodes of the machineset to match a new generation
+func ObserveMachineSetConvergence(machineSet string, duration time.Duration) {
+	MachineSetConvergenceDuration.WithLabelValues(machineSet).Observe(duration.Seconds())
 }
 
-// IncreaseNodeReconciliationFailure Adds 1
-func IncreaseNodeReconciliationFailure(node string) {
-	NodeReconciliationFailure.WithLabelValues(node).Add(1.0)
+// ObserveNodeConvergence records the time taken for a node of the machineset to be updated to a new generation
+func ObserveNodeConvergence(machineSet string, duration time.Duration) {
+	NodeConvergenceDuration.WithLabelValues(machineSet).Observe(duration.Seconds())
 }
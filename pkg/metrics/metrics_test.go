@@ -4,7 +4,9 @@ import (
 	"fmt"
 	"strings"
 	"sync"
+	"time"
 
+	"github.com/openshift/managed-node-metadata-operator/pkg/plan"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/testutil"
 
@@ -18,6 +20,9 @@ var _ = Describe("Metrics", func() {
 		testNodename1 = "test-nodename-1"
 		testNodename2 = "test-nodename-2"
 		emptyNodename = ""
+
+		testMachineSet = "test-machineset"
+		testReason     = "Conflict"
 	)
 
 	BeforeEach(func() {
@@ -34,8 +39,8 @@ var _ = Describe("Metrics", func() {
 
 		When("the metric is set once", func() {
 			It("should record the metric correctly", func() {
-				IncreaseNodeReconciliationFailure(testNodename1)
-				expectedMetric := fmt.Sprintf(`%smnmo_node_reconciliation_failure{node="%s"} 1
+				IncreaseNodeReconciliationFailure(testMachineSet, testNodename1, testReason)
+				expectedMetric := fmt.Sprintf(`%smnmo_node_reconciliation_failure{machineset="test-machineset",node="%s",reason="Conflict"} 1
 `, metricHelpHeader, testNodename1)
 
 				err := testutil.CollectAndCompare(NodeReconciliationFailure, strings.NewReader(expectedMetric))
@@ -45,12 +50,12 @@ var _ = Describe("Metrics", func() {
 
 		When("the metric is incremented multiple times for the same node", func() {
 			It("should accumulate the values correctly", func() {
-				IncreaseNodeReconciliationFailure(testNodename1)
-				IncreaseNodeReconciliationFailure(testNodename1)
-				IncreaseNodeReconciliationFailure(testNodename1)
-				IncreaseNodeReconciliationFailure(testNodename1)
+				IncreaseNodeReconciliationFailure(testMachineSet, testNodename1, testReason)
+				IncreaseNodeReconciliationFailure(testMachineSet, testNodename1, testReason)
+				IncreaseNodeReconciliationFailure(testMachineSet, testNodename1, testReason)
+				IncreaseNodeReconciliationFailure(testMachineSet, testNodename1, testReason)
 
-				expectedMetric := fmt.Sprintf(`%smnmo_node_reconciliation_failure{node="%s"} 4
+				expectedMetric := fmt.Sprintf(`%smnmo_node_reconciliation_failure{machineset="test-machineset",node="%s",reason="Conflict"} 4
 `, metricHelpHeader, testNodename1)
 
 				err := testutil.CollectAndCompare(NodeReconciliationFailure, strings.NewReader(expectedMetric))
@@ -60,12 +65,12 @@ var _ = Describe("Metrics", func() {
 
 		When("metrics are set for different nodes", func() {
 			It("should track each node separately", func() {
-				IncreaseNodeReconciliationFailure(testNodename1)
-				IncreaseNodeReconciliationFailure(testNodename2)
-				IncreaseNodeReconciliationFailure(testNodename1)
+				IncreaseNodeReconciliationFailure(testMachineSet, testNodename1, testReason)
+				IncreaseNodeReconciliationFailure(testMachineSet, testNodename2, testReason)
+				IncreaseNodeReconciliationFailure(testMachineSet, testNodename1, testReason)
 
-				expectedMetric := fmt.Sprintf(`%smnmo_node_reconciliation_failure{node="%s"} 2
-mnmo_node_reconciliation_failure{node="%s"} 1
+				expectedMetric := fmt.Sprintf(`%smnmo_node_reconciliation_failure{machineset="test-machineset",node="%s",reason="Conflict"} 2
+mnmo_node_reconciliation_failure{machineset="test-machineset",node="%s",reason="Conflict"} 1
 `, metricHelpHeader, testNodename1, testNodename2)
 
 				err := testutil.CollectAndCompare(NodeReconciliationFailure, strings.NewReader(expectedMetric))
@@ -75,9 +80,9 @@ mnmo_node_reconciliation_failure{node="%s"} 1
 
 		When("the metric is called with empty node name", func() {
 			It("should still record the metric", func() {
-				IncreaseNodeReconciliationFailure(emptyNodename)
+				IncreaseNodeReconciliationFailure(testMachineSet, emptyNodename, testReason)
 
-				expectedMetric := fmt.Sprintf(`%smnmo_node_reconciliation_failure{node=""} 1
+				expectedMetric := fmt.Sprintf(`%smnmo_node_reconciliation_failure{machineset="test-machineset",node="",reason="Conflict"} 1
 `, metricHelpHeader)
 
 				err := testutil.CollectAndCompare(NodeReconciliationFailure, strings.NewReader(expectedMetric))
@@ -87,12 +92,12 @@ mnmo_node_reconciliation_failure{node="%s"} 1
 
 		When("the metric is reset", func() {
 			It("should clear all recorded values", func() {
-				IncreaseNodeReconciliationFailure(testNodename1)
-				IncreaseNodeReconciliationFailure(testNodename2)
+				IncreaseNodeReconciliationFailure(testMachineSet, testNodename1, testReason)
+				IncreaseNodeReconciliationFailure(testMachineSet, testNodename2, testReason)
 
 				// Verify metrics are recorded
-				expectedMetric := fmt.Sprintf(`%smnmo_node_reconciliation_failure{node="%s"} 1
-mnmo_node_reconciliation_failure{node="%s"} 1
+				expectedMetric := fmt.Sprintf(`%smnmo_node_reconciliation_failure{machineset="test-machineset",node="%s",reason="Conflict"} 1
+mnmo_node_reconciliation_failure{machineset="test-machineset",node="%s",reason="Conflict"} 1
 `, metricHelpHeader, testNodename1, testNodename2)
 				err := testutil.CollectAndCompare(NodeReconciliationFailure, strings.NewReader(expectedMetric))
 				Expect(err).To(BeNil())
@@ -119,7 +124,7 @@ mnmo_node_reconciliation_failure{node="%s"} 1
 					go func(nodeName string) {
 						defer wg.Done()
 						for j := 0; j < incrementsPerGoroutine; j++ {
-							IncreaseNodeReconciliationFailure(nodeName)
+							IncreaseNodeReconciliationFailure(testMachineSet, nodeName, testReason)
 						}
 					}(fmt.Sprintf("node-%d", i))
 				}
@@ -135,7 +140,7 @@ mnmo_node_reconciliation_failure{node="%s"} 1
 `)
 
 				for i := 0; i < numGoroutines; i++ {
-					expectedMetric.WriteString(fmt.Sprintf(`mnmo_node_reconciliation_failure{node="node-%d"} %d
+					expectedMetric.WriteString(fmt.Sprintf(`mnmo_node_reconciliation_failure{machineset="test-machineset",node="node-%d",reason="Conflict"} %d
 `, i, incrementsPerGoroutine))
 				}
 
@@ -149,11 +154,11 @@ mnmo_node_reconciliation_failure{node="%s"} 1
 		When("checking metric configuration", func() {
 			It("should have correct metric name", func() {
 				// Test by collecting the metric and checking the output
-				IncreaseNodeReconciliationFailure("test-node")
+				IncreaseNodeReconciliationFailure(testMachineSet, "test-node", testReason)
 				expectedMetric := `
 # HELP mnmo_node_reconciliation_failure Reconciliation failures occurring when updating a specific node
 # TYPE mnmo_node_reconciliation_failure counter
-mnmo_node_reconciliation_failure{node="test-node"} 1
+mnmo_node_reconciliation_failure{machineset="test-machineset",node="test-node",reason="Conflict"} 1
 `
 				err := testutil.CollectAndCompare(NodeReconciliationFailure, strings.NewReader(expectedMetric))
 				Expect(err).To(BeNil())
@@ -173,7 +178,7 @@ mnmo_node_reconciliation_failure{node="test-node"} 1
 		When("checking metric label names", func() {
 			It("should have correct label names", func() {
 				// Test by using the metric with a label value
-				metric, err := NodeReconciliationFailure.GetMetricWithLabelValues("valid-node-name")
+				metric, err := NodeReconciliationFailure.GetMetricWithLabelValues(testMachineSet, "valid-node-name", testReason)
 				Expect(err).To(BeNil())
 				Expect(metric).ToNot(BeNil())
 			})
@@ -184,15 +189,15 @@ mnmo_node_reconciliation_failure{node="test-node"} 1
 		When("calling IncreaseNodeReconciliationFailure with nil-like values", func() {
 			It("should handle empty string gracefully", func() {
 				Expect(func() {
-					IncreaseNodeReconciliationFailure("")
+					IncreaseNodeReconciliationFailure(testMachineSet, "", testReason)
 				}).ToNot(Panic())
 			})
 
 			It("should handle whitespace-only strings", func() {
 				Expect(func() {
-					IncreaseNodeReconciliationFailure("   ")
-					IncreaseNodeReconciliationFailure("\t")
-					IncreaseNodeReconciliationFailure("\n")
+					IncreaseNodeReconciliationFailure(testMachineSet, "   ", testReason)
+					IncreaseNodeReconciliationFailure(testMachineSet, "\t", testReason)
+					IncreaseNodeReconciliationFailure(testMachineSet, "\n", testReason)
 				}).ToNot(Panic())
 			})
 		})
@@ -213,24 +218,270 @@ mnmo_node_reconciliation_failure{node="test-node"} 1
 
 				for _, nodeName := range specialChars {
 					Expect(func() {
-						IncreaseNodeReconciliationFailure(nodeName)
+						IncreaseNodeReconciliationFailure(testMachineSet, nodeName, testReason)
 					}).ToNot(Panic(), "Should handle node name: %s", nodeName)
 				}
 			})
 		})
 	})
 
+	Context("Machineset sync failures metric", func() {
+		When("failed syncs are recorded", func() {
+			It("should count them by machineset and reason", func() {
+				IncreaseMachineSetSyncFailure(testMachineSet, "InvalidSelector")
+				IncreaseMachineSetSyncFailure(testMachineSet, "InvalidSelector")
+				IncreaseMachineSetSyncFailure(testMachineSet, testReason)
+				expectedMetric := `
+# HELP mnmo_machineset_sync_failures_total Failed syncs of a machineset
+# TYPE mnmo_machineset_sync_failures_total counter
+mnmo_machineset_sync_failures_total{machineset="test-machineset",reason="Conflict"} 1
+mnmo_machineset_sync_failures_total{machineset="test-machineset",reason="InvalidSelector"} 2
+`
+
+				err := testutil.CollectAndCompare(MachineSetSyncFailures, strings.NewReader(expectedMetric))
+				Expect(err).To(BeNil())
+			})
+		})
+	})
+
+	Context("Contested machines metric", func() {
+		var (
+			metricHelpHeader = `
+# HELP mnmo_contested_machines Machines of a machineset that are also selected by other machinesets and are not synced
+# TYPE mnmo_contested_machines gauge
+`
+		)
+
+		When("the metric is set", func() {
+			It("should record the current number of contested machines", func() {
+				SetContestedMachines("test-machineset", 2)
+				SetContestedMachines("test-machineset", 1)
+				expectedMetric := fmt.Sprintf(`%smnmo_contested_machines{machineset="test-machineset"} 1
+`, metricHelpHeader)
+
+				err := testutil.CollectAndCompare(ContestedMachines, strings.NewReader(expectedMetric))
+				Expect(err).To(BeNil())
+			})
+		})
+	})
+
+	Context("Lifecycle decisions metric", func() {
+		var (
+			metricHelpHeader = `
+# HELP mnmo_lifecycle_decisions_total Decisions taken because of the phase of a machine or the readiness of its node
+# TYPE mnmo_lifecycle_decisions_total counter
+`
+		)
+
+		When("decisions are recorded", func() {
+			It("should count each decision separately", func() {
+				IncreaseLifecycleDecision("test-machineset", DecisionFailedMachineSkipped)
+				IncreaseLifecycleDecision("test-machineset", DecisionNodeNotReady)
+				IncreaseLifecycleDecision("test-machineset", DecisionNodeNotReady)
+				expectedMetric := fmt.Sprintf(`%smnmo_lifecycle_decisions_total{decision="failed_machine_skipped",machineset="test-machineset"} 1
+mnmo_lifecycle_decisions_total{decision="node_not_ready",machineset="test-machineset"} 2
+`, metricHelpHeader)
+
+				err := testutil.CollectAndCompare(LifecycleDecisions, strings.NewReader(expectedMetric))
+				Expect(err).To(BeNil())
+			})
+		})
+	})
+
+	Context("Reconcile duration metric", func() {
+		When("a reconcile is observed", func() {
+			It("should record it in the histogram of the machineset", func() {
+				ObserveReconcileDuration("test-machineset", 150*time.Millisecond)
+				ObserveReconcileDuration("test-machineset", 3*time.Second)
+
+				Expect(testutil.CollectAndCount(ReconcileDuration)).To(Equal(1))
+				expectedMetric := `
+# HELP mnmo_reconcile_duration_seconds Time taken to reconcile a machineset
+# TYPE mnmo_reconcile_duration_seconds histogram
+mnmo_reconcile_duration_seconds_bucket{machineset="test-machineset",le="0.005"} 0
+mnmo_reconcile_duration_seconds_bucket{machineset="test-machineset",le="0.01"} 0
+mnmo_reconcile_duration_seconds_bucket{machineset="test-machineset",le="0.025"} 0
+mnmo_reconcile_duration_seconds_bucket{machineset="test-machineset",le="0.05"} 0
+mnmo_reconcile_duration_seconds_bucket{machineset="test-machineset",le="0.1"} 0
+mnmo_reconcile_duration_seconds_bucket{machineset="test-machineset",le="0.25"} 1
+mnmo_reconcile_duration_seconds_bucket{machineset="test-machineset",le="0.5"} 1
+mnmo_reconcile_duration_seconds_bucket{machineset="test-machineset",le="1"} 1
+mnmo_reconcile_duration_seconds_bucket{machineset="test-machineset",le="2.5"} 1
+mnmo_reconcile_duration_seconds_bucket{machineset="test-machineset",le="5"} 2
+mnmo_reconcile_duration_seconds_bucket{machineset="test-machineset",le="10"} 2
+mnmo_reconcile_duration_seconds_bucket{machineset="test-machineset",le="+Inf"} 2
+mnmo_reconcile_duration_seconds_sum{machineset="test-machineset"} 3.15
+mnmo_reconcile_duration_seconds_count{machineset="test-machineset"} 2
+`
+				err := testutil.CollectAndCompare(ReconcileDuration, strings.NewReader(expectedMetric))
+				Expect(err).To(BeNil())
+			})
+		})
+	})
+
+	Context("Metadata changes metric", func() {
+		var (
+			metricHelpHeader = `
+# HELP mnmo_metadata_changes_total Labels and taints added, changed or removed on machines and nodes
+# TYPE mnmo_metadata_changes_total counter
+`
+		)
+
+		When("changes are recorded", func() {
+			It("should count them by kind, metadata and change", func() {
+				RecordMetadataChanges(plan.KindNode, plan.MetadataLabel, 2, 1, 0)
+				RecordMetadataChanges(plan.KindMachine, plan.MetadataTaint, 0, 0, 1)
+				RecordMetadataChanges(plan.KindNode, plan.MetadataLabel, 1, 0, 0)
+				expectedMetric := fmt.Sprintf(`%smnmo_metadata_changes_total{change="added",kind="Node",metadata="label"} 3
+mnmo_metadata_changes_total{change="changed",kind="Node",metadata="label"} 1
+mnmo_metadata_changes_total{change="removed",kind="Machine",metadata="taint"} 1
+`, metricHelpHeader)
+
+				err := testutil.CollectAndCompare(MetadataChanges, strings.NewReader(expectedMetric))
+				Expect(err).To(BeNil())
+			})
+		})
+	})
+
+	Context("Managed keys metric", func() {
+		When("the managed keys are set", func() {
+			It("should record the labels and taints of the machineset", func() {
+				SetManagedKeys("test-machineset", plan.MetadataLabel, 3)
+				SetManagedKeys("test-machineset", plan.MetadataTaint, 1)
+				expectedMetric := `
+# HELP mnmo_managed_keys Labels and taints managed by the operator for the nodes of a machineset
+# TYPE mnmo_managed_keys gauge
+mnmo_managed_keys{machineset="test-machineset",metadata="label"} 3
+mnmo_managed_keys{machineset="test-machineset",metadata="taint"} 1
+`
+				err := testutil.CollectAndCompare(ManagedKeys, strings.NewReader(expectedMetric))
+				Expect(err).To(BeNil())
+			})
+		})
+	})
+
+	Context("Label conflicts metric", func() {
+		When("conflicts are recorded", func() {
+			It("should count each skipped label", func() {
+				IncreaseLabelConflicts("test-machineset", 2)
+				IncreaseLabelConflicts("test-machineset", 0)
+				IncreaseLabelConflicts("test-machineset", 1)
+				expectedMetric := `
+# HELP mnmo_label_conflicts_total Labels not synced because they were already set on the node by someone else
+# TYPE mnmo_label_conflicts_total counter
+mnmo_label_conflicts_total{machineset="test-machineset"} 3
+`
+
+				err := testutil.CollectAndCompare(LabelConflicts, strings.NewReader(expectedMetric))
+				Expect(err).To(BeNil())
+			})
+		})
+	})
+
+	Context("Nodes out of sync metric", func() {
+		var (
+			metricHelpHeader = `
+# HELP mnmo_nodes_out_of_sync Nodes whose labels or taints differ from their machineset template
+# TYPE mnmo_nodes_out_of_sync gauge
+`
+		)
+
+		When("the nodes out of sync are set", func() {
+			It("should record them by machineset and kind", func() {
+				SetNodesOutOfSync("test-machineset", plan.MetadataLabel, 2)
+				SetNodesOutOfSync("test-machineset", plan.MetadataTaint, 0)
+				expectedMetric := fmt.Sprintf(`%smnmo_nodes_out_of_sync{kind="label",machineset="test-machineset"} 2
+mnmo_nodes_out_of_sync{kind="taint",machineset="test-machineset"} 0
+`, metricHelpHeader)
+
+				err := testutil.CollectAndCompare(NodesOutOfSync, strings.NewReader(expectedMetric))
+				Expect(err).To(BeNil())
+			})
+		})
+
+		When("the machineset is deleted", func() {
+			It("should remove its series only", func() {
+				SetNodesOutOfSync("test-machineset", plan.MetadataLabel, 2)
+				SetNodesOutOfSync("other-machineset", plan.MetadataLabel, 1)
+				DeleteNodesOutOfSync("test-machineset")
+				expectedMetric := fmt.Sprintf(`%smnmo_nodes_out_of_sync{kind="label",machineset="other-machineset"} 1
+`, metricHelpHeader)
+
+				err := testutil.CollectAndCompare(NodesOutOfSync, strings.NewReader(expectedMetric))
+				Expect(err).To(BeNil())
+			})
+		})
+	})
+
+	Context("Failure metric cardinality", func() {
+		var (
+			metricHelpHeader = `
+# HELP mnmo_node_reconciliation_failure Reconciliation failures occurring when updating a specific node
+# TYPE mnmo_node_reconciliation_failure counter
+`
+		)
+
+		When("the node label is dropped", func() {
+			AfterEach(func() {
+				SetFailureNodeLabel(true)
+			})
+
+			It("should record the failures of all nodes together", func() {
+				SetFailureNodeLabel(false)
+				IncreaseNodeReconciliationFailure(testMachineSet, testNodename1, testReason)
+				IncreaseNodeReconciliationFailure(testMachineSet, testNodename2, testReason)
+				expectedMetric := fmt.Sprintf(`%smnmo_node_reconciliation_failure{machineset="test-machineset",node="",reason="Conflict"} 2
+`, metricHelpHeader)
+
+				err := testutil.CollectAndCompare(NodeReconciliationFailure, strings.NewReader(expectedMetric))
+				Expect(err).To(BeNil())
+			})
+		})
+
+		When("a node is deleted", func() {
+			It("should remove the series of the node only", func() {
+				IncreaseNodeReconciliationFailure(testMachineSet, testNodename1, testReason)
+				IncreaseNodeReconciliationFailure(testMachineSet, testNodename2, "Forbidden")
+				DeleteNodeMetrics(testNodename1)
+				expectedMetric := fmt.Sprintf(`%smnmo_node_reconciliation_failure{machineset="test-machineset",node="%s",reason="Forbidden"} 1
+`, metricHelpHeader, testNodename2)
+
+				err := testutil.CollectAndCompare(NodeReconciliationFailure, strings.NewReader(expectedMetric))
+				Expect(err).To(BeNil())
+			})
+		})
+
+		When("a machineset is deleted", func() {
+			It("should remove the series of the machineset", func() {
+				IncreaseNodeReconciliationFailure(testMachineSet, testNodename1, testReason)
+				IncreaseNodeReconciliationFailure("other-machineset", testNodename2, testReason)
+				IncreaseMachineSetSyncFailure(testMachineSet, testReason)
+				SetContestedMachines(testMachineSet, 1)
+				SetManagedKeys(testMachineSet, plan.MetadataLabel, 1)
+				DeleteMachineSetMetrics(testMachineSet)
+				expectedMetric := fmt.Sprintf(`%smnmo_node_reconciliation_failure{machineset="other-machineset",node="%s",reason="Conflict"} 1
+`, metricHelpHeader, testNodename2)
+
+				err := testutil.CollectAndCompare(NodeReconciliationFailure, strings.NewReader(expectedMetric))
+				Expect(err).To(BeNil())
+				Expect(testutil.CollectAndCount(MachineSetSyncFailures)).To(Equal(0))
+				Expect(testutil.CollectAndCount(ContestedMachines)).To(Equal(0))
+				Expect(testutil.CollectAndCount(ManagedKeys)).To(Equal(0))
+			})
+		})
+	})
+
 	Context("Performance and stress testing", func() {
 		When("calling IncreaseNodeReconciliationFailure many times", func() {
 			It("should handle high frequency calls efficiently", func() {
 				const numCalls = 10000
 
 				for i := 0; i < numCalls; i++ {
-					IncreaseNodeReconciliationFailure(fmt.Sprintf("stress-test-node-%d", i%100))
+					IncreaseNodeReconciliationFailure(testMachineSet, fmt.Sprintf("stress-test-node-%d", i%100), testReason)
 				}
 
 				// Verify some metrics were recorded
-				metric, err := NodeReconciliationFailure.GetMetricWithLabelValues("stress-test-node-0")
+				metric, err := NodeReconciliationFailure.GetMetricWithLabelValues(testMachineSet, "stress-test-node-0", testReason)
 				Expect(err).To(BeNil())
 				Expect(metric).ToNot(BeNil())
 			})
@@ -240,4 +491,12 @@ mnmo_node_reconciliation_failure{node="test-node"} 1
 
 func resetMetrics() {
 	NodeReconciliationFailure.Reset()
+	MachineSetSyncFailures.Reset()
+	ContestedMachines.Reset()
+	LifecycleDecisions.Reset()
+	ReconcileDuration.Reset()
+	MetadataChanges.Reset()
+	ManagedKeys.Reset()
+	LabelConflicts.Reset()
+	NodesOutOfSync.Reset()
 }
@@ -0,0 +1,186 @@
+// Package offline loads the MachineSets, Machines and Nodes of a cluster from YAML or JSON dumps, such as the files
+// of a must-gather, so their syncs can be planned without an API server.
+package offline
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	utilyaml "k8s.io/apimachinery/pkg/util/yaml"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+// extensions are the extensions of the files loaded
+var extensions = []string{".yaml", ".yml", ".json"}
+
+// machineGroups are the API groups of the machine management APIs whose objects are loaded
+var machineGroups = []string{"machine.openshift.io", "cluster.x-k8s.io"}
+
+// Cluster is the objects of a cluster loaded from dumps
+type Cluster struct {
+	// Objects are the Nodes and the objects of the machine management APIs. The objects of the types of the scheme
+	// are typed, the others are unstructured.
+	Objects []client.Object
+	// Skipped are the files that couldn't be decoded, with why
+	Skipped map[string]error
+
+	scheme *runtime.Scheme
+}
+
+// Load walks the directory and loads the Nodes and the objects of the machine management APIs of its YAML and JSON
+// files. A file can hold several documents, and lists of objects like the ones of "oc get -o yaml". Other objects are
+// ignored, and files that aren't valid YAML or JSON are recorded as skipped. An object found in several files is
+// loaded once.
+func Load(dir string, scheme *runtime.Scheme) (*Cluster, error) {
+	cluster := &Cluster{Objects: []client.Object{}, Skipped: map[string]error{}, scheme: scheme}
+	seen := map[string]bool{}
+	err := filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() || !slices.Contains(extensions, strings.ToLower(filepath.Ext(path))) {
+			return nil
+		}
+		objects, err := decodeFile(path)
+		if err != nil {
+			cluster.Skipped[path] = err
+		}
+		for _, object := range objects {
+			if !loaded(object.GroupVersionKind()) {
+				continue
+			}
+			key := object.GroupVersionKind().GroupKind().String() + "/" + object.GetNamespace() + "/" + object.GetName()
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			typed, err := cluster.typed(object)
+			if err != nil {
+				cluster.Skipped[path] = err
+				continue
+			}
+			cluster.Objects = append(cluster.Objects, typed)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(cluster.Objects) == 0 {
+		return nil, fmt.Errorf("no Nodes, MachineSets or Machines found in %s", dir)
+	}
+	return cluster, nil
+}
+
+// Client returns a client reading and writing copies of the objects in memory
+func (c *Cluster) Client() client.Client {
+	objects := make([]client.Object, 0, len(c.Objects))
+	for _, object := range c.Objects {
+		objects = append(objects, object.DeepCopyObject().(client.Object))
+	}
+	return fake.NewClientBuilder().WithScheme(c.scheme).WithObjects(objects...).Build()
+}
+
+// ServerGroups returns the machine management API groups of the objects, with the first version found as the
+// preferred one, so the provider can be detected like on a cluster
+func (c *Cluster) ServerGroups() (*metav1.APIGroupList, error) {
+	groups := map[string]*metav1.APIGroup{}
+	names := []string{}
+	for _, object := range c.Objects {
+		gvk := object.GetObjectKind().GroupVersionKind()
+		if gvk.Group == "" {
+			continue
+		}
+		group, ok := groups[gvk.Group]
+		if !ok {
+			version := metav1.GroupVersionForDiscovery{GroupVersion: gvk.GroupVersion().String(), Version: gvk.Version}
+			group = &metav1.APIGroup{Name: gvk.Group, PreferredVersion: version}
+			groups[gvk.Group] = group
+			names = append(names, gvk.Group)
+		}
+		if !slices.ContainsFunc(group.Versions, func(v metav1.GroupVersionForDiscovery) bool { return v.Version == gvk.Version }) {
+			group.Versions = append(group.Versions, metav1.GroupVersionForDiscovery{GroupVersion: gvk.GroupVersion().String(), Version: gvk.Version})
+		}
+	}
+	sort.Strings(names)
+	list := &metav1.APIGroupList{}
+	for _, name := range names {
+		list.Groups = append(list.Groups, *groups[name])
+	}
+	return list, nil
+}
+
+// typed converts the object to its type in the scheme, if it has one
+func (c *Cluster) typed(object *unstructured.Unstructured) (client.Object, error) {
+	gvk := object.GroupVersionKind()
+	if !c.scheme.Recognizes(gvk) {
+		return object, nil
+	}
+	typed, err := c.scheme.New(gvk)
+	if err != nil {
+		return nil, err
+	}
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(object.Object, typed); err != nil {
+		return nil, fmt.Errorf("failed to convert %s %s: %w", gvk.Kind, object.GetName(), err)
+	}
+	typedObject, ok := typed.(client.Object)
+	if !ok {
+		return nil, fmt.Errorf("unexpected type %T of %s", typed, gvk)
+	}
+	typedObject.GetObjectKind().SetGroupVersionKind(gvk)
+	return typedObject, nil
+}
+
+// decodeFile returns the objects of the documents of the file, with the items of the lists
+func decodeFile(path string) ([]*unstructured.Unstructured, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	objects := []*unstructured.Unstructured{}
+	decoder := utilyaml.NewYAMLOrJSONDecoder(f, 4096)
+	for {
+		document := map[string]interface{}{}
+		if err := decoder.Decode(&document); err != nil {
+			if errors.Is(err, io.EOF) {
+				return objects, nil
+			}
+			return objects, err
+		}
+		object := &unstructured.Unstructured{Object: document}
+		if object.GetKind() == "" {
+			continue
+		}
+		if !object.IsList() {
+			objects = append(objects, object)
+			continue
+		}
+		list, err := object.ToList()
+		if err != nil {
+			return objects, err
+		}
+		for i := range list.Items {
+			objects = append(objects, &list.Items[i])
+		}
+	}
+}
+
+// loaded tells if objects of the kind are loaded
+func loaded(gvk schema.GroupVersionKind) bool {
+	return (gvk.Group == corev1.GroupName && gvk.Kind == "Node") || slices.Contains(machineGroups, gvk.Group)
+}
@@ -0,0 +1,115 @@
+package offline
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	machinev1beta1 "github.com/openshift/api/machine/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const machineSetList = `apiVersion: v1
+kind: List
+items:
+- apiVersion: machine.openshift.io/v1beta1
+  kind: MachineSet
+  metadata:
+    name: infra-a
+    namespace: openshift-machine-api
+  spec:
+    template:
+      spec:
+        metadata:
+          labels:
+            team: payments
+`
+
+const nodes = `apiVersion: v1
+kind: Node
+metadata:
+  name: ip-10-0-1-12
+  labels:
+    team: platform
+---
+apiVersion: v1
+kind: Node
+metadata:
+  name: ip-10-0-1-13
+`
+
+const capiMachine = `{"apiVersion": "cluster.x-k8s.io/v1beta1", "kind": "Machine", "metadata": {"name": "capi-a", "namespace": "openshift-cluster-api"}}`
+
+var _ = Describe("Load", func() {
+	var dir string
+
+	s := runtime.NewScheme()
+	Expect(corev1.AddToScheme(s)).To(Succeed())
+	Expect(machinev1beta1.AddToScheme(s)).To(Succeed())
+
+	write := func(path string, content string) {
+		path = filepath.Join(dir, path)
+		Expect(os.MkdirAll(filepath.Dir(path), 0o755)).To(Succeed())
+		Expect(os.WriteFile(path, []byte(content), 0o644)).To(Succeed())
+	}
+
+	BeforeEach(func() {
+		dir = GinkgoT().TempDir()
+		write("namespaces/openshift-machine-api/machine.openshift.io/machinesets.yaml", machineSetList)
+		write("cluster-scoped-resources/core/nodes/nodes.yaml", nodes)
+		write("cluster-scoped-resources/core/nodes/ip-10-0-1-12.yaml", nodes)
+		write("namespaces/openshift-cluster-api/machines.json", capiMachine)
+		write("namespaces/openshift-machine-api/core/configmaps.yaml", "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: ignored\n")
+		write("host_service_logs/kubelet.log", "not loaded")
+	})
+
+	It("should load the nodes and the machine objects once", func() {
+		cluster, err := Load(dir, s)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(cluster.Skipped).To(BeEmpty())
+		Expect(cluster.Objects).To(HaveLen(4))
+
+		c := cluster.Client()
+		node := &corev1.Node{}
+		Expect(c.Get(context.TODO(), client.ObjectKey{Name: "ip-10-0-1-12"}, node)).To(Succeed())
+		Expect(node.Labels).To(HaveKeyWithValue("team", "platform"))
+		machineSet := &machinev1beta1.MachineSet{}
+		Expect(c.Get(context.TODO(), client.ObjectKey{Namespace: "openshift-machine-api", Name: "infra-a"}, machineSet)).To(Succeed())
+		Expect(machineSet.Spec.Template.Spec.Labels).To(HaveKeyWithValue("team", "payments"))
+	})
+
+	It("should keep the objects of types out of the scheme unstructured", func() {
+		cluster, err := Load(dir, s)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(cluster.Objects).To(ContainElement(BeAssignableToTypeOf(&unstructured.Unstructured{})))
+	})
+
+	It("should serve the machine management API groups of the objects", func() {
+		cluster, err := Load(dir, s)
+		Expect(err).NotTo(HaveOccurred())
+		groups, err := cluster.ServerGroups()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(groups.Groups).To(HaveLen(2))
+		Expect(groups.Groups[0].Name).To(Equal("cluster.x-k8s.io"))
+		Expect(groups.Groups[0].PreferredVersion.Version).To(Equal("v1beta1"))
+		Expect(groups.Groups[1].Name).To(Equal("machine.openshift.io"))
+	})
+
+	It("should record the files that aren't valid", func() {
+		write("broken.yaml", "kind: [")
+		cluster, err := Load(dir, s)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(cluster.Skipped).To(HaveKey(filepath.Join(dir, "broken.yaml")))
+	})
+
+	It("should fail when nothing is found", func() {
+		_, err := Load(GinkgoT().TempDir(), s)
+		Expect(err).To(HaveOccurred())
+	})
+})
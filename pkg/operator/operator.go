@@ -0,0 +1,230 @@
+// Package operator wires the controllers, endpoints and checks of the operator into a manager. It's shared by the
+// operator binary and the integration tests, so they run the same operator.
+package operator
+
+import (
+	"fmt"
+	"time"
+
+	cpmsv1 "github.com/openshift/api/machine/v1"
+	machinev1 "github.com/openshift/api/machine/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/client-go/discovery"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/rest"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	ctrlmetrics "sigs.k8s.io/controller-runtime/pkg/metrics"
+	"sigs.k8s.io/controller-runtime/pkg/metrics/filters"
+	metricsserver "sigs.k8s.io/controller-runtime/pkg/metrics/server"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+
+	"github.com/openshift/managed-node-metadata-operator/controllers"
+	"github.com/openshift/managed-node-metadata-operator/pkg/audit"
+	"github.com/openshift/managed-node-metadata-operator/pkg/health"
+	"github.com/openshift/managed-node-metadata-operator/pkg/history"
+	"github.com/openshift/managed-node-metadata-operator/pkg/machine"
+	"github.com/openshift/managed-node-metadata-operator/pkg/tracing"
+)
+
+var setupLog = ctrl.Log.WithName("setup")
+
+// Options configure the operator, the binary sets them from its flags
+type Options struct {
+	// MetricsAddr is the address the metrics endpoint binds to, "0" disables it
+	MetricsAddr string
+	// SecureMetrics serves the metrics over HTTPS to authenticated and authorized clients
+	SecureMetrics bool
+	// MetricsCertDir, MetricsCertName and MetricsKeyName locate the metrics server certificate, a self-signed
+	// certificate is generated if MetricsCertDir is empty
+	MetricsCertDir  string
+	MetricsCertName string
+	MetricsKeyName  string
+	// ProbeAddr is the address the probe endpoint binds to, "0" disables it
+	ProbeAddr string
+	// LeaderElection makes only one replica of the operator run the controllers
+	LeaderElection bool
+
+	// Providers give access to the machine sets, machines and nodes
+	Providers []machine.Provider
+	// Namespace of the machine sets, the cache only covers it
+	Namespace string
+	// EnableControlPlaneMachineSet syncs the control plane nodes too, only with the Machine API
+	EnableControlPlaneMachineSet bool
+	// SyncAutoscalerAnnotations keeps the cluster-autoscaler scale-from-zero annotations of the machinesets in step
+	SyncAutoscalerAnnotations bool
+	// DriftEvaluationInterval is the interval of the drift evaluation, 0 disables it
+	DriftEvaluationInterval time.Duration
+	// TraceClient traces the calls to the API server, when a tracing exporter is set up
+	TraceClient bool
+	// Audit configures the audit sink
+	Audit audit.Options
+	// LivenessStallThreshold is how long a controller can have work queued without progress before the operator is
+	// reported unhealthy
+	LivenessStallThreshold time.Duration
+	// HistoryMaxGenerations is the number of templates kept per machineset in the history ConfigMap of
+	// HistoryNamespace and HistoryName, 0 disables the history
+	HistoryMaxGenerations int
+	HistoryNamespace      string
+	HistoryName           string
+}
+
+// NewScheme returns a scheme with the types the operator uses
+func NewScheme() *runtime.Scheme {
+	scheme := runtime.NewScheme()
+	utilruntime.Must(clientgoscheme.AddToScheme(scheme))
+
+	utilruntime.Must(machinev1.AddToScheme(scheme))
+	utilruntime.Must(cpmsv1.AddToScheme(scheme))
+	//+kubebuilder:scaffold:scheme
+	return scheme
+}
+
+// NewManager returns a manager running the controllers, endpoints and checks of the operator, to be started
+func NewManager(cfg *rest.Config, scheme *runtime.Scheme, opts Options) (manager.Manager, error) {
+	if len(opts.Providers) == 0 {
+		return nil, fmt.Errorf("no machine provider")
+	}
+
+	metricsOpts := metricsserver.Options{
+		BindAddress:   opts.MetricsAddr,
+		SecureServing: opts.SecureMetrics,
+	}
+	if opts.SecureMetrics {
+		// Clients are authenticated with TokenReviews and must be allowed to get the path by a SubjectAccessReview
+		metricsOpts.FilterProvider = filters.WithAuthenticationAndAuthorization
+		metricsOpts.CertDir = opts.MetricsCertDir
+		metricsOpts.CertName = opts.MetricsCertName
+		metricsOpts.KeyName = opts.MetricsKeyName
+	} else {
+		setupLog.Info("serving metrics over HTTP without authentication")
+	}
+
+	mgr, err := ctrl.NewManager(cfg, ctrl.Options{
+		Scheme:                 scheme,
+		Metrics:                metricsOpts,
+		WebhookServer:          webhook.NewServer(webhook.Options{Port: 9443}),
+		HealthProbeBindAddress: opts.ProbeAddr,
+		LeaderElection:         opts.LeaderElection,
+		LeaderElectionID:       "cdb62f5e.managed.openshift.com",
+		Cache: cache.Options{
+			DefaultNamespaces: map[string]cache.Config{
+				opts.Namespace: {},
+			},
+		}})
+	if err != nil {
+		return nil, fmt.Errorf("unable to create manager: %w", err)
+	}
+	c := mgr.GetClient()
+	if opts.TraceClient {
+		c = tracing.WrapClient(c)
+	}
+
+	// The cache only covers the machine namespace, the audit and history ConfigMaps are read and written directly
+	directClient, err := client.New(cfg, client.Options{Scheme: scheme})
+	if err != nil {
+		return nil, fmt.Errorf("unable to create direct client: %w", err)
+	}
+	auditor, err := audit.NewSink(opts.Audit, directClient)
+	if err != nil {
+		return nil, fmt.Errorf("unable to set up audit: %w", err)
+	}
+	var templateHistory *history.Store
+	if opts.HistoryMaxGenerations > 0 {
+		templateHistory = &history.Store{
+			Client:         directClient,
+			Namespace:      opts.HistoryNamespace,
+			Name:           opts.HistoryName,
+			MaxGenerations: opts.HistoryMaxGenerations,
+		}
+	}
+
+	syncResults := controllers.NewSyncResults()
+	// The informers the controllers need before the operator is ready
+	cachedObjects := []client.Object{&corev1.Node{}}
+	controllerNames := []string{}
+	for _, provider := range opts.Providers {
+		cachedObjects = append(cachedObjects, provider.NewMachineSet(), provider.NewMachine())
+		controllerNames = append(controllerNames, provider.Name()+"_controller")
+		if err = (&controllers.MachinesetReconciler{
+			Client:   c,
+			Scheme:   mgr.GetScheme(),
+			Recorder: mgr.GetEventRecorderFor(provider.Name() + "_controller"),
+			Provider: provider,
+			Auditor:  auditor,
+			History:  templateHistory,
+
+			SyncResults:               syncResults,
+			SyncAutoscalerAnnotations: opts.SyncAutoscalerAnnotations,
+		}).SetupWithManager(mgr); err != nil {
+			return nil, fmt.Errorf("unable to create controller %s: %w", provider.Name(), err)
+		}
+		if opts.DriftEvaluationInterval > 0 {
+			if err = mgr.Add(&controllers.DriftEvaluator{
+				Client:    c,
+				Provider:  provider,
+				Namespace: opts.Namespace,
+				Interval:  opts.DriftEvaluationInterval,
+			}); err != nil {
+				return nil, fmt.Errorf("unable to add drift evaluator of %s: %w", provider.Name(), err)
+			}
+		}
+	}
+	_, isMachineAPI := opts.Providers[0].(machine.MachineAPIProvider)
+	if opts.EnableControlPlaneMachineSet && !isMachineAPI {
+		setupLog.Info("ControlPlaneMachineSets are only supported with the Machine API, not syncing control plane nodes")
+	} else if opts.EnableControlPlaneMachineSet {
+		cachedObjects = append(cachedObjects, &cpmsv1.ControlPlaneMachineSet{})
+		controllerNames = append(controllerNames, "controlplanemachineset_controller")
+		if err = (&controllers.ControlPlaneMachineSetReconciler{
+			Client:   c,
+			Scheme:   mgr.GetScheme(),
+			Recorder: mgr.GetEventRecorderFor("controlplanemachineset_controller"),
+			Auditor:  auditor,
+		}).SetupWithManager(mgr); err != nil {
+			return nil, fmt.Errorf("unable to create controller ControlPlaneMachineSet: %w", err)
+		}
+	}
+	//+kubebuilder:scaffold:builder
+
+	if err := mgr.AddMetricsServerExtraHandler(controllers.SyncStatePath, &controllers.SyncStateHandler{
+		Client:    c,
+		Providers: opts.Providers,
+		Namespace: opts.Namespace,
+		Results:   syncResults,
+	}); err != nil {
+		return nil, fmt.Errorf("unable to set up sync state endpoint: %w", err)
+	}
+	if err := mgr.AddMetricsServerExtraHandler(controllers.DriftReportPath, &controllers.DriftReportHandler{
+		Client:    c,
+		Providers: opts.Providers,
+		Namespace: opts.Namespace,
+	}); err != nil {
+		return nil, fmt.Errorf("unable to set up drift report endpoint: %w", err)
+	}
+
+	if err := mgr.AddHealthzCheck("workqueue", (&health.WorkqueueProgress{
+		Gatherer:       ctrlmetrics.Registry,
+		Controllers:    controllerNames,
+		StallThreshold: opts.LivenessStallThreshold,
+	}).Check); err != nil {
+		return nil, fmt.Errorf("unable to set up health check: %w", err)
+	}
+	if err := mgr.AddReadyzCheck("cache-sync", health.CacheSynced(mgr.GetCache(), cachedObjects...)); err != nil {
+		return nil, fmt.Errorf("unable to set up ready check: %w", err)
+	}
+	probeCfg := rest.CopyConfig(cfg)
+	probeCfg.Timeout = 5 * time.Second
+	probeClient, err := discovery.NewDiscoveryClientForConfig(probeCfg)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create API server probe client: %w", err)
+	}
+	if err := mgr.AddReadyzCheck("apiserver", health.APIServer(probeClient)); err != nil {
+		return nil, fmt.Errorf("unable to set up ready check: %w", err)
+	}
+	return mgr, nil
+}
@@ -0,0 +1,98 @@
+package plan
+
+import (
+	"sort"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// CountChanges returns the number of labels or taints added, changed and removed by the operations
+func CountChanges(operations []Operation) (int, int, int) {
+	added, changed, removed := 0, 0, 0
+	for _, op := range operations {
+		switch op.Change {
+		case ChangeAdded:
+			added++
+		case ChangeChanged:
+			changed++
+		case ChangeRemoved:
+			removed++
+		}
+	}
+	return added, changed, removed
+}
+
+// LabelChanges returns the labels added, changed or removed going from the actual to the expected labels,
+// sorted by key
+func LabelChanges(kind string, expected, actual map[string]string) []Operation {
+	var changes []Operation
+	for k, v := range expected {
+		actualValue, ok := actual[k]
+		if !ok {
+			changes = append(changes, newOperation(kind, MetadataLabel, k, nil, &v))
+		} else if actualValue != v {
+			changes = append(changes, newOperation(kind, MetadataLabel, k, &actualValue, &v))
+		}
+	}
+	for k, v := range actual {
+		if _, ok := expected[k]; !ok {
+			changes = append(changes, newOperation(kind, MetadataLabel, k, &v, nil))
+		}
+	}
+	sortOperations(changes)
+	return changes
+}
+
+// TaintChanges returns the taints added, changed or removed going from the actual to the expected taints, sorted
+// by key. Taints are identified by their key and effect.
+func TaintChanges(kind string, expected, actual []corev1.Taint) []Operation {
+	var changes []Operation
+	for i := range expected {
+		found := false
+		for j := range actual {
+			if expected[i].MatchTaint(&actual[j]) {
+				found = true
+				if expected[i].Value != actual[j].Value {
+					changes = append(changes, newOperation(kind, MetadataTaint, TaintKey(&expected[i]), &actual[j].Value, &expected[i].Value))
+				}
+				break
+			}
+		}
+		if !found {
+			changes = append(changes, newOperation(kind, MetadataTaint, TaintKey(&expected[i]), nil, &expected[i].Value))
+		}
+	}
+	for i := range actual {
+		if !TaintExists(expected, &actual[i]) {
+			changes = append(changes, newOperation(kind, MetadataTaint, TaintKey(&actual[i]), &actual[i].Value, nil))
+		}
+	}
+	sortOperations(changes)
+	return changes
+}
+
+// newOperation returns the change of the key from the old to the new value
+func newOperation(kind string, metadata string, key string, oldValue, newValue *string) Operation {
+	op := Operation{Kind: kind, Metadata: metadata, Key: key, OldValue: oldValue, NewValue: newValue}
+	switch {
+	case oldValue == nil:
+		op.Change = ChangeAdded
+	case newValue == nil:
+		op.Change = ChangeRemoved
+	default:
+		op.Change = ChangeChanged
+	}
+	return op
+}
+
+// TaintKey identifies the taint by its key and effect
+func TaintKey(taint *corev1.Taint) string {
+	return taint.Key + ":" + string(taint.Effect)
+}
+
+// sortOperations sorts the operations by key
+func sortOperations(operations []Operation) {
+	sort.SliceStable(operations, func(i, j int) bool {
+		return operations[i].Key < operations[j].Key
+	})
+}
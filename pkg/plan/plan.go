@@ -0,0 +1,375 @@
+// Package plan computes the label and taint changes a sync of a machine set makes to its machines and nodes,
+// without reading from or writing to the cluster. The reconciler applies the plan, while the sync state endpoint,
+// the drift metrics and mnmoctl report it.
+package plan
+
+import (
+	"strings"
+
+	machinev1beta1 "github.com/openshift/api/machine/v1beta1"
+	m "github.com/openshift/managed-node-metadata-operator/pkg/machine"
+	"github.com/openshift/managed-node-metadata-operator/pkg/reasons"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// CustomLabelsAnnotation records on a node the keys of the labels set by the operator
+const CustomLabelsAnnotation = "managed.openshift.com/customlabels"
+
+// nodeTaintPrefix is the prefix of the taints the kubelet and the node lifecycle controller manage on the nodes
+const nodeTaintPrefix = "node.kubernetes.io/"
+
+// Kinds of the objects changed by an operation, the kind label of the metrics
+const (
+	KindMachine = "Machine"
+	KindNode    = "Node"
+)
+
+// Metadata changed by an operation, the metadata label of the metrics
+const (
+	MetadataLabel = "label"
+	MetadataTaint = "taint"
+)
+
+// Changes of an operation, the added, changed and removed ones are the change label of the metrics
+const (
+	ChangeAdded   = "added"
+	ChangeChanged = "changed"
+	ChangeRemoved = "removed"
+	// ChangeSkipped is a label or taint of the template the sync leaves alone
+	ChangeSkipped = "skipped"
+)
+
+// Why a machine, label or taint is skipped, next to the error reasons of pkg/reasons
+const (
+	// ReasonMachineSelectorOverlap means the machine is selected by several machine sets
+	ReasonMachineSelectorOverlap = string(reasons.ReasonMachineSelectorOverlap)
+	// ReasonMachineFailed means the machine is in the Failed phase
+	ReasonMachineFailed = "MachineFailed"
+	// ReasonNoNode means the machine has no node yet
+	ReasonNoNode = "NoNode"
+	// ReasonReservedKey means the label is reserved and never managed
+	ReasonReservedKey = string(reasons.ReasonReservedKey)
+	// ReasonLabelConflict means the label is already set on the node by someone else
+	ReasonLabelConflict = "LabelConflict"
+	// ReasonNodeNotReady means the NoExecute taint is held back until the node is Ready
+	ReasonNodeNotReady = "NodeNotReady"
+)
+
+// Operation is the change of a single label or taint of a machine or node
+type Operation struct {
+	// Kind is the kind of the changed object, Machine or Node
+	Kind string `json:"kind"`
+	// Metadata is label or taint
+	Metadata string `json:"metadata"`
+	// Key is the label key, or the taint key and effect as key:effect
+	Key string `json:"key"`
+	// Change is added, changed, removed or skipped
+	Change string `json:"change"`
+	// OldValue is nil when the key is added
+	OldValue *string `json:"oldValue"`
+	// NewValue is nil when the key is removed
+	NewValue *string `json:"newValue"`
+	// Reason is why the operation is skipped
+	Reason string `json:"reason,omitempty"`
+}
+
+// Plan is the operations a sync of a machine set makes to its machines and nodes
+type Plan struct {
+	Provider  string        `json:"provider"`
+	Namespace string        `json:"namespace"`
+	Name      string        `json:"name"`
+	Machines  []MachinePlan `json:"machines"`
+}
+
+// Synced tells if the machines and their nodes already have the labels and taints of the template, so the sync
+// changes nothing. Machines without a node yet aren't synced, the other skipped machines are left out.
+func (p Plan) Synced() bool {
+	for _, machinePlan := range p.Machines {
+		switch machinePlan.Skipped {
+		case ReasonNoNode:
+			return false
+		case "":
+			machineInSync := len(machinePlan.Changes(KindMachine, MetadataLabel)) == 0 &&
+				len(machinePlan.Changes(KindMachine, MetadataTaint)) == 0
+			if !machineInSync || !machinePlan.NodeInSync() || len(machinePlan.HeldTaints) > 0 {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// MachinePlan is the operations a sync makes to a machine and its node, along with the metadata they lead to
+type MachinePlan struct {
+	Name string `json:"name"`
+	Node string `json:"node,omitempty"`
+	// Skipped is why the machine isn't synced, if it isn't
+	Skipped    string      `json:"skipped,omitempty"`
+	Operations []Operation `json:"changes"`
+
+	// Labels are the labels the Machine API machine spec and the node get from the template
+	Labels map[string]string `json:"-"`
+	// Taints are the taints the Machine API machine spec gets from the template, without the held back ones
+	Taints []corev1.Taint `json:"-"`
+	// NodeTaints are all the taints the node gets
+	NodeTaints []corev1.Taint `json:"-"`
+	// HeldTaints are the NoExecute taints held back until the node is Ready
+	HeldTaints []corev1.Taint `json:"-"`
+	// DuplicateTaintErr is a DuplicateTaintError if the template has the same taint several times, the node gets
+	// it once
+	DuplicateTaintErr error `json:"-"`
+}
+
+// Changes returns the operations on the metadata of the kind of object that aren't skipped
+func (p MachinePlan) Changes(kind string, metadata string) []Operation {
+	var changes []Operation
+	for _, op := range p.Operations {
+		if op.Kind == kind && op.Metadata == metadata && op.Change != ChangeSkipped {
+			changes = append(changes, op)
+		}
+	}
+	return changes
+}
+
+// Skips returns the operations skipped for the reason
+func (p MachinePlan) Skips(reason string) []Operation {
+	var skips []Operation
+	for _, op := range p.Operations {
+		if op.Change == ChangeSkipped && op.Reason == reason {
+			skips = append(skips, op)
+		}
+	}
+	return skips
+}
+
+// Adoptable returns the labels skipped because they're already set on the node by someone else, but to the value of
+// the template. Recording them as set by the operator brings them under its ownership without changing the node.
+func (p MachinePlan) Adoptable() []Operation {
+	var adoptable []Operation
+	for _, op := range p.Skips(ReasonLabelConflict) {
+		if op.OldValue != nil && op.NewValue != nil && *op.OldValue == *op.NewValue {
+			adoptable = append(adoptable, op)
+		}
+	}
+	return adoptable
+}
+
+// NodeInSync returns true if the plan doesn't change the labels or taints of the node
+func (p MachinePlan) NodeInSync() bool {
+	return len(p.Changes(KindNode, MetadataLabel)) == 0 && len(p.Changes(KindNode, MetadataTaint)) == 0
+}
+
+// Planner plans the syncs of machine sets
+type Planner struct {
+	// Provider reads the labels and taints of the machine set templates, it defaults to the Machine API
+	Provider m.Provider
+	// ReservedLabels are node label keys that are never changed or removed
+	ReservedLabels []string
+	// ReservedTaints are node taints that are never removed
+	ReservedTaints []corev1.Taint
+}
+
+// Plan returns the operations a sync of the machine set makes to its machines and their nodes, keyed by name.
+// Machines selected by other machine sets, as listed in overlaps, failed machines and machines without a node are
+// skipped.
+func (p Planner) Plan(machineSet client.Object, machines []client.Object, nodes map[string]*corev1.Node, overlaps map[string][]string) *Plan {
+	desiredLabels := p.provider().DesiredLabels(machineSet)
+	desiredTaints := p.provider().DesiredTaints(machineSet)
+	plan := &Plan{
+		Provider:  p.provider().Name(),
+		Namespace: machineSet.GetNamespace(),
+		Name:      machineSet.GetName(),
+		Machines:  []MachinePlan{},
+	}
+	for _, machine := range machines {
+		machinePlan := MachinePlan{Name: machine.GetName(), Node: m.GetNodeName(machine), Operations: []Operation{}}
+		node := nodes[machinePlan.Node]
+		machinePlan.Skipped = SkipReason(machine, overlaps)
+		switch {
+		case machinePlan.Skipped != "":
+		case node == nil:
+			machinePlan.Skipped = ReasonNoNode
+		default:
+			machinePlan = p.PlanMachine(desiredLabels, desiredTaints, machine, node)
+		}
+		plan.Machines = append(plan.Machines, machinePlan)
+	}
+	return plan
+}
+
+// SkipReason returns why the machine isn't synced whatever its node, if it isn't: it's selected by other machine
+// sets, as listed in overlaps, or it failed. Their nodes don't need to be fetched.
+func SkipReason(machine client.Object, overlaps map[string][]string) string {
+	switch {
+	case len(overlaps[machine.GetName()]) > 0:
+		return ReasonMachineSelectorOverlap
+	case m.GetMachinePhase(machine) == m.MachinePhaseFailed:
+		return ReasonMachineFailed
+	}
+	return ""
+}
+
+// PlanMachine returns the operations a sync makes to the machine and its node for the labels and taints of the
+// template. Nodes that aren't Ready get their labels, but NoExecute taints are held back until they're Ready, so
+// the workloads already scheduled on them aren't evicted while they come up.
+func (p Planner) PlanMachine(desiredLabels map[string]string, desiredTaints []corev1.Taint, machine client.Object, node *corev1.Node) MachinePlan {
+	plan := MachinePlan{Name: machine.GetName(), Node: node.Name, Taints: desiredTaints}
+	plan.Labels = p.expectedLabels(desiredLabels, machine, node)
+	if p.provider().SyncsTaints() {
+		if !m.IsNodeReady(node) {
+			plan.Taints, plan.HeldTaints = holdNoExecuteTaints(desiredTaints, node)
+		}
+		plan.NodeTaints, plan.DuplicateTaintErr = p.expectedNodeTaints(plan.Taints, node)
+	} else {
+		plan.NodeTaints = node.Spec.Taints
+	}
+
+	var operations []Operation
+	// Machine API machines carry the node labels and taints in their spec
+	if mapiMachine, ok := machine.(*machinev1beta1.Machine); ok {
+		operations = append(operations, LabelChanges(KindMachine, plan.Labels, mapiMachine.Spec.Labels)...)
+		operations = append(operations, TaintChanges(KindMachine, plan.Taints, mapiMachine.Spec.Taints)...)
+	}
+	operations = append(operations, LabelChanges(KindNode, plan.Labels, p.ManagedNodeLabels(node))...)
+	operations = append(operations, TaintChanges(KindNode, plan.NodeTaints, node.Spec.Taints)...)
+
+	var skipped []Operation
+	for key, value := range desiredLabels {
+		if _, ok := plan.Labels[key]; ok {
+			continue
+		}
+		op := Operation{Kind: KindNode, Metadata: MetadataLabel, Key: key, Change: ChangeSkipped, NewValue: &value, Reason: ReasonLabelConflict}
+		if p.IsReservedLabel(key) {
+			op.Reason = ReasonReservedKey
+		}
+		if nodeValue, ok := node.Labels[key]; ok {
+			op.OldValue = &nodeValue
+		}
+		skipped = append(skipped, op)
+	}
+	for i := range plan.HeldTaints {
+		skipped = append(skipped, Operation{
+			Kind:     KindNode,
+			Metadata: MetadataTaint,
+			Key:      TaintKey(&plan.HeldTaints[i]),
+			Change:   ChangeSkipped,
+			NewValue: &plan.HeldTaints[i].Value,
+			Reason:   ReasonNodeNotReady,
+		})
+	}
+	sortOperations(skipped)
+	plan.Operations = append(append([]Operation{}, operations...), skipped...)
+	return plan
+}
+
+// ManagedNodeLabels returns the labels of the node that were set by the operator, as recorded in its
+// custom labels annotation
+func (p Planner) ManagedNodeLabels(node *corev1.Node) map[string]string {
+	managedLabels := map[string]string{}
+	currentAnnotationValue, ok := node.Annotations[CustomLabelsAnnotation]
+	if !ok {
+		return managedLabels
+	}
+	for _, lk := range strings.Split(currentAnnotationValue, ",") {
+		if p.IsReservedLabel(lk) {
+			continue
+		}
+		if lv, nodeHasLabel := node.Labels[lk]; nodeHasLabel {
+			managedLabels[lk] = lv
+		}
+	}
+	return managedLabels
+}
+
+// IsReservedLabel returns true if the label key must not be managed
+func (p Planner) IsReservedLabel(key string) bool {
+	for _, reserved := range p.ReservedLabels {
+		if reserved == key {
+			return true
+		}
+	}
+	return false
+}
+
+// expectedLabels returns the labels of the template the node gets. Labels that are already set on the node, but
+// weren't set by the machine or the operator, are left out to avoid overwriting them.
+func (p Planner) expectedLabels(desiredLabels map[string]string, machine client.Object, node *corev1.Node) map[string]string {
+	// Copy the desired labels, they're shared by all machines of the machineset
+	var result map[string]string
+	if desiredLabels != nil {
+		result = make(map[string]string, len(desiredLabels))
+		for k, v := range desiredLabels {
+			result[k] = v
+		}
+	}
+
+	var machineLabels map[string]string
+	if mapiMachine, ok := machine.(*machinev1beta1.Machine); ok {
+		machineLabels = mapiMachine.Spec.Labels
+	}
+
+	setByOperator := map[string]bool{}
+	for _, lk := range strings.Split(node.Annotations[CustomLabelsAnnotation], ",") {
+		setByOperator[lk] = true
+	}
+	for label := range desiredLabels {
+		if p.IsReservedLabel(label) {
+			delete(result, label)
+			continue
+		}
+		_, nodeHasLabel := node.Labels[label]
+		_, machineHasLabel := machineLabels[label]
+		if nodeHasLabel && !machineHasLabel && !setByOperator[label] {
+			delete(result, label)
+		}
+	}
+	return result
+}
+
+// expectedNodeTaints returns the taints the node should have: the desired taints, without duplicates, as well as the
+// node.kubernetes.io taints and reserved taints already on the node. The node.kubernetes.io taints, such as
+// unschedulable, not-ready and unreachable, are managed by the kubelet and the node lifecycle controller. A
+// DuplicateTaintError is returned along with the taints if the desired taints have duplicates.
+func (p Planner) expectedNodeTaints(desiredTaints []corev1.Taint, node *corev1.Node) ([]corev1.Taint, error) {
+	expectedTaints, duplicateTaintErr := CheckDuplicateTaints(desiredTaints)
+	for _, taint := range node.Spec.Taints {
+		if TaintExists(expectedTaints, &taint) {
+			continue
+		}
+		if strings.HasPrefix(taint.Key, nodeTaintPrefix) || TaintExists(p.ReservedTaints, &taint) {
+			expectedTaints = append(expectedTaints, taint)
+		}
+	}
+	return expectedTaints, duplicateTaintErr
+}
+
+// holdNoExecuteTaints splits the desired taints of a node that isn't Ready into the taints to apply and the
+// NoExecute taints to hold back. NoExecute taints that are already on the node are kept.
+func holdNoExecuteTaints(desiredTaints []corev1.Taint, node *corev1.Node) ([]corev1.Taint, []corev1.Taint) {
+	var heldTaints []corev1.Taint
+	for i := range desiredTaints {
+		if desiredTaints[i].Effect == corev1.TaintEffectNoExecute && !TaintExists(node.Spec.Taints, &desiredTaints[i]) {
+			heldTaints = append(heldTaints, desiredTaints[i])
+		}
+	}
+	if len(heldTaints) == 0 {
+		return desiredTaints, nil
+	}
+
+	taints := []corev1.Taint{}
+	for i := range desiredTaints {
+		if !TaintExists(heldTaints, &desiredTaints[i]) {
+			taints = append(taints, desiredTaints[i])
+		}
+	}
+	return taints, heldTaints
+}
+
+// provider returns the configured Provider, or the Machine API provider if none is set
+func (p Planner) provider() m.Provider {
+	if p.Provider == nil {
+		return m.MachineAPIProvider{}
+	}
+	return p.Provider
+}
@@ -0,0 +1,232 @@
+package plan
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	machinev1beta1 "github.com/openshift/api/machine/v1beta1"
+	m "github.com/openshift/managed-node-metadata-operator/pkg/machine"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+var _ = Describe("Planner", func() {
+	var (
+		planner       Planner
+		desiredLabels map[string]string
+		desiredTaints []corev1.Taint
+		machine       *machinev1beta1.Machine
+		node          *corev1.Node
+	)
+
+	operations := func(machinePlan MachinePlan) map[string]Operation {
+		result := map[string]Operation{}
+		for _, op := range machinePlan.Operations {
+			result[op.Kind+"/"+op.Metadata+"/"+op.Key] = op
+		}
+		return result
+	}
+
+	BeforeEach(func() {
+		planner = Planner{ReservedLabels: []string{"node-role.kubernetes.io/infra"}}
+		desiredLabels = map[string]string{"foo": "bar", "team": "payments", "node-role.kubernetes.io/infra": ""}
+		desiredTaints = []corev1.Taint{{Key: "dedicated", Value: "infra", Effect: corev1.TaintEffectNoExecute}}
+		machine = &machinev1beta1.Machine{
+			ObjectMeta: metav1.ObjectMeta{Name: "test-machine", Namespace: "test"},
+			Spec: machinev1beta1.MachineSpec{
+				ObjectMeta: machinev1beta1.ObjectMeta{Labels: map[string]string{"foo": "old", "stale": "value"}},
+			},
+			Status: machinev1beta1.MachineStatus{NodeRef: &corev1.ObjectReference{Name: "test-node"}},
+		}
+		node = &corev1.Node{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        "test-node",
+				Labels:      map[string]string{"foo": "old", "stale": "value", "team": "platform"},
+				Annotations: map[string]string{CustomLabelsAnnotation: "foo,stale"},
+			},
+			Spec: corev1.NodeSpec{Taints: []corev1.Taint{
+				{Key: corev1.TaintNodeUnschedulable, Effect: corev1.TaintEffectNoSchedule},
+				{Key: "other", Value: "true", Effect: corev1.TaintEffectNoSchedule},
+			}},
+			Status: corev1.NodeStatus{Conditions: []corev1.NodeCondition{
+				{Type: corev1.NodeReady, Status: corev1.ConditionTrue},
+			}},
+		}
+	})
+
+	Describe("PlanMachine", func() {
+		It("should plan the operations on the machine and its node", func() {
+			machinePlan := planner.PlanMachine(desiredLabels, desiredTaints, machine, node)
+			Expect(machinePlan.Labels).To(Equal(map[string]string{"foo": "bar"}))
+			Expect(machinePlan.Taints).To(Equal(desiredTaints))
+			Expect(machinePlan.NodeInSync()).To(BeFalse())
+
+			ops := operations(machinePlan)
+			Expect(ops).To(HaveLen(9))
+			Expect(ops["Machine/label/foo"].Change).To(Equal(ChangeChanged))
+			Expect(ops["Machine/label/stale"].Change).To(Equal(ChangeRemoved))
+			Expect(ops["Machine/taint/dedicated:NoExecute"].Change).To(Equal(ChangeAdded))
+			Expect(ops["Node/label/foo"].Change).To(Equal(ChangeChanged))
+			Expect(ops["Node/label/stale"].Change).To(Equal(ChangeRemoved))
+			Expect(ops["Node/taint/dedicated:NoExecute"].Change).To(Equal(ChangeAdded))
+			Expect(ops["Node/taint/other:NoSchedule"].Change).To(Equal(ChangeRemoved))
+			Expect(ops["Node/label/team"].Reason).To(Equal(ReasonLabelConflict))
+			Expect(ops["Node/label/node-role.kubernetes.io/infra"].Reason).To(Equal(ReasonReservedKey))
+			Expect(machinePlan.Skips(ReasonLabelConflict)).To(HaveLen(1))
+		})
+
+		It("should find the labels set by someone else to the template value", func() {
+			Expect(planner.PlanMachine(desiredLabels, desiredTaints, machine, node).Adoptable()).To(BeEmpty())
+
+			node.Labels["team"] = "payments"
+			adoptable := planner.PlanMachine(desiredLabels, desiredTaints, machine, node).Adoptable()
+			Expect(adoptable).To(HaveLen(1))
+			Expect(adoptable[0].Key).To(Equal("team"))
+		})
+
+		It("should keep the node.kubernetes.io and reserved taints of the node", func() {
+			planner.ReservedTaints = []corev1.Taint{{Key: "other", Effect: corev1.TaintEffectNoSchedule}}
+			machinePlan := planner.PlanMachine(desiredLabels, desiredTaints, machine, node)
+			Expect(machinePlan.NodeTaints).To(ConsistOf(append(desiredTaints, node.Spec.Taints...)))
+			Expect(machinePlan.Changes(KindNode, MetadataTaint)).To(HaveLen(1))
+		})
+
+		It("should plan taint value changes", func() {
+			node.Spec.Taints = []corev1.Taint{{Key: "dedicated", Value: "old", Effect: corev1.TaintEffectNoExecute}}
+			changes := planner.PlanMachine(desiredLabels, desiredTaints, machine, node).Changes(KindNode, MetadataTaint)
+			Expect(changes).To(HaveLen(1))
+			Expect(changes[0].Change).To(Equal(ChangeChanged))
+			Expect(*changes[0].OldValue).To(Equal("old"))
+		})
+
+		It("should plan the template taints once", func() {
+			desiredTaints = append(desiredTaints, desiredTaints[0])
+			machinePlan := planner.PlanMachine(desiredLabels, desiredTaints, machine, node)
+			Expect(machinePlan.DuplicateTaintErr).To(BeAssignableToTypeOf(DuplicateTaintError{}))
+			Expect(machinePlan.NodeTaints).To(HaveLen(2))
+		})
+
+		Context("When the node isn't ready", func() {
+			BeforeEach(func() {
+				node.Status.Conditions[0].Status = corev1.ConditionFalse
+			})
+
+			It("should hold back the NoExecute taints", func() {
+				machinePlan := planner.PlanMachine(desiredLabels, desiredTaints, machine, node)
+				Expect(machinePlan.HeldTaints).To(Equal(desiredTaints))
+				Expect(machinePlan.Taints).To(BeEmpty())
+				Expect(machinePlan.Changes(KindNode, MetadataTaint)).To(HaveLen(1))
+				Expect(machinePlan.Skips(ReasonNodeNotReady)).To(HaveLen(1))
+				Expect(machinePlan.Changes(KindNode, MetadataLabel)).NotTo(BeEmpty())
+			})
+
+			It("should keep the not-ready taint of the node", func() {
+				node.Spec.Taints = []corev1.Taint{
+					{Key: corev1.TaintNodeNotReady, Effect: corev1.TaintEffectNoSchedule},
+					{Key: corev1.TaintNodeUnreachable, Effect: corev1.TaintEffectNoExecute},
+				}
+				machinePlan := planner.PlanMachine(desiredLabels, desiredTaints, machine, node)
+				Expect(machinePlan.NodeTaints).To(ConsistOf(node.Spec.Taints))
+				Expect(machinePlan.Changes(KindNode, MetadataTaint)).To(BeEmpty())
+			})
+
+			It("should keep the NoExecute taints already on the node", func() {
+				node.Spec.Taints = desiredTaints
+				machinePlan := planner.PlanMachine(desiredLabels, desiredTaints, machine, node)
+				Expect(machinePlan.HeldTaints).To(BeEmpty())
+				Expect(machinePlan.Changes(KindNode, MetadataTaint)).To(BeEmpty())
+			})
+		})
+
+		It("should leave a node in sync alone", func() {
+			machine.Spec.Labels = map[string]string{"foo": "bar"}
+			machine.Spec.Taints = desiredTaints
+			node.Labels = map[string]string{"foo": "bar"}
+			node.Spec.Taints = desiredTaints
+			machinePlan := planner.PlanMachine(map[string]string{"foo": "bar"}, desiredTaints, machine, node)
+			Expect(machinePlan.NodeInSync()).To(BeTrue())
+			Expect(machinePlan.Operations).To(BeEmpty())
+		})
+
+		Context("When the provider doesn't sync taints", func() {
+			BeforeEach(func() {
+				planner.Provider = m.ClusterAPIProvider{Version: "v1beta1", Kind: m.ClusterAPIMachineSetKind}
+			})
+
+			It("should leave the node taints alone", func() {
+				machinePlan := planner.PlanMachine(desiredLabels, nil, machine, node)
+				Expect(machinePlan.NodeTaints).To(Equal(node.Spec.Taints))
+				Expect(machinePlan.Changes(KindNode, MetadataTaint)).To(BeEmpty())
+				Expect(machinePlan.Changes(KindNode, MetadataLabel)).NotTo(BeEmpty())
+			})
+		})
+	})
+
+	Describe("Plan", func() {
+		var machineSet *machinev1beta1.MachineSet
+
+		BeforeEach(func() {
+			machineSet = &machinev1beta1.MachineSet{
+				ObjectMeta: metav1.ObjectMeta{Name: "test-machineset", Namespace: "test"},
+				Spec: machinev1beta1.MachineSetSpec{
+					Template: machinev1beta1.MachineTemplateSpec{
+						Spec: machinev1beta1.MachineSpec{
+							ObjectMeta: machinev1beta1.ObjectMeta{Labels: desiredLabels},
+							Taints:     desiredTaints,
+						},
+					},
+				},
+			}
+		})
+
+		It("should plan the machines and say why the others are skipped", func() {
+			failed := machine.DeepCopy()
+			failed.Name = "failed-machine"
+			phase := "Failed"
+			failed.Status.Phase = &phase
+			contested := machine.DeepCopy()
+			contested.Name = "contested-machine"
+			provisioning := machine.DeepCopy()
+			provisioning.Name = "provisioning-machine"
+			provisioning.Status.NodeRef = nil
+
+			plan := planner.Plan(machineSet, []client.Object{machine, failed, contested, provisioning},
+				map[string]*corev1.Node{node.Name: node}, map[string][]string{"contested-machine": {"other-machineset"}})
+			Expect(plan.Name).To(Equal("test-machineset"))
+			Expect(plan.Provider).To(Equal("machineset"))
+
+			skipped := map[string]string{}
+			for _, machinePlan := range plan.Machines {
+				skipped[machinePlan.Name] = machinePlan.Skipped
+			}
+			Expect(skipped).To(Equal(map[string]string{
+				"test-machine":         "",
+				"failed-machine":       ReasonMachineFailed,
+				"contested-machine":    ReasonMachineSelectorOverlap,
+				"provisioning-machine": ReasonNoNode,
+			}))
+			Expect(plan.Machines[0].Operations).NotTo(BeEmpty())
+			Expect(plan.Machines[1].Operations).To(BeEmpty())
+			Expect(plan.Synced()).To(BeFalse())
+		})
+
+		It("should be synced once the machines with a node match the template", func() {
+			machineSet.Spec.Template.Spec = machinev1beta1.MachineSpec{}
+			machine.Spec.Labels = nil
+			node.Annotations = nil
+			node.Spec.Taints = nil
+			failed := machine.DeepCopy()
+			failed.Name = "failed-machine"
+			phase := "Failed"
+			failed.Status.Phase = &phase
+			nodes := map[string]*corev1.Node{node.Name: node}
+			Expect(planner.Plan(machineSet, []client.Object{machine, failed}, nodes, nil).Synced()).To(BeTrue())
+
+			provisioning := machine.DeepCopy()
+			provisioning.Name = "provisioning-machine"
+			provisioning.Status.NodeRef = nil
+			Expect(planner.Plan(machineSet, []client.Object{machine, provisioning}, nodes, nil).Synced()).To(BeFalse())
+		})
+	})
+})
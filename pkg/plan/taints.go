@@ -0,0 +1,72 @@
+package plan
+
+import (
+	"fmt"
+
+	"github.com/openshift/managed-node-metadata-operator/pkg/reasons"
+	corev1 "k8s.io/api/core/v1"
+)
+
+type DuplicateTaintError struct {
+	Message string
+}
+
+func (d DuplicateTaintError) Error() string {
+	return d.Message
+}
+
+// Reason classifies duplicate taints for logs, events, metrics and status
+func (d DuplicateTaintError) Reason() reasons.Reason {
+	return reasons.ReasonDuplicateTaint
+}
+
+func CheckDuplicateTaints(taints []corev1.Taint) ([]corev1.Taint, error) {
+	var err error = nil
+	tmpTaints := make(map[corev1.Taint]bool, len(taints))
+	uniqueTaints := make([]corev1.Taint, 0)
+	for _, taint := range taints {
+		if _, value := tmpTaints[taint]; !value {
+			tmpTaints[taint] = true
+			uniqueTaints = append(uniqueTaints, taint)
+		} else {
+			err = DuplicateTaintError{Message: fmt.Sprintf("duplicate taint in machine spec found - will be ignored: %v", taint)}
+		}
+	}
+	return uniqueTaints, err
+}
+
+// TaintExists checks if the given taint exists in list of taints. Returns true if exists false otherwise.
+func TaintExists(taints []corev1.Taint, taintToFind *corev1.Taint) bool {
+	for _, taint := range taints {
+		if taint.MatchTaint(taintToFind) {
+			return true
+		}
+	}
+	return false
+}
+
+// TaintSliceDiff finds the difference between two taint slices and
+// returns all new and removed elements of the new slice relative to the old slice.
+// for example:
+// input: expected=[a b] actual=[a c]
+// output: taintsToAdd=[b] taintsToRemove=[c]
+func TaintSliceDiff(expected, actual []corev1.Taint) ([]*corev1.Taint, []*corev1.Taint) {
+	var (
+		taintsToAdd    []*corev1.Taint
+		taintsToRemove []*corev1.Taint
+	)
+
+	for i := range expected {
+		if !TaintExists(actual, &expected[i]) {
+			taintsToAdd = append(taintsToAdd, &expected[i])
+		}
+	}
+
+	for i := range actual {
+		if !TaintExists(expected, &actual[i]) {
+			taintsToRemove = append(taintsToRemove, &actual[i])
+		}
+	}
+
+	return taintsToAdd, taintsToRemove
+}
@@ -0,0 +1,183 @@
+package plan
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+var _ = Describe("Taints", func() {
+	Describe("CheckDuplicateTaints", func() {
+		Context("When taints are unique", func() {
+			It("should return all taints without error", func() {
+				taints := []corev1.Taint{
+					{Key: "key1", Value: "value1", Effect: corev1.TaintEffectNoSchedule},
+					{Key: "key2", Value: "value2", Effect: corev1.TaintEffectPreferNoSchedule},
+				}
+
+				result, err := CheckDuplicateTaints(taints)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(result).To(Equal(taints))
+			})
+		})
+
+		Context("When taints have duplicates", func() {
+			It("should return unique taints and error", func() {
+				taints := []corev1.Taint{
+					{Key: "key1", Value: "value1", Effect: corev1.TaintEffectNoSchedule},
+					{Key: "key1", Value: "value1", Effect: corev1.TaintEffectNoSchedule},
+					{Key: "key2", Value: "value2", Effect: corev1.TaintEffectPreferNoSchedule},
+				}
+
+				result, err := CheckDuplicateTaints(taints)
+				Expect(err).To(HaveOccurred())
+				Expect(err).To(BeAssignableToTypeOf(DuplicateTaintError{}))
+				Expect(len(result)).To(Equal(2))
+				Expect(result[0]).To(Equal(taints[0]))
+				Expect(result[1]).To(Equal(taints[2]))
+			})
+		})
+
+		Context("When taints slice is empty", func() {
+			It("should return empty slice without error", func() {
+				taints := []corev1.Taint{}
+
+				result, err := CheckDuplicateTaints(taints)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(result).To(BeEmpty())
+			})
+		})
+	})
+
+	Describe("TaintExists", func() {
+		Context("When taint exists in slice", func() {
+			It("should return true", func() {
+				taints := []corev1.Taint{
+					{Key: "key1", Value: "value1", Effect: corev1.TaintEffectNoSchedule},
+					{Key: "key2", Value: "value2", Effect: corev1.TaintEffectPreferNoSchedule},
+				}
+				taintToFind := &corev1.Taint{Key: "key1", Value: "value1", Effect: corev1.TaintEffectNoSchedule}
+
+				result := TaintExists(taints, taintToFind)
+				Expect(result).To(BeTrue())
+			})
+		})
+
+		Context("When taint does not exist in slice", func() {
+			It("should return false", func() {
+				taints := []corev1.Taint{
+					{Key: "key1", Value: "value1", Effect: corev1.TaintEffectNoSchedule},
+				}
+				taintToFind := &corev1.Taint{Key: "key2", Value: "value2", Effect: corev1.TaintEffectNoSchedule}
+
+				result := TaintExists(taints, taintToFind)
+				Expect(result).To(BeFalse())
+			})
+		})
+
+		Context("When taint slice is empty", func() {
+			It("should return false", func() {
+				taints := []corev1.Taint{}
+				taintToFind := &corev1.Taint{Key: "key1", Value: "value1", Effect: corev1.TaintEffectNoSchedule}
+
+				result := TaintExists(taints, taintToFind)
+				Expect(result).To(BeFalse())
+			})
+		})
+	})
+
+	Describe("TaintSliceDiff", func() {
+		Context("When expected and actual are identical", func() {
+			It("should return empty slices", func() {
+				expected := []corev1.Taint{
+					{Key: "key1", Value: "value1", Effect: corev1.TaintEffectNoSchedule},
+				}
+				actual := []corev1.Taint{
+					{Key: "key1", Value: "value1", Effect: corev1.TaintEffectNoSchedule},
+				}
+
+				toAdd, toRemove := TaintSliceDiff(expected, actual)
+				Expect(toAdd).To(BeEmpty())
+				Expect(toRemove).To(BeEmpty())
+			})
+		})
+
+		Context("When expected has additional taints", func() {
+			It("should return taints to add", func() {
+				expected := []corev1.Taint{
+					{Key: "key1", Value: "value1", Effect: corev1.TaintEffectNoSchedule},
+					{Key: "key2", Value: "value2", Effect: corev1.TaintEffectNoSchedule},
+				}
+				actual := []corev1.Taint{
+					{Key: "key1", Value: "value1", Effect: corev1.TaintEffectNoSchedule},
+				}
+
+				toAdd, toRemove := TaintSliceDiff(expected, actual)
+				Expect(len(toAdd)).To(Equal(1))
+				Expect(toAdd[0]).To(Equal(&expected[1]))
+				Expect(toRemove).To(BeEmpty())
+			})
+		})
+
+		Context("When actual has additional taints", func() {
+			It("should return taints to remove", func() {
+				expected := []corev1.Taint{
+					{Key: "key1", Value: "value1", Effect: corev1.TaintEffectNoSchedule},
+				}
+				actual := []corev1.Taint{
+					{Key: "key1", Value: "value1", Effect: corev1.TaintEffectNoSchedule},
+					{Key: "key2", Value: "value2", Effect: corev1.TaintEffectNoSchedule},
+				}
+
+				toAdd, toRemove := TaintSliceDiff(expected, actual)
+				Expect(toAdd).To(BeEmpty())
+				Expect(len(toRemove)).To(Equal(1))
+				Expect(toRemove[0]).To(Equal(&actual[1]))
+			})
+		})
+
+		Context("When both have different taints", func() {
+			It("should return both taints to add and remove", func() {
+				expected := []corev1.Taint{
+					{Key: "key1", Value: "value1", Effect: corev1.TaintEffectNoSchedule},
+					{Key: "key3", Value: "value3", Effect: corev1.TaintEffectNoSchedule},
+				}
+				actual := []corev1.Taint{
+					{Key: "key2", Value: "value2", Effect: corev1.TaintEffectNoSchedule},
+					{Key: "key3", Value: "value3", Effect: corev1.TaintEffectNoSchedule},
+				}
+
+				toAdd, toRemove := TaintSliceDiff(expected, actual)
+				Expect(len(toAdd)).To(Equal(1))
+				Expect(toAdd[0]).To(Equal(&expected[0]))
+				Expect(len(toRemove)).To(Equal(1))
+				Expect(toRemove[0]).To(Equal(&actual[0]))
+			})
+		})
+	})
+})
+
+var _ = Describe("Changes", func() {
+	It("should count label changes", func() {
+		added, changed, removed := CountChanges(LabelChanges(KindNode,
+			map[string]string{"a": "1", "b": "2", "c": "3"},
+			map[string]string{"b": "2", "c": "old", "d": "4"},
+		))
+		Expect([]int{added, changed, removed}).To(Equal([]int{1, 1, 1}))
+	})
+
+	It("should count taint changes", func() {
+		added, changed, removed := CountChanges(TaintChanges(KindNode,
+			[]corev1.Taint{
+				{Key: "a", Value: "1", Effect: corev1.TaintEffectNoSchedule},
+				{Key: "b", Value: "new", Effect: corev1.TaintEffectNoSchedule},
+			},
+			[]corev1.Taint{
+				{Key: "b", Value: "old", Effect: corev1.TaintEffectNoSchedule},
+				{Key: "c", Value: "3", Effect: corev1.TaintEffectNoExecute},
+			},
+		))
+		Expect([]int{added, changed, removed}).To(Equal([]int{1, 1, 1}))
+	})
+})
@@ -0,0 +1,118 @@
+// Package reasons defines the reasons of the errors of the operator. The same reason is used in logs, events,
+// metric labels and status conditions, so an alert can point to a likely cause.
+package reasons
+
+import (
+	"errors"
+	"fmt"
+
+	k8serr "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Reason is the likely cause of an error
+type Reason string
+
+const (
+	// ReasonConflict means the object was changed by someone else in the meantime, the sync is retried
+	ReasonConflict Reason = "Conflict"
+	// ReasonForbidden means the operator isn't allowed to read or update the object
+	ReasonForbidden Reason = "Forbidden"
+	// ReasonNodeNotFound means the node referenced by a machine doesn't exist
+	ReasonNodeNotFound Reason = "NodeNotFound"
+	// ReasonNotFound means an object other than a node doesn't exist
+	ReasonNotFound Reason = "NotFound"
+	// ReasonInvalidSelector means the label selector of a machine set can't be parsed or can't match its machines
+	ReasonInvalidSelector Reason = "InvalidSelector"
+	// ReasonDuplicateTaint means the machine set template has the same taint several times
+	ReasonDuplicateTaint Reason = "DuplicateTaint"
+	// ReasonReservedKey means the machine set template sets a label or taint the operator must not manage
+	ReasonReservedKey Reason = "ReservedKey"
+	// ReasonWebhookDenied means an admission webhook denied the update of the object
+	ReasonWebhookDenied Reason = "WebhookDenied"
+	// ReasonMachineSelectorOverlap means a machine is selected by several machine sets
+	ReasonMachineSelectorOverlap Reason = "MachineSelectorOverlap"
+	// ReasonUnknown is used for errors that can't be classified
+	ReasonUnknown Reason = "Unknown"
+)
+
+// Error is an error with a known reason
+type Error struct {
+	reason Reason
+	err    error
+}
+
+// New returns an error with the given reason, the message is formatted like fmt.Errorf, so errors can be wrapped with %w
+func New(reason Reason, format string, args ...interface{}) error {
+	return &Error{reason: reason, err: fmt.Errorf(format, args...)}
+}
+
+func (e *Error) Error() string {
+	return e.err.Error()
+}
+
+func (e *Error) Unwrap() error {
+	return e.err
+}
+
+// Reason returns the reason of the error
+func (e *Error) Reason() Reason {
+	return e.reason
+}
+
+// ReasonFor classifies the error. Errors of the operator carry their reason, API errors are classified by their
+// status. An empty reason is returned for a nil error.
+func ReasonFor(err error) Reason {
+	if err == nil {
+		return ""
+	}
+
+	var reasoned interface{ Reason() Reason }
+	if errors.As(err, &reasoned) {
+		return reasoned.Reason()
+	}
+
+	switch {
+	case isWebhookDenied(err):
+		return ReasonWebhookDenied
+	case k8serr.IsConflict(err):
+		return ReasonConflict
+	case k8serr.IsForbidden(err):
+		return ReasonForbidden
+	case k8serr.IsNotFound(err):
+		if isNodeNotFound(err) {
+			return ReasonNodeNotFound
+		}
+		return ReasonNotFound
+	}
+	return ReasonUnknown
+}
+
+// isWebhookDenied returns true for API errors returned when an admission webhook denies a request. The API server
+// returns the status of the webhook response, which has no details, while its own errors about an object name it
+// in their details. Webhooks deny with a Forbidden or Invalid reason, or with none.
+func isWebhookDenied(err error) bool {
+	var status k8serr.APIStatus
+	if !errors.As(err, &status) {
+		return false
+	}
+	s := status.Status()
+	if s.Status != metav1.StatusFailure || s.Details != nil {
+		return false
+	}
+	switch s.Reason {
+	case "", metav1.StatusReasonForbidden, metav1.StatusReasonInvalid:
+		return true
+	}
+	return false
+}
+
+// isNodeNotFound returns true for NotFound API errors about nodes
+func isNodeNotFound(err error) bool {
+	var status k8serr.APIStatus
+	if !errors.As(err, &status) {
+		return false
+	}
+	details := status.Status().Details
+	return details != nil && details.Kind == "nodes"
+}
@@ -0,0 +1,86 @@
+package reasons
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	k8serr "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+var _ = Describe("Reasons", func() {
+	var (
+		nodes      = schema.GroupResource{Resource: "nodes"}
+		machines   = schema.GroupResource{Group: "machine.openshift.io", Resource: "machines"}
+		errExample = errors.New("example")
+	)
+
+	Describe("New", func() {
+		It("should keep the message and the wrapped error", func() {
+			err := New(ReasonInvalidSelector, "failed to parse selector: %w", errExample)
+			Expect(err.Error()).To(Equal("failed to parse selector: example"))
+			Expect(errors.Is(err, errExample)).To(BeTrue())
+		})
+	})
+
+	Describe("ReasonFor", func() {
+		It("should return no reason for a nil error", func() {
+			Expect(ReasonFor(nil)).To(BeEmpty())
+		})
+
+		It("should return the reason of errors of the operator, even when wrapped", func() {
+			err := fmt.Errorf("failed to sync: %w", New(ReasonDuplicateTaint, "duplicate taint"))
+			Expect(ReasonFor(err)).To(Equal(ReasonDuplicateTaint))
+		})
+
+		It("should classify conflicts", func() {
+			Expect(ReasonFor(k8serr.NewConflict(nodes, "node-1", errExample))).To(Equal(ReasonConflict))
+		})
+
+		It("should classify forbidden errors", func() {
+			err := fmt.Errorf("failed to update node: %w", k8serr.NewForbidden(nodes, "node-1", errExample))
+			Expect(ReasonFor(err)).To(Equal(ReasonForbidden))
+		})
+
+		It("should classify missing nodes", func() {
+			Expect(ReasonFor(k8serr.NewNotFound(nodes, "node-1"))).To(Equal(ReasonNodeNotFound))
+		})
+
+		It("should classify other missing objects", func() {
+			Expect(ReasonFor(k8serr.NewNotFound(machines, "machine-1"))).To(Equal(ReasonNotFound))
+		})
+
+		It("should classify webhook denials", func() {
+			err := &k8serr.StatusError{ErrStatus: metav1.Status{
+				Status:  metav1.StatusFailure,
+				Code:    http.StatusForbidden,
+				Reason:  metav1.StatusReasonForbidden,
+				Message: `admission webhook "example.openshift.io" denied the request: label is not allowed`,
+			}}
+			Expect(ReasonFor(err)).To(Equal(ReasonWebhookDenied))
+		})
+
+		It("should classify webhook denials without a reason", func() {
+			err := &k8serr.StatusError{ErrStatus: metav1.Status{
+				Status:  metav1.StatusFailure,
+				Code:    http.StatusBadRequest,
+				Message: `admission webhook "example.openshift.io" denied the request without explanation`,
+			}}
+			Expect(ReasonFor(err)).To(Equal(ReasonWebhookDenied))
+		})
+
+		It("should not classify errors of the API server about an object as webhook denials", func() {
+			err := k8serr.NewInvalid(schema.GroupKind{Kind: "Node"}, "node-1", nil)
+			Expect(ReasonFor(err)).To(Equal(ReasonUnknown))
+		})
+
+		It("should not classify unknown errors", func() {
+			Expect(ReasonFor(errExample)).To(Equal(ReasonUnknown))
+		})
+	})
+})
@@ -0,0 +1,96 @@
+package tracing
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+)
+
+// WrapClient returns a client creating a span for each API call, as a child of the span of the context
+func WrapClient(c client.Client) client.Client {
+	return &tracingClient{Client: c}
+}
+
+type tracingClient struct {
+	client.Client
+}
+
+func (c *tracingClient) Get(ctx context.Context, key client.ObjectKey, obj client.Object, opts ...client.GetOption) (err error) {
+	ctx, span := c.start(ctx, "Get", obj, key.Namespace, key.Name)
+	defer func() { End(span, err) }()
+	return c.Client.Get(ctx, key, obj, opts...)
+}
+
+func (c *tracingClient) List(ctx context.Context, list client.ObjectList, opts ...client.ListOption) (err error) {
+	listOpts := &client.ListOptions{}
+	listOpts.ApplyOptions(opts)
+	ctx, span := c.start(ctx, "List", list, listOpts.Namespace, "")
+	defer func() { End(span, err) }()
+	return c.Client.List(ctx, list, opts...)
+}
+
+func (c *tracingClient) Create(ctx context.Context, obj client.Object, opts ...client.CreateOption) (err error) {
+	ctx, span := c.start(ctx, "Create", obj, obj.GetNamespace(), obj.GetName())
+	defer func() { End(span, err) }()
+	return c.Client.Create(ctx, obj, opts...)
+}
+
+func (c *tracingClient) Update(ctx context.Context, obj client.Object, opts ...client.UpdateOption) (err error) {
+	ctx, span := c.start(ctx, "Update", obj, obj.GetNamespace(), obj.GetName())
+	defer func() { End(span, err) }()
+	return c.Client.Update(ctx, obj, opts...)
+}
+
+func (c *tracingClient) Patch(ctx context.Context, obj client.Object, patch client.Patch, opts ...client.PatchOption) (err error) {
+	ctx, span := c.start(ctx, "Patch", obj, obj.GetNamespace(), obj.GetName())
+	defer func() { End(span, err) }()
+	return c.Client.Patch(ctx, obj, patch, opts...)
+}
+
+func (c *tracingClient) Delete(ctx context.Context, obj client.Object, opts ...client.DeleteOption) (err error) {
+	ctx, span := c.start(ctx, "Delete", obj, obj.GetNamespace(), obj.GetName())
+	defer func() { End(span, err) }()
+	return c.Client.Delete(ctx, obj, opts...)
+}
+
+func (c *tracingClient) Status() client.SubResourceWriter {
+	return &tracingStatusWriter{SubResourceWriter: c.Client.Status(), client: c}
+}
+
+// start starts the span of an API call, named after the verb and the kind of the object
+func (c *tracingClient) start(ctx context.Context, verb string, obj runtime.Object, namespace, name string) (context.Context, trace.Span) {
+	kind := ""
+	if gvk, err := apiutil.GVKForObject(obj, c.Scheme()); err == nil {
+		kind = gvk.Kind
+	}
+	attrs := []attribute.KeyValue{VerbKey.String(verb), KindKey.String(kind)}
+	if namespace != "" {
+		attrs = append(attrs, NamespaceKey.String(namespace))
+	}
+	if name != "" {
+		attrs = append(attrs, NameKey.String(name))
+	}
+	return Start(ctx, verb+" "+kind, attrs...)
+}
+
+// tracingStatusWriter creates a span for each update of the status of an object
+type tracingStatusWriter struct {
+	client.SubResourceWriter
+	client *tracingClient
+}
+
+func (w *tracingStatusWriter) Update(ctx context.Context, obj client.Object, opts ...client.SubResourceUpdateOption) (err error) {
+	ctx, span := w.client.start(ctx, "UpdateStatus", obj, obj.GetNamespace(), obj.GetName())
+	defer func() { End(span, err) }()
+	return w.SubResourceWriter.Update(ctx, obj, opts...)
+}
+
+func (w *tracingStatusWriter) Patch(ctx context.Context, obj client.Object, patch client.Patch, opts ...client.SubResourcePatchOption) (err error) {
+	ctx, span := w.client.start(ctx, "PatchStatus", obj, obj.GetNamespace(), obj.GetName())
+	defer func() { End(span, err) }()
+	return w.SubResourceWriter.Patch(ctx, obj, patch, opts...)
+}
@@ -0,0 +1,136 @@
+// Package tracing sets up the OpenTelemetry tracing of the operator. Tracing is disabled by default, the
+// spans created through Tracer are then dropped by the no-op global tracer provider.
+package tracing
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/openshift/managed-node-metadata-operator/pkg/reasons"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	// ExporterNone disables tracing
+	ExporterNone = "none"
+	// ExporterOTLP exports the spans to an OTLP/HTTP collector
+	ExporterOTLP = "otlp"
+	// ExporterStdout writes the spans to stdout, for local debugging
+	ExporterStdout = "stdout"
+	// ExporterFile writes the spans to a file, for local debugging
+	ExporterFile = "file"
+
+	serviceName = "managed-node-metadata-operator"
+	tracerName  = "github.com/openshift/managed-node-metadata-operator"
+)
+
+// Attributes set on the spans of the operator
+const (
+	MachineSetKey = attribute.Key("mnmo.machineset")
+	ProviderKey   = attribute.Key("mnmo.provider")
+	MachineKey    = attribute.Key("mnmo.machine")
+	NodeKey       = attribute.Key("mnmo.node")
+	MachinesKey   = attribute.Key("mnmo.machines")
+	ReasonKey     = attribute.Key("mnmo.reason")
+	VerbKey       = attribute.Key("k8s.verb")
+	KindKey       = attribute.Key("k8s.kind")
+	NamespaceKey  = attribute.Key("k8s.namespace")
+	NameKey       = attribute.Key("k8s.name")
+)
+
+// Options configures the exporter of the spans
+type Options struct {
+	// Exporter is one of none, otlp, stdout or file
+	Exporter string
+	// Endpoint is the URL of the OTLP/HTTP collector, e.g. http://collector:4318. The OTEL_EXPORTER_OTLP_*
+	// environment variables are used if empty.
+	Endpoint string
+	// File is the path the spans are appended to with the file exporter
+	File string
+}
+
+// Setup installs a global tracer provider exporting the spans as configured. The returned function flushes
+// the pending spans and must be called before exiting.
+func Setup(ctx context.Context, opts Options) (func(context.Context) error, error) {
+	var exporter sdktrace.SpanExporter
+	var file *os.File
+	var err error
+	switch opts.Exporter {
+	case "", ExporterNone:
+		return func(context.Context) error { return nil }, nil
+	case ExporterOTLP:
+		var otlpOpts []otlptracehttp.Option
+		if opts.Endpoint != "" {
+			otlpOpts = append(otlpOpts, otlptracehttp.WithEndpointURL(opts.Endpoint))
+		}
+		exporter, err = otlptracehttp.New(ctx, otlpOpts...)
+	case ExporterStdout:
+		exporter, err = stdouttrace.New(stdouttrace.WithWriter(os.Stdout))
+	case ExporterFile:
+		if opts.File == "" {
+			return nil, fmt.Errorf("the file exporter needs a file path")
+		}
+		file, err = os.OpenFile(opts.File, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+		if err != nil {
+			return nil, err
+		}
+		exporter, err = stdouttrace.New(stdouttrace.WithWriter(file))
+	default:
+		return nil, fmt.Errorf("unknown tracing exporter %q", opts.Exporter)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to create %s trace exporter: %w", opts.Exporter, err)
+	}
+
+	res, err := resource.Merge(resource.Default(), resource.NewSchemaless(attribute.String("service.name", serviceName)))
+	if err != nil {
+		return nil, err
+	}
+	provider := sdktrace.NewTracerProvider(sdktrace.WithBatcher(exporter), sdktrace.WithResource(res))
+	otel.SetTracerProvider(provider)
+	return func(ctx context.Context) error {
+		err := provider.Shutdown(ctx)
+		if file != nil {
+			return errors.Join(err, file.Close())
+		}
+		return err
+	}, nil
+}
+
+// Tracer returns the tracer of the operator from the global tracer provider
+func Tracer() trace.Tracer {
+	return otel.Tracer(tracerName)
+}
+
+// Start starts a span of the operator
+func Start(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return Tracer().Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// End records the error, if any, with its reason and ends the span
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		span.SetAttributes(ReasonKey.String(string(reasons.ReasonFor(err))))
+	}
+	span.End()
+}
+
+// RecordChanges adds the number of labels or taints added, changed and removed to the span of the context
+func RecordChanges(ctx context.Context, metadata string, added, changed, removed int) {
+	trace.SpanFromContext(ctx).SetAttributes(
+		attribute.Int(fmt.Sprintf("mnmo.%s.added", metadata), added),
+		attribute.Int(fmt.Sprintf("mnmo.%s.changed", metadata), changed),
+		attribute.Int(fmt.Sprintf("mnmo.%s.removed", metadata), removed),
+	)
+}
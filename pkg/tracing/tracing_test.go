@@ -0,0 +1,155 @@
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+var _ = Describe("Tracing", func() {
+	var (
+		recorder         *tracetest.SpanRecorder
+		previousProvider trace.TracerProvider
+	)
+
+	s := runtime.NewScheme()
+	Expect(corev1.AddToScheme(s)).To(Succeed())
+
+	BeforeEach(func() {
+		previousProvider = otel.GetTracerProvider()
+		recorder = tracetest.NewSpanRecorder()
+		otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	})
+
+	AfterEach(func() {
+		otel.SetTracerProvider(previousProvider)
+	})
+
+	attributes := func(span sdktrace.ReadOnlySpan) map[attribute.Key]attribute.Value {
+		attrs := map[attribute.Key]attribute.Value{}
+		for _, attr := range span.Attributes() {
+			attrs[attr.Key] = attr.Value
+		}
+		return attrs
+	}
+
+	Describe("Setup", func() {
+		It("should not install a tracer provider when tracing is disabled", func() {
+			provider := otel.GetTracerProvider()
+			shutdown, err := Setup(context.TODO(), Options{Exporter: ExporterNone})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(shutdown(context.TODO())).To(Succeed())
+			Expect(otel.GetTracerProvider()).To(Equal(provider))
+		})
+
+		It("should fail for an unknown exporter", func() {
+			_, err := Setup(context.TODO(), Options{Exporter: "jaeger"})
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("should fail for the file exporter without a file", func() {
+			_, err := Setup(context.TODO(), Options{Exporter: ExporterFile})
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("should write the spans to the file", func() {
+			file := filepath.Join(GinkgoT().TempDir(), "spans.json")
+			shutdown, err := Setup(context.TODO(), Options{Exporter: ExporterFile, File: file})
+			Expect(err).NotTo(HaveOccurred())
+
+			_, span := Start(context.TODO(), "ReconcileMachineSet", MachineSetKey.String("test-machineset"))
+			span.End()
+			Expect(shutdown(context.TODO())).To(Succeed())
+
+			content, err := os.ReadFile(file)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(content)).To(ContainSubstring("ReconcileMachineSet"))
+			Expect(string(content)).To(ContainSubstring("test-machineset"))
+		})
+	})
+
+	Describe("End", func() {
+		It("should record the error and its reason", func() {
+			_, span := Start(context.TODO(), "UpdateNode")
+			End(span, fmt.Errorf("boom"))
+
+			spans := recorder.Ended()
+			Expect(spans).To(HaveLen(1))
+			Expect(spans[0].Status().Code).To(Equal(codes.Error))
+			Expect(attributes(spans[0])).To(HaveKeyWithValue(ReasonKey, attribute.StringValue("Unknown")))
+		})
+
+		It("should leave the status unset without an error", func() {
+			_, span := Start(context.TODO(), "UpdateNode")
+			End(span, nil)
+
+			spans := recorder.Ended()
+			Expect(spans).To(HaveLen(1))
+			Expect(spans[0].Status().Code).To(Equal(codes.Unset))
+		})
+	})
+
+	Describe("RecordChanges", func() {
+		It("should add the change counts to the span of the context", func() {
+			ctx, span := Start(context.TODO(), "UpdateNode")
+			RecordChanges(ctx, "label", 1, 2, 3)
+			span.End()
+
+			attrs := attributes(recorder.Ended()[0])
+			Expect(attrs).To(HaveKeyWithValue(attribute.Key("mnmo.label.added"), attribute.IntValue(1)))
+			Expect(attrs).To(HaveKeyWithValue(attribute.Key("mnmo.label.changed"), attribute.IntValue(2)))
+			Expect(attrs).To(HaveKeyWithValue(attribute.Key("mnmo.label.removed"), attribute.IntValue(3)))
+		})
+	})
+
+	Describe("WrapClient", func() {
+		var c client.Client
+
+		BeforeEach(func() {
+			c = WrapClient(fake.NewClientBuilder().WithScheme(s).WithObjects(&corev1.Node{
+				ObjectMeta: metav1.ObjectMeta{Name: "test-node"},
+			}).Build())
+		})
+
+		It("should create a child span for each API call", func() {
+			ctx, parent := Start(context.TODO(), "ReconcileMachineSet")
+			node := &corev1.Node{}
+			Expect(c.Get(ctx, client.ObjectKey{Name: "test-node"}, node)).To(Succeed())
+			Expect(c.Update(ctx, node)).To(Succeed())
+			parent.End()
+
+			spans := recorder.Ended()
+			Expect(spans).To(HaveLen(3))
+			Expect(spans[0].Name()).To(Equal("Get Node"))
+			Expect(spans[0].Parent().SpanID()).To(Equal(parent.SpanContext().SpanID()))
+			Expect(attributes(spans[0])).To(HaveKeyWithValue(NameKey, attribute.StringValue("test-node")))
+			Expect(spans[1].Name()).To(Equal("Update Node"))
+			Expect(spans[1].Parent().SpanID()).To(Equal(parent.SpanContext().SpanID()))
+		})
+
+		It("should record the reason of a failed API call", func() {
+			Expect(c.Get(context.TODO(), client.ObjectKey{Name: "missing-node"}, &corev1.Node{})).NotTo(Succeed())
+
+			spans := recorder.Ended()
+			Expect(spans).To(HaveLen(1))
+			Expect(spans[0].Status().Code).To(Equal(codes.Error))
+			Expect(attributes(spans[0])).To(HaveKeyWithValue(ReasonKey, attribute.StringValue("NodeNotFound")))
+		})
+	})
+})